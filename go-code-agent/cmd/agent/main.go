@@ -3,74 +3,313 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/example/go-code-agent/pkg/ai"
+	"github.com/example/go-code-agent/pkg/llm"
+	"github.com/example/go-code-agent/pkg/mcpclient"
+	"github.com/example/go-code-agent/pkg/tools"
+	"github.com/example/go-code-agent/pkg/transcript"
 )
 
-func renderSpinner(stopCh <-chan struct{}) {
+// spinner animates while the agent works, pause stops the drawing while
+// the user is asked something
+type spinner struct {
+	mu     sync.Mutex
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+func startSpinner() *spinner {
+	s := &spinner{stopCh: make(chan struct{}), done: make(chan struct{})}
+	go s.render()
+	return s
+}
+
+func (s *spinner) render() {
+	defer close(s.done)
 	frames := []string{"|", "/", "-", "\\"}
 	i := 0
 
 	for {
 		select {
-		case <-stopCh:
+		case <-s.stopCh:
 			// wipe the line clear when thinking finishes
 			fmt.Print("\r\033[K")
 			return
 		default:
+			s.mu.Lock()
 			fmt.Printf("\r\033[35m%s\033[0m AI is reviewing files and thinking...", frames[i%4])
+			s.mu.Unlock()
 			i++
 			time.Sleep(100 * time.Millisecond)
 		}
 	}
 }
 
+// pause stops the drawing and clears the line until the returned func is called
+func (s *spinner) pause() func() {
+	s.mu.Lock()
+	fmt.Print("\r\033[K")
+	return s.mu.Unlock
+}
+
+func (s *spinner) stop() {
+	close(s.stopCh)
+	<-s.done
+}
+
+// turnTimeout bounds one request, approval prompts included. The write
+// tools give up waiting for an answer before that, see ai.SetupAgent.
+const turnTimeout = 10 * time.Minute
+
+// readLines reads r on a single goroutine. The prompt and the approval
+// questions both take their lines from the channel, so a question abandoned
+// by a cancelled turn never competes with the prompt for the next line.
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		if err := sc.Err(); err != nil {
+			log.Printf("scanner error: %v", err)
+		}
+	}()
+	return lines
+}
+
+// approver asks on the terminal before every file change
+type approver struct {
+	in      <-chan string
+	spin    *spinner
+	approve bool // approve everything from now on
+	dryRun  bool // only show the diffs
+}
+
+func (a *approver) ask(ctx context.Context, c tools.Change) (bool, error) {
+	resume := a.spin.pause()
+	defer resume()
+
+	printDiff(c)
+	if a.dryRun {
+		fmt.Println("\033[33m(dry run, not written)\033[0m")
+		return true, nil
+	}
+	if a.approve {
+		fmt.Println("\033[33m(auto-approved)\033[0m")
+		return true, nil
+	}
+	for {
+		fmt.Printf("\033[33mApply change to %s? [y]es / [n]o / [a]ll: \033[0m", c.Path)
+		var answer string
+		select {
+		case line, ok := <-a.in:
+			if !ok {
+				return false, fmt.Errorf("no answer: input closed")
+			}
+			answer = line
+		case <-ctx.Done():
+			fmt.Println()
+			return false, fmt.Errorf("no answer: %w", ctx.Err())
+		}
+		// an answer racing the deadline does not count
+		if err := ctx.Err(); err != nil {
+			return false, fmt.Errorf("no answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "a", "all":
+			a.approve = true
+			return true, nil
+		}
+	}
+}
+
+func printDiff(c tools.Change) {
+	for _, line := range strings.SplitAfter(c.Diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Print("\033[1m" + line + "\033[0m")
+		case strings.HasPrefix(line, "+"):
+			fmt.Print("\033[32m" + line + "\033[0m")
+		case strings.HasPrefix(line, "-"):
+			fmt.Print("\033[31m" + line + "\033[0m")
+		case strings.HasPrefix(line, "@@"):
+			fmt.Print("\033[36m" + line + "\033[0m")
+		default:
+			fmt.Print(line)
+		}
+	}
+}
+
+// defaultMCPConfig is read when present, the agent runs without MCP servers otherwise
+const defaultMCPConfig = "mcp.json"
+
+// transcriptDir holds the saved conversations, hidden from the file tools
+const transcriptDir = ".agent/transcripts"
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func newTranscriptPath() string {
+	return filepath.Join(transcriptDir, time.Now().Format("20060102-150405")+".jsonl")
+}
+
 func main() {
+	autoApprove := flag.Bool("yes", false, "apply file changes without asking")
+	dryRun := flag.Bool("dry-run", false, "only print the diffs of file changes, never write")
+	mcpConfig := flag.String("mcp-config", envOr("AGENT_MCP_CONFIG", defaultMCPConfig), "JSON file listing the MCP servers to use")
+	modelName := flag.String("model", envOr("AGENT_MODEL", "deepseek-coder"), "model to chat with")
+	baseURL := flag.String("url", envOr("AGENT_BASE_URL", "http://localhost:8080"), "OpenAI-compatible server, with or without /v1")
+	contextWindow := flag.Int("context-window", envInt("AGENT_CONTEXT_WINDOW", ai.DefaultContextWindow), "context size of the model in tokens")
+	resume := flag.String("resume", "", "JSONL transcript to continue, new turns are appended to it")
+	transcriptPath := flag.String("transcript", os.Getenv("AGENT_TRANSCRIPT"), "JSONL file the conversation is saved to (default "+transcriptDir+"/<time>.jsonl)")
+	flag.Parse()
+
 	ctx := context.Background()
 
-	modelName := "deepseek-coder"
-	localAIURL := "http://localhost:8080"
 	fmt.Println("\033[36m=== LocalAI CLI Agent Ready ===\033[0m")
-	fmt.Printf("Model: %s at %s\n", modelName, localAIURL)
-	fmt.Println("Type your instructions below (type 'exit' to quit):")
-	agent, err := ai.SetupAgent(modelName, localAIURL)
+	fmt.Printf("Model: %s at %s\n", *modelName, *baseURL)
+	if *dryRun {
+		fmt.Println("Dry run: file changes are only shown, not written.")
+	}
+
+	lines := readLines(os.Stdin)
+	approval := &approver{in: lines, approve: *autoApprove, dryRun: *dryRun}
+
+	var err error
+	cfg := ai.Config{
+		Model:         *modelName,
+		BaseURL:       *baseURL,
+		APIKey:        os.Getenv("AGENT_API_KEY"),
+		ContextWindow: *contextWindow,
+		Workspace:     ".",
+		Approve:       approval.ask,
+		DryRun:        *dryRun,
+	}
+	// AGENT_ALLOWED_COMMANDS is a comma separated allow-list for run_command,
+	// the commands are not sandboxed, see tools.Runner
+	if v := os.Getenv("AGENT_ALLOWED_COMMANDS"); v != "" {
+		cfg.AllowedCommands = strings.Split(v, ",")
+	}
+	// the default file is optional, one named explicitly has to exist
+	cfg.MCP, err = mcpclient.LoadConfig(*mcpConfig, *mcpConfig == defaultMCPConfig)
 	if err != nil {
 		log.Fatalf("Agent build sequence crashed: %v", err)
 	}
-	defer agent.Cleanup(ctx)
+	agent, err := ai.SetupAgent(ctx, cfg)
+	if agent == nil {
+		log.Fatalf("Agent build sequence crashed: %v", err)
+	}
+	defer agent.Close()
+	if err != nil {
+		fmt.Printf("\033[33m[Warning]:\033[0m %v\n", err)
+	}
+	if servers := agent.MCPServers(); len(servers) > 0 {
+		fmt.Printf("MCP servers: %s\n", strings.Join(servers, ", "))
+	}
+	ws := agent.Workspace()
+
+	switch {
+	case *resume != "":
+		history, err := transcript.Load(*resume)
+		if err != nil {
+			log.Fatalf("Resume failed: %v", err)
+		}
+		agent.SetHistory(history)
+		*transcriptPath = *resume
+		fmt.Printf("Resumed %d messages from %s\n", len(history), *resume)
+	case *transcriptPath == "":
+		*transcriptPath = newTranscriptPath()
+	}
+	fmt.Printf("Transcript: %s\n", *transcriptPath)
+	fmt.Println("Type your instructions below ('undo' reverts the last change, 'changes' lists them, " +
+		"'reset' starts a new conversation, 'exit' quits):")
 
-	scanner := bufio.NewScanner(os.Stdin)
+	var session llm.Usage
+	turn := 0
 	for {
 		fmt.Print("\n\033[32m[CodeAgent Ask]>\033[0m ")
-		if !scanner.Scan() {
+		line, ok := <-lines
+		if !ok {
 			break
 		}
-		input := strings.TrimSpace(scanner.Text())
+		input := strings.TrimSpace(line)
 
 		if input == "" {
 			continue
 		}
-		if strings.ToLower(input) == "exit" {
-			break
+		switch strings.ToLower(input) {
+		case "exit":
+			return
+		case "reset":
+			agent.Reset()
+			session, turn = llm.Usage{}, 0
+			*transcriptPath = newTranscriptPath()
+			fmt.Printf("New conversation, transcript: %s\n", *transcriptPath)
+			continue
+		case "undo":
+			c, err := ws.Undo()
+			if err != nil {
+				fmt.Printf("\033[31m[Undo]:\033[0m %v\n", err)
+				continue
+			}
+			if c.Existed {
+				fmt.Printf("\033[34m[Undo]:\033[0m restored %s\n", c.Path)
+			} else {
+				fmt.Printf("\033[34m[Undo]:\033[0m removed %s\n", c.Path)
+			}
+			continue
+		case "changes":
+			changes := ws.Journal.List()
+			if len(changes) == 0 {
+				fmt.Println("No changes in this session.")
+			}
+			for i, c := range changes {
+				action := "modified"
+				if !c.Existed {
+					action = "created"
+				}
+				fmt.Printf("%2d. %s %s %s\n", i+1, c.Time.Format(time.TimeOnly), action, c.Path)
+			}
+			continue
 		}
 
-		stopSpinner := make(chan struct{})
-		go renderSpinner(stopSpinner)
+		spin := startSpinner()
+		approval.spin = spin
 
-		// per-request timeout
-		reqCtx, reqCancel := context.WithTimeout(ctx, 2*time.Minute)
+		reqCtx, reqCancel := context.WithTimeout(ctx, turnTimeout)
 
-		// Use streaming for real-time response
 		response, err := agent.Run(reqCtx, input)
 		reqCancel()
 
-		close(stopSpinner)
-		time.Sleep(50 * time.Millisecond)
+		spin.stop()
 
 		if err != nil {
 			fmt.Printf("\n\033[31m[Error]:\033[0m %v\n", err)
@@ -80,8 +319,47 @@ func main() {
 		fmt.Println("\n\033[34m[Response]:\033[0m")
 		fmt.Println(response.Content)
 
+		turn++
+		session = session.Add(response.Usage)
+		printTurnSummary(turn, response, session, agent.ContextWindow())
+		if err := transcript.Append(*transcriptPath, response.Messages); err != nil {
+			fmt.Printf("\033[31m[Transcript]:\033[0m %v\n", err)
+		}
+	}
+}
+
+// printTurnSummary reports the tools a turn called and the tokens it used.
+func printTurnSummary(turn int, r *ai.Response, session llm.Usage, window int) {
+	var calls []string
+	counts := map[string]int{}
+	for _, m := range r.Messages {
+		for _, c := range m.ToolCalls {
+			if counts[c.Function.Name] == 0 {
+				calls = append(calls, c.Function.Name)
+			}
+			counts[c.Function.Name]++
+		}
+	}
+	for i, name := range calls {
+		if counts[name] > 1 {
+			calls[i] = fmt.Sprintf("%s x%d", name, counts[name])
+		}
+	}
+	called := fmt.Sprintf("%d tool calls", r.ToolCalls)
+	if len(calls) > 0 {
+		called += " (" + strings.Join(calls, ", ") + ")"
+	}
+
+	tokens := "tokens not reported"
+	if r.Usage.TotalTokens > 0 {
+		tokens = fmt.Sprintf("tokens %d prompt + %d completion = %d, session %d",
+			r.Usage.PromptTokens, r.Usage.CompletionTokens, r.Usage.TotalTokens, session.TotalTokens)
+	}
+	fmt.Printf("\033[90m[Turn %d] %s | %s | context ~%d/%d\033[0m\n", turn, called, tokens, r.ContextTokens, window)
+	if r.Dropped > 0 {
+		fmt.Printf("\033[90m(%d earlier messages left out to fit the context window)\033[0m\n", r.Dropped)
 	}
-	if err := scanner.Err(); err != nil {
-		log.Printf("scanner error: %v", err)
+	if r.Elided > 0 {
+		fmt.Printf("\033[90m(%d tool results removed to fit the context window)\033[0m\n", r.Elided)
 	}
 }
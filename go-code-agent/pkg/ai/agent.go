@@ -0,0 +1,203 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/go-code-agent/pkg/llm"
+	"github.com/example/go-code-agent/pkg/mcpclient"
+	"github.com/example/go-code-agent/pkg/tools"
+)
+
+const finalAnswerPrompt = "Provide your final answer now without requesting more tools."
+
+// elidedResult replaces a tool result removed to fit the context window.
+const elidedResult = "[output removed to fit the context window, call the tool again if it is still needed]"
+
+// Agent runs the function-calling loop: the model answers or asks for tool
+// calls, the calls are dispatched and their results appended as tool
+// messages until the model answers. The conversation carries over from one
+// run to the next, its oldest turns are dropped when it outgrows the
+// context window, and within a run the oldest tool results are elided.
+type Agent struct {
+	client        *llm.Client
+	tools         *tools.Toolset
+	maxIterations int
+	workspace     *tools.Workspace
+	mcp           *mcpclient.Servers
+	// contextWindow is the model's context size in tokens, 0 never trims
+	contextWindow int
+	history       []llm.Message
+}
+
+// Response is the final answer of a run.
+type Response struct {
+	Content string
+	// Messages is the run's part of the conversation: the question, every
+	// tool call with its result and the answer.
+	Messages []llm.Message
+	// ToolCalls is how many tools the model called.
+	ToolCalls int
+	Usage     llm.Usage
+	// ContextTokens estimates the prompt of the last model call.
+	ContextTokens int
+	// Dropped is how many earlier messages were left out to fit the context window.
+	Dropped int
+	// Elided is how many tool results were replaced by a note during the
+	// run, when the rounds of tool calls outgrew the context window.
+	Elided int
+}
+
+func NewAgent(client *llm.Client, ts *tools.Toolset, maxIterations int) *Agent {
+	return &Agent{client: client, tools: ts, maxIterations: maxIterations}
+}
+
+// History returns the conversation so far, without the system prompt.
+func (a *Agent) History() []llm.Message {
+	return append([]llm.Message(nil), a.history...)
+}
+
+// SetHistory replaces the conversation, e.g. with a saved transcript.
+// System messages are skipped, the current system prompt is used instead.
+func (a *Agent) SetHistory(msgs []llm.Message) {
+	a.history = nil
+	for _, m := range msgs {
+		if m.Role != llm.RoleSystem {
+			a.history = append(a.history, m)
+		}
+	}
+}
+
+// Reset starts a new conversation.
+func (a *Agent) Reset() { a.history = nil }
+
+// ContextWindow is the context size in tokens the conversation is fit into.
+func (a *Agent) ContextWindow() int { return a.contextWindow }
+
+// Workspace is the sandbox of the file tools, nil when the agent has none.
+func (a *Agent) Workspace() *tools.Workspace { return a.workspace }
+
+// MCPServers returns the names of the connected MCP servers.
+func (a *Agent) MCPServers() []string {
+	if a.mcp == nil {
+		return nil
+	}
+	return a.mcp.Names()
+}
+
+// Close disconnects the MCP servers and stops the ones started as commands.
+func (a *Agent) Close() error {
+	if a.mcp == nil {
+		return nil
+	}
+	return a.mcp.Close()
+}
+
+// Run answers input in the context of the conversation so far, calling
+// tools for at most maxIterations rounds. The question and everything the
+// run produced join the conversation once it succeeds; a failed run leaves
+// the conversation as it was.
+func (a *Agent) Run(ctx context.Context, input string) (*Response, error) {
+	system := llm.Message{Role: llm.RoleSystem, Content: a.tools.SystemPrompt()}
+	question := llm.Message{Role: llm.RoleUser, Content: input}
+	history, dropped := a.trim(a.history, llm.EstimateMessages([]llm.Message{system, question}))
+	resp := &Response{Dropped: dropped}
+
+	conv := append([]llm.Message{system}, history...)
+	start := len(conv)
+	conv = append(conv, question)
+	defs := a.tools.Definitions()
+
+	chat := func(req llm.Request) (llm.Message, error) {
+		resp.Elided += a.elide(conv)
+		req.Messages, req.Tools = conv, defs
+		resp.ContextTokens = llm.EstimateMessages(conv)
+		reply, usage, err := a.client.Chat(ctx, req)
+		resp.Usage = resp.Usage.Add(usage)
+		if err != nil {
+			return reply, fmt.Errorf("llm call failed: %w", err)
+		}
+		conv = append(conv, reply)
+		return reply, nil
+	}
+	finish := func(reply llm.Message) (*Response, error) {
+		resp.Content = reply.Content
+		resp.Messages = conv[start:]
+		a.history = append(history, resp.Messages...)
+		return resp, nil
+	}
+
+	for i := 0; i < a.maxIterations; i++ {
+		reply, err := chat(llm.Request{})
+		if err != nil {
+			return resp, err
+		}
+		if len(reply.ToolCalls) == 0 {
+			return finish(reply) // final answer
+		}
+
+		for _, call := range reply.ToolCalls {
+			conv = append(conv, a.tools.Call(ctx, call))
+			resp.ToolCalls++
+		}
+	}
+
+	// out of rounds: ask for an answer from what the tools returned so far
+	conv = append(conv, llm.Message{Role: llm.RoleUser, Content: finalAnswerPrompt})
+	reply, err := chat(llm.Request{ToolChoice: "none"})
+	if err != nil {
+		return resp, err
+	}
+	return finish(reply)
+}
+
+// elide replaces the oldest tool results in conv with elidedResult until
+// the conversation fits the context window with an eighth of it left for
+// the answer. trim only fits the history before the run, the results of
+// its rounds of tool calls are kept in check here. It returns how many
+// results were replaced.
+func (a *Agent) elide(conv []llm.Message) int {
+	if a.contextWindow <= 0 {
+		return 0
+	}
+	budget := a.contextWindow - a.contextWindow/8
+	total := llm.EstimateMessages(conv)
+	elided := 0
+	for i := 0; i < len(conv) && total > budget; i++ {
+		m := &conv[i]
+		saved := llm.EstimateTokens(m.Content) - llm.EstimateTokens(elidedResult)
+		if m.Role != llm.RoleTool || saved <= 0 {
+			continue
+		}
+		total -= saved
+		m.Content = elidedResult
+		elided++
+	}
+	return elided
+}
+
+// trim drops the oldest turns of history until it and reserved tokens
+// fill at most three quarters of the context window, the rest is left for
+// the tool results and the answer of the next run. A turn starts with a
+// user message, so no tool result is kept without its call. It returns
+// the rest of history and how many messages were dropped, history itself
+// is not modified.
+func (a *Agent) trim(history []llm.Message, reserved int) ([]llm.Message, int) {
+	if a.contextWindow <= 0 {
+		return history, 0
+	}
+	budget := a.contextWindow*3/4 - reserved
+	dropped := 0
+	for len(history) > 0 && llm.EstimateMessages(history) > budget {
+		next := len(history)
+		for i := 1; i < len(history); i++ {
+			if history[i].Role == llm.RoleUser {
+				next = i
+				break
+			}
+		}
+		history = history[next:]
+		dropped += next
+	}
+	return history, dropped
+}
@@ -2,41 +2,205 @@ package ai
 
 import (
 	"context"
+	"strings"
 	"time"
 
-	"github.com/agenticgokit/agenticgokit/v1beta"
+	"github.com/example/go-code-agent/pkg/llm"
+	"github.com/example/go-code-agent/pkg/mcpclient"
 	"github.com/example/go-code-agent/pkg/tools"
-
-	_ "github.com/agenticgokit/agenticgokit/plugins/llm/openai" // registers the "openai" provider
 )
 
-const basePrompt = "You are an autonomous coding assistant. You use tools to read, search, and write files in the local running workspace."
-
-func SetupAgent(modelName string, AIUrl string) (v1beta.Agent, error) {
-	ts := tools.NewToolset(basePrompt).
-		Add("search_workspace_files", "Lists every file path in the workspace. No arguments.", nil,
-			func(ctx context.Context, _ map[string]interface{}) (string, error) {
-				return tools.SearchWorkspaceFiles(ctx)
-			}).
-		Add("read_file_content", "Reads the full text of a file.",
-			map[string]interface{}{"path": "string - file to read"},
-			func(ctx context.Context, a map[string]interface{}) (string, error) {
-				return tools.ReadFileContent(ctx, tools.Arg(a, "path"))
-			}).
-		Add("write_file_content", "Creates folders if needed and writes text to a file.",
-			map[string]interface{}{"path": "string - destination", "content": "string - file body"},
-			func(ctx context.Context, a map[string]interface{}) (string, error) {
-				return tools.WriteFileContent(ctx, tools.Arg(a, "path"), tools.Arg(a, "content"))
-			})
-
-	return v1beta.NewBuilder("CodeAgent").
-		WithConfig(&v1beta.Config{
-			Name:         "CodeAgent",
-			SystemPrompt: basePrompt,
-			LLM:          v1beta.LLMConfig{Provider: "openai", Model: modelName, BaseURL: AIUrl},
-			Tools:        &v1beta.ToolsConfig{Enabled: true, MaxRetries: 3, Timeout: 30 * time.Second},
-		}).
-		WithHandler(ts.Handler(5)).
-		Build()
+const basePrompt = "You are an autonomous coding assistant. You use tools to read, search, edit and write files " +
+	"and to run commands in the local workspace. Paths are relative to the workspace root. " +
+	"Prefer replace_in_file or replace_lines over rewriting whole files."
+
+// Config is what SetupAgent needs to build an agent.
+type Config struct {
+	Model   string
+	BaseURL string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	// ContextWindow is the model's context size in tokens, 0 uses
+	// DefaultContextWindow. Long conversations and tool results are cut to fit.
+	ContextWindow int
+	// Workspace is the directory the file tools are confined to.
+	Workspace string
+	// AllowedCommands are the executables run_command may start,
+	// nil uses tools.DefaultAllowedCommands.
+	AllowedCommands []string
+	// CommandTimeout bounds a run_command call, 0 uses tools.DefaultCommandTimeout.
+	CommandTimeout time.Duration
+	// Approve is asked before every file change, nil applies changes without asking.
+	Approve tools.Approver
+	// DryRun only shows the diffs of file changes.
+	DryRun bool
+	// MCP lists the MCP servers whose tools are offered next to the built-in ones.
+	MCP mcpclient.Config
 }
 
+// DefaultContextWindow is the context size assumed when Config leaves it out,
+// what most local models are served with.
+const DefaultContextWindow = 8192
+
+// approvalTimeout bounds the write tools while a person is asked to
+// approve, shorter than the turn timeout of the CLI so an unanswered
+// prompt fails the tool call rather than the whole turn
+const approvalTimeout = 5 * time.Minute
+
+// SetupAgent builds the agent and connects its MCP servers, which live until
+// Close or until ctx is done. A server that cannot be reached does not stop
+// the setup: the agent is returned together with the error naming it.
+func SetupAgent(ctx context.Context, cfg Config) (*Agent, error) {
+	ws, err := tools.NewWorkspace(cfg.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	ws.Approve = cfg.Approve
+	ws.DryRun = cfg.DryRun
+	runner := &tools.Runner{Workspace: ws, Allowed: cfg.AllowedCommands, Timeout: cfg.CommandTimeout}
+	if runner.Allowed == nil {
+		runner.Allowed = tools.DefaultAllowedCommands
+	}
+	if runner.Timeout <= 0 {
+		runner.Timeout = tools.DefaultCommandTimeout
+	}
+
+	window := cfg.ContextWindow
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	ts := tools.NewToolset(basePrompt)
+	// a single tool result may take up a quarter of the window
+	ts.MaxOutput = window / 4 * llm.CharsPerToken
+	var writeTimeout time.Duration
+	if cfg.Approve != nil {
+		writeTimeout = approvalTimeout
+	}
+	addWorkspaceTools(ts, ws, runner, writeTimeout)
+
+	agent := NewAgent(llm.New(cfg.BaseURL, cfg.Model, cfg.APIKey), ts, 5)
+	agent.workspace = ws
+	agent.contextWindow = window
+	// MCP tools are registered after the built-in ones and never replace
+	// them, a clashing name is skipped and reported in err
+	agent.mcp, err = mcpclient.Connect(ctx, cfg.MCP, ts)
+	return agent, err
+}
+
+// addWorkspaceTools registers the file and command tools, writeTimeout
+// bounds the tools that change files, 0 uses the set's default.
+func addWorkspaceTools(ts *tools.Toolset, ws *tools.Workspace, runner *tools.Runner, writeTimeout time.Duration) {
+	ts.Add(tools.Tool{
+		Name:        "search_workspace_files",
+		Description: "Lists the file paths in the workspace, or below path. Narrow large trees with path and glob.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"path": tools.String("directory to list recursively, defaults to the workspace root"),
+			"glob": tools.String("only files whose name matches, e.g. *.go"),
+		}),
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			return ws.SearchWorkspaceFiles(ctx, tools.Arg(a, "path"), tools.Arg(a, "glob"))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name:        "list_directory",
+		Description: "Lists the entries of a directory with file sizes and, for subdirectories, the total size and file count.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"path": tools.String("directory to list, defaults to the workspace root"),
+		}),
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			return ws.ListDirectory(ctx, tools.Arg(a, "path"))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name:        "read_file_content",
+		Description: "Reads the full text of a file. With start_line/end_line returns just those lines, numbered.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"path":       tools.String("file to read, relative to the workspace"),
+			"start_line": tools.Integer("first line to return, 1-based"),
+			"end_line":   tools.Integer("last line to return, inclusive"),
+		}, "path"),
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			if _, ok := a["start_line"]; ok {
+				return ws.ReadFileLines(ctx, tools.Arg(a, "path"), tools.IntArg(a, "start_line", 1), tools.IntArg(a, "end_line", 0))
+			}
+			if _, ok := a["end_line"]; ok {
+				return ws.ReadFileLines(ctx, tools.Arg(a, "path"), 1, tools.IntArg(a, "end_line", 0))
+			}
+			return ws.ReadFileContent(ctx, tools.Arg(a, "path"))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name:        "grep",
+		Description: "Searches the workspace text files for a Go regular expression and returns path:line: text for every match.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"pattern":     tools.String("regular expression (RE2 syntax)"),
+			"path":        tools.String("directory to search, defaults to the workspace root"),
+			"glob":        tools.String("only files whose name matches, e.g. *.go"),
+			"max_results": tools.Integer("stop after this many matches, default 100"),
+		}, "pattern"),
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			return ws.Grep(ctx, tools.Arg(a, "pattern"), tools.Arg(a, "path"), tools.Arg(a, "glob"), tools.IntArg(a, "max_results", 0))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name:        "write_file_content",
+		Description: "Creates folders if needed and writes text to a file, replacing its content. Use it for new files.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"path":    tools.String("destination file, relative to the workspace"),
+			"content": tools.String("the full file body"),
+		}, "path", "content"),
+		Timeout: writeTimeout,
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			return ws.WriteFileContent(ctx, tools.Arg(a, "path"), tools.Arg(a, "content"))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name: "replace_in_file",
+		Description: "Replaces an exact piece of text in a file. old_text must match the file exactly and " +
+			"occur once, unless replace_all is set.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"path":        tools.String("file to edit"),
+			"old_text":    tools.String("the exact text to replace, with enough context to be unique"),
+			"new_text":    tools.String("the replacement text"),
+			"replace_all": tools.Boolean("replace every occurrence"),
+		}, "path", "old_text", "new_text"),
+		Timeout: writeTimeout,
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			return ws.ReplaceInFile(ctx, tools.Arg(a, "path"), tools.Arg(a, "old_text"), tools.Arg(a, "new_text"), tools.BoolArg(a, "replace_all"))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name: "replace_lines",
+		Description: "Replaces lines start_line..end_line (1-based, inclusive) of a file with content. " +
+			"end_line = start_line-1 inserts before start_line. Read the numbered lines first.",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"path":       tools.String("file to edit"),
+			"start_line": tools.Integer("first line to replace"),
+			"end_line":   tools.Integer("last line to replace"),
+			"content":    tools.String("the new lines, empty deletes the range"),
+		}, "path", "start_line", "end_line", "content"),
+		Timeout: writeTimeout,
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			return ws.ReplaceLines(ctx, tools.Arg(a, "path"), tools.IntArg(a, "start_line", 0), tools.IntArg(a, "end_line", 0), tools.Arg(a, "content"))
+		},
+	})
+	ts.Add(tools.Tool{
+		Name: "run_command",
+		Description: "Runs a program in the workspace root without a shell and returns its exit status and output. " +
+			"Allowed programs: " + strings.Join(runner.Allowed, ", ") + ".",
+		Parameters: tools.Object(map[string]*tools.Schema{
+			"command": tools.String("program name, e.g. go"),
+			"args":    tools.Array("arguments, e.g. [\"test\", \"./...\"]", tools.String("")),
+		}, "command"),
+		// the runner enforces its own timeout, this only catches a hung wait
+		Timeout: runner.Timeout + 5*time.Second,
+		Run: func(ctx context.Context, a map[string]interface{}) (string, error) {
+			var args []string
+			list, _ := a["args"].([]interface{})
+			for _, v := range list {
+				args = append(args, v.(string))
+			}
+			return runner.Run(ctx, tools.Arg(a, "command"), args)
+		},
+	})
+}
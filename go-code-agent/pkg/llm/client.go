@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client calls the /v1/chat/completions endpoint of BaseURL.
+type Client struct {
+	BaseURL string
+	Model   string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	HTTP   *http.Client
+}
+
+// New returns a client for model served at baseURL.
+func New(baseURL, model, apiKey string) *Client {
+	return &Client{BaseURL: baseURL, Model: model, APIKey: apiKey, HTTP: http.DefaultClient}
+}
+
+// Chat sends the conversation and returns the assistant's reply and the tokens used.
+func (c *Client) Chat(ctx context.Context, req Request) (Message, Usage, error) {
+	if req.Model == "" {
+		req.Model = c.Model
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Message{}, Usage{}, fmt.Errorf("encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return Message{}, Usage{}, fmt.Errorf("chat completion: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Message{}, Usage{}, fmt.Errorf("read response: %w", err)
+	}
+	var out response
+	if err := json.Unmarshal(data, &out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return Message{}, Usage{}, fmt.Errorf("chat completion: %s: %s", resp.Status, bytes.TrimSpace(data))
+		}
+		return Message{}, Usage{}, fmt.Errorf("decode response: %w", err)
+	}
+	if out.Error != nil {
+		return Message{}, Usage{}, fmt.Errorf("chat completion: %s: %s", resp.Status, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Message{}, Usage{}, fmt.Errorf("chat completion: %s", resp.Status)
+	}
+	if len(out.Choices) == 0 {
+		return Message{}, out.Usage, errors.New("chat completion returned no choices")
+	}
+
+	msg := out.Choices[0].Message
+	if msg.Role == "" {
+		msg.Role = RoleAssistant
+	}
+	// some servers leave out the ids, the tool results must still refer to their call
+	for i := range msg.ToolCalls {
+		if msg.ToolCalls[i].ID == "" {
+			msg.ToolCalls[i].ID = fmt.Sprintf("call_%d", i)
+		}
+		if msg.ToolCalls[i].Type == "" {
+			msg.ToolCalls[i].Type = "function"
+		}
+	}
+	return msg, out.Usage, nil
+}
+
+// endpoint accepts base URLs with and without the /v1 suffix.
+func (c *Client) endpoint() string {
+	base := strings.TrimRight(c.BaseURL, "/")
+	if !strings.HasSuffix(base, "/v1") {
+		base += "/v1"
+	}
+	return base + "/chat/completions"
+}
@@ -0,0 +1,23 @@
+package llm
+
+// CharsPerToken is the rough ratio used to estimate token counts without a
+// tokenizer, close enough for English text and source code.
+const CharsPerToken = 4
+
+// EstimateTokens guesses the tokens s takes up in a prompt.
+func EstimateTokens(s string) int {
+	return (len(s) + CharsPerToken - 1) / CharsPerToken
+}
+
+// EstimateMessages guesses the prompt tokens of a conversation, counting a
+// few tokens of framing per message and the arguments of tool calls.
+func EstimateMessages(msgs []Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += 4 + EstimateTokens(m.Content)
+		for _, c := range m.ToolCalls {
+			n += EstimateTokens(c.Function.Name) + EstimateTokens(c.Function.Arguments)
+		}
+	}
+	return n
+}
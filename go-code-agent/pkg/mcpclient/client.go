@@ -0,0 +1,237 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/go-code-agent/pkg/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectTimeout bounds starting a server and listing its tools
+const connectTimeout = 30 * time.Second
+
+// maxToolName is the longest function name chat completion APIs accept
+const maxToolName = 64
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Servers holds the sessions of the connected MCP servers.
+type Servers struct {
+	sessions map[string]*mcp.ClientSession
+	cancels  []context.CancelFunc
+}
+
+// Connect starts or dials every enabled server of cfg and registers its
+// tools in ts as "<server>_<tool>". A server that fails is skipped, the
+// returned error lists the failures next to the servers that did connect.
+// A tool whose name is already taken, by a built-in tool or another
+// server's, is skipped and reported in the error as well.
+func Connect(ctx context.Context, cfg Config, ts *tools.Toolset) (*Servers, error) {
+	s := &Servers{sessions: map[string]*mcp.ClientSession{}}
+	client := mcp.NewClient(&mcp.Implementation{Name: "go-code-agent", Version: "1.0.0"}, nil)
+
+	names := make([]string, 0, len(cfg.Servers))
+	for name := range cfg.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		server := cfg.Servers[name]
+		if server.Disabled {
+			continue
+		}
+		if err := s.connect(ctx, client, name, server, ts); err != nil {
+			errs = append(errs, fmt.Errorf("MCP server %q: %w", name, err))
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+func (s *Servers) connect(ctx context.Context, client *mcp.Client, name string, cfg ServerConfig, ts *tools.Toolset) error {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return err
+	}
+	// the HTTP transports keep their stream open for as long as the connect
+	// context lives, so it is cancelled on a timeout instead of bounded by one
+	sessionCtx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(connectTimeout, cancel)
+
+	session, err := client.Connect(sessionCtx, transport, nil)
+	if err != nil {
+		timer.Stop()
+		cancel()
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	var found []*mcp.Tool
+	for tool, err := range session.Tools(sessionCtx, nil) {
+		if err != nil {
+			timer.Stop()
+			session.Close()
+			cancel()
+			return fmt.Errorf("list tools: %w", err)
+		}
+		found = append(found, tool)
+	}
+	if !timer.Stop() {
+		session.Close()
+		return fmt.Errorf("no answer within %s", connectTimeout)
+	}
+	s.sessions[name] = session
+	s.cancels = append(s.cancels, cancel)
+
+	var conflicts []error
+	for _, t := range found {
+		tool := adapt(name, session, t, time.Duration(cfg.Timeout))
+		if ts.Has(tool.Name) {
+			conflicts = append(conflicts, fmt.Errorf("tool %q skipped: the name %s is already taken", t.Name, tool.Name))
+			continue
+		}
+		ts.Add(tool)
+	}
+	return errors.Join(conflicts...)
+}
+
+func newTransport(cfg ServerConfig) (mcp.Transport, error) {
+	switch cfg.Transport {
+	case TransportStdio:
+		cmd := exec.Command(cfg.Command, cfg.Args...)
+		cmd.Dir = cfg.Dir
+		cmd.Env = os.Environ()
+		for k, v := range cfg.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		// the server's logs would garble the terminal, stderr is discarded
+		cmd.Stderr = nil
+		return &mcp.CommandTransport{Command: cmd}, nil
+	case TransportSSE:
+		return &mcp.SSEClientTransport{Endpoint: cfg.URL}, nil
+	case TransportHTTP:
+		return &mcp.StreamableClientTransport{Endpoint: cfg.URL}, nil
+	}
+	return nil, fmt.Errorf("unknown transport %q", cfg.Transport)
+}
+
+// adapt turns an MCP tool into a toolset entry that calls it over session.
+func adapt(server string, session *mcp.ClientSession, t *mcp.Tool, timeout time.Duration) tools.Tool {
+	raw, params := schemas(t.InputSchema)
+	desc := t.Description
+	if desc == "" {
+		desc = t.Title
+	}
+	return tools.Tool{
+		Name:          ToolName(server, t.Name),
+		Description:   fmt.Sprintf("[%s] %s", server, desc),
+		Parameters:    params,
+		RawParameters: raw,
+		Timeout:       timeout,
+		Run: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: t.Name, Arguments: args})
+			if err != nil {
+				return "", err
+			}
+			out := resultText(res)
+			if res.IsError {
+				return "", errors.New(out)
+			}
+			return out, nil
+		},
+	}
+}
+
+// ToolName is the name a server's tool is registered under: prefixed by the
+// server and limited to the characters and length function names allow.
+// Different tools can map to the same name, Connect refuses the later ones.
+func ToolName(server, tool string) string {
+	name := invalidNameChars.ReplaceAllString(server+"_"+tool, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
+
+// schemas returns the server's input schema as sent to the model and the
+// part of it the toolset validates. A schema beyond the validator's subset
+// is passed on unchecked.
+func schemas(input any) (json.RawMessage, *tools.Schema) {
+	permissive := &tools.Schema{Type: "object"}
+	if input == nil {
+		return nil, permissive
+	}
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, permissive
+	}
+	var s tools.Schema
+	if err := json.Unmarshal(raw, &s); err != nil || s.Type != "object" {
+		return raw, permissive
+	}
+	return raw, &s
+}
+
+// resultText flattens the content of a tool result for the model.
+func resultText(res *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		switch c := c.(type) {
+		case *mcp.TextContent:
+			parts = append(parts, c.Text)
+		case *mcp.ImageContent:
+			parts = append(parts, fmt.Sprintf("[image %s, %d bytes]", c.MIMEType, len(c.Data)))
+		case *mcp.AudioContent:
+			parts = append(parts, fmt.Sprintf("[audio %s, %d bytes]", c.MIMEType, len(c.Data)))
+		case *mcp.ResourceLink:
+			parts = append(parts, fmt.Sprintf("[resource %s %s]", c.Name, c.URI))
+		case *mcp.EmbeddedResource:
+			if c.Resource != nil && c.Resource.Text != "" {
+				parts = append(parts, c.Resource.Text)
+			} else if c.Resource != nil {
+				parts = append(parts, fmt.Sprintf("[resource %s, %d bytes]", c.Resource.URI, len(c.Resource.Blob)))
+			}
+		}
+	}
+	if len(parts) == 0 && res.StructuredContent != nil {
+		if data, err := json.Marshal(res.StructuredContent); err == nil {
+			parts = append(parts, string(data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Names returns the connected servers.
+func (s *Servers) Names() []string {
+	names := make([]string, 0, len(s.sessions))
+	for name := range s.sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close ends every session, stdio servers are shut down.
+func (s *Servers) Close() error {
+	var errs []error
+	for name, session := range s.sessions {
+		if err := session.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close MCP server %q: %w", name, err))
+		}
+	}
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	s.sessions = map[string]*mcp.ClientSession{}
+	s.cancels = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,127 @@
+// Package mcpclient connects to Model Context Protocol servers and
+// registers their tools in a tools.Toolset next to the built-in ones.
+package mcpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Transports a server can be reached over.
+const (
+	TransportStdio = "stdio"
+	// TransportSSE is the HTTP+SSE transport of the 2024-11-05 spec.
+	TransportSSE = "sse"
+	// TransportHTTP is the streamable HTTP transport of newer specs.
+	TransportHTTP = "http"
+)
+
+// Config is the server file, in the layout other MCP clients use:
+//
+//	{
+//	  "mcpServers": {
+//	    "curl":   {"command": "mcp-curl", "args": [], "env": {"HTTP_PROXY": "..."}},
+//	    "remote": {"url": "http://localhost:8081/sse", "timeout": "2m"}
+//	  }
+//	}
+type Config struct {
+	Servers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// ServerConfig describes one server, either a command speaking MCP on
+// stdin/stdout or a URL.
+type ServerConfig struct {
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	// Dir is the working directory of the command.
+	Dir string `json:"dir,omitempty"`
+
+	URL string `json:"url,omitempty"`
+	// Transport is stdio, sse or http. It defaults to stdio for commands and,
+	// for URLs, to sse when the path ends in /sse and http otherwise.
+	Transport string `json:"transport,omitempty"`
+
+	// Timeout bounds a tool call, e.g. "90s". Empty uses the toolset default.
+	Timeout  Duration `json:"timeout,omitempty"`
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "30s" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig reads the server file at path. A missing file is an empty
+// config when optional is set, so the agent runs without MCP servers.
+func LoadConfig(path string, optional bool) (Config, error) {
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("read MCP config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parse MCP config %s: %w", path, err)
+	}
+	for name, s := range cfg.Servers {
+		transport, err := s.transport()
+		if err != nil {
+			return Config{}, fmt.Errorf("MCP server %q: %w", name, err)
+		}
+		s.Transport = transport
+		cfg.Servers[name] = s
+	}
+	return cfg, nil
+}
+
+func (s ServerConfig) transport() (string, error) {
+	switch {
+	case s.Command != "" && s.URL != "":
+		return "", errors.New("set either command or url, not both")
+	case s.Command == "" && s.URL == "":
+		return "", errors.New("command or url is required")
+	}
+	switch s.Transport {
+	case "":
+		if s.Command != "" {
+			return TransportStdio, nil
+		}
+		if strings.HasSuffix(strings.TrimRight(s.URL, "/"), "/sse") {
+			return TransportSSE, nil
+		}
+		return TransportHTTP, nil
+	case TransportStdio:
+		if s.Command == "" {
+			return "", errors.New("stdio transport needs a command")
+		}
+	case TransportSSE, TransportHTTP:
+		if s.URL == "" {
+			return "", fmt.Errorf("%s transport needs a url", s.Transport)
+		}
+	default:
+		return "", fmt.Errorf("unknown transport %q, use stdio, sse or http", s.Transport)
+	}
+	return s.Transport, nil
+}
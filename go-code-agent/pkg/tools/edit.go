@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReplaceInFile replaces oldText with newText in a file. oldText must occur
+// exactly once unless all is set, so an ambiguous edit is refused instead of
+// applied to the wrong place.
+func (w *Workspace) ReplaceInFile(ctx context.Context, path, oldText, newText string, all bool) (string, error) {
+	if oldText == "" {
+		return "", fmt.Errorf("old_text is required")
+	}
+	content, err := w.ReadFileContent(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	n := strings.Count(content, oldText)
+	switch {
+	case n == 0:
+		return "", fmt.Errorf("old_text not found in %s, read the file again and copy the text exactly", path)
+	case n > 1 && !all:
+		return "", fmt.Errorf("old_text occurs %d times in %s, add surrounding lines to make it unique or set replace_all", n, path)
+	}
+	count := 1
+	if all {
+		count = n
+	}
+	if note, err := w.update(ctx, path, strings.Replace(content, oldText, newText, count)); err != nil || note != "" {
+		return note, err
+	}
+	return fmt.Sprintf("replaced %d occurrence(s) in %s", count, path), nil
+}
+
+// ReplaceLines replaces the lines start..end (1-based, inclusive) of a file
+// with text. end = start-1 inserts text before line start without removing anything.
+func (w *Workspace) ReplaceLines(ctx context.Context, path string, start, end int, text string) (string, error) {
+	content, err := w.ReadFileContent(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if start < 1 || start > len(lines)+1 {
+		return "", fmt.Errorf("start_line %d out of range, %s has %d lines", start, path, len(lines))
+	}
+	if end < start-1 || end > len(lines) {
+		return "", fmt.Errorf("end_line %d out of range for start_line %d, %s has %d lines", end, start, path, len(lines))
+	}
+	// keep the line structure: the new text ends its last line unless it
+	// replaces the last line of a file without a final newline
+	if text != "" && !strings.HasSuffix(text, "\n") && (end < len(lines) || strings.HasSuffix(content, "\n")) {
+		text += "\n"
+	}
+
+	var sb strings.Builder
+	for _, l := range lines[:start-1] {
+		sb.WriteString(l)
+	}
+	sb.WriteString(text)
+	for _, l := range lines[end:] {
+		sb.WriteString(l)
+	}
+	if note, err := w.update(ctx, path, sb.String()); err != nil || note != "" {
+		return note, err
+	}
+	return fmt.Sprintf("replaced lines %d-%d of %s with %d line(s)", start, end, path, strings.Count(text, "\n")), nil
+}
+
+// update writes the edited content of an existing file, the note is the
+// tool result when the edit was not applied as is: a dry run or no change.
+func (w *Workspace) update(ctx context.Context, path, content string) (string, error) {
+	abs, err := w.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	c, applied, err := w.write(ctx, abs, content)
+	switch {
+	case err != nil:
+		return "", err
+	case c.Diff == "":
+		return fmt.Sprintf("the edit leaves %s unchanged", c.Path), nil
+	case !applied:
+		return dryRunNote(c), nil
+	}
+	return "", nil
+}
+
+// ReadFileLines returns the lines start..end of a file prefixed with their
+// numbers, the view ReplaceLines works on. end <= 0 reads to the end.
+func (w *Workspace) ReadFileLines(ctx context.Context, path string, start, end int) (string, error) {
+	abs, err := w.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	start = max(start, 1)
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&sb, "%6d\t%s\n", i, lines[i-1])
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultCommandTimeout = 60 * time.Second
+	DefaultMaxOutput      = 16 * 1024
+)
+
+// DefaultAllowedCommands are the executables run_command may start when
+// nothing else is configured: build, test and inspect.
+var DefaultAllowedCommands = []string{"go", "gofmt", "git", "make", "ls"}
+
+// Runner executes allow-listed commands in the workspace root. Commands are
+// started directly, not through a shell, so pipes, redirects and
+// substitutions in the arguments have no effect.
+//
+// Runner is not a sandbox: the allow-list decides which program starts, not
+// what it does. The arguments are not confined to the workspace, so ls /
+// or git -C /etc read outside it, and go run or make run arbitrary code
+// with the agent's permissions. Only allow commands you would let the
+// model run in your shell.
+type Runner struct {
+	Workspace *Workspace
+	// Allowed are the executable names that may be run.
+	Allowed []string
+	// Timeout bounds a command, 0 uses DefaultCommandTimeout.
+	Timeout time.Duration
+	// MaxOutput caps the bytes of output returned, 0 uses DefaultMaxOutput.
+	MaxOutput int
+}
+
+// Run starts name with args and returns the exit status and combined output,
+// a failing command is a result for the model, not an error.
+func (r *Runner) Run(ctx context.Context, name string, args []string) (string, error) {
+	if !slices.Contains(r.Allowed, name) {
+		return "", fmt.Errorf("command %q is not allowed, allowed: %s", name, strings.Join(r.Allowed, ", "))
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = r.Workspace.Root()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	status := "exit 0"
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = fmt.Sprintf("killed after %s", timeout)
+	case errors.As(err, &exitErr):
+		status = fmt.Sprintf("exit %d", exitErr.ExitCode())
+	case err != nil:
+		return "", fmt.Errorf("run %s: %w", name, err)
+	}
+
+	maxOutput := r.MaxOutput
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutput
+	}
+	return fmt.Sprintf("$ %s\n[%s]\n%s", strings.Join(append([]string{name}, args...), " "), status,
+		Truncate(out.String(), maxOutput)), nil
+}
+
+// Truncate keeps the head and the tail of s within limit bytes, where the
+// interesting parts of build and test output usually are.
+func Truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	head := limit * 2 / 3
+	tail := limit - head
+	return fmt.Sprintf("%s\n[... %d bytes truncated ...]\n%s",
+		strings.ToValidUTF8(s[:head], ""), len(s)-limit, strings.ToValidUTF8(s[len(s)-tail:], ""))
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultGrepMatches = 100
+	// maxGrepLine caps the characters shown of a matching line
+	maxGrepLine = 200
+	// binarySniff is how many leading bytes are checked for NUL to skip binary files
+	binarySniff = 8000
+)
+
+var errGrepLimit = errors.New("match limit reached")
+
+// Grep searches the text files under dir for the regular expression pattern
+// and returns "path:line: text" for every matching line, at most maxMatches.
+// glob, when set, filters the files by base name, e.g. "*.go".
+func (w *Workspace) Grep(ctx context.Context, pattern, dir, glob string, maxMatches int) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	if glob != "" {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob: %w", err)
+		}
+	}
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := w.Resolve(dir)
+	if err != nil {
+		return "", err
+	}
+	if maxMatches <= 0 {
+		maxMatches = defaultGrepMatches
+	}
+
+	var sb strings.Builder
+	matches := 0
+	err = w.walk(abs, func(path string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if glob != "" {
+			if ok, _ := filepath.Match(glob, d.Name()); !ok {
+				return nil
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data[:min(len(data), binarySniff)], 0) >= 0 {
+			return nil
+		}
+
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			text := sc.Text()
+			if !re.MatchString(text) {
+				continue
+			}
+			if runes := []rune(text); len(runes) > maxGrepLine {
+				text = string(runes[:maxGrepLine]) + "…"
+			}
+			fmt.Fprintf(&sb, "%s:%d: %s\n", w.Rel(path), line, text)
+			matches++
+			if matches >= maxMatches {
+				return errGrepLimit
+			}
+		}
+		return nil
+	})
+	switch {
+	case err == errGrepLimit:
+		fmt.Fprintf(&sb, "[stopped after %d matches, narrow the pattern or the directory]\n", maxMatches)
+	case err != nil:
+		return "", fmt.Errorf("grep: %w", err)
+	case matches == 0:
+		return "no matches", nil
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrRejected is returned when the user declines a change.
+var ErrRejected = errors.New("change rejected by the user")
+
+// Change is a file write proposed by a tool.
+type Change struct {
+	// Path is relative to the workspace root.
+	Path string
+	// Existed is false when the write creates the file.
+	Existed bool
+	Before  string
+	After   string
+	Diff    string
+	Time    time.Time
+}
+
+// Approver decides whether a change is applied, it sees the change with its diff.
+// It has to return once ctx is done, the tool call is abandoned by then.
+type Approver func(ctx context.Context, c Change) (bool, error)
+
+// Journal records the changes applied in a session, newest last, so they can be undone.
+type Journal struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+// List returns the recorded changes, oldest first.
+func (j *Journal) List() []Change {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Change(nil), j.changes...)
+}
+
+func (j *Journal) record(c Change) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.changes = append(j.changes, c)
+}
+
+func (j *Journal) last() (Change, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.changes) == 0 {
+		return Change{}, false
+	}
+	return j.changes[len(j.changes)-1], true
+}
+
+func (j *Journal) pop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.changes = j.changes[:len(j.changes)-1]
+}
+
+// write applies a change to abs after approval and records it in the journal.
+// It returns the change and whether it was written: in dry-run mode the
+// diff is produced but the file is left alone.
+func (w *Workspace) write(ctx context.Context, abs, content string) (Change, bool, error) {
+	c := Change{Path: w.Rel(abs), After: content, Time: time.Now()}
+	data, err := os.ReadFile(abs)
+	switch {
+	case err == nil:
+		c.Existed = true
+		c.Before = string(data)
+	case !errors.Is(err, os.ErrNotExist):
+		return c, false, fmt.Errorf("read %s: %w", c.Path, err)
+	}
+	c.Diff = UnifiedDiff(c.Path, c.Before, c.After)
+	if c.Existed && c.Diff == "" {
+		return c, false, nil
+	}
+
+	if w.Approve != nil {
+		ok, err := w.Approve(ctx, c)
+		if err != nil {
+			return c, false, err
+		}
+		if !ok {
+			return c, false, fmt.Errorf("%s: %w", c.Path, ErrRejected)
+		}
+		// the call may have timed out while the person was deciding
+		if err := ctx.Err(); err != nil {
+			return c, false, err
+		}
+	}
+	if w.DryRun {
+		return c, false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return c, false, fmt.Errorf("mkdir: %w", err)
+	}
+	if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+		return c, false, fmt.Errorf("write %s: %w", c.Path, err)
+	}
+	w.Journal.record(c)
+	return c, true, nil
+}
+
+// Undo restores the file of the newest journal entry to its content before
+// the change, or removes it when the change created it. A file edited since
+// the change is left alone.
+func (w *Workspace) Undo() (Change, error) {
+	c, ok := w.Journal.last()
+	if !ok {
+		return Change{}, errors.New("nothing to undo")
+	}
+	abs, err := w.Resolve(c.Path)
+	if err != nil {
+		return c, err
+	}
+	current, err := os.ReadFile(abs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return c, fmt.Errorf("read %s: %w", c.Path, err)
+	}
+	if string(current) != c.After {
+		return c, fmt.Errorf("%s was modified after the change, not undoing it", c.Path)
+	}
+
+	if c.Existed {
+		err = os.WriteFile(abs, []byte(c.Before), 0o644)
+	} else {
+		err = os.Remove(abs)
+	}
+	if err != nil {
+		return c, fmt.Errorf("undo %s: %w", c.Path, err)
+	}
+	w.Journal.pop()
+	return c, nil
+}
+
+// dryRunNote is the tool result of a write that was only previewed.
+func dryRunNote(c Change) string {
+	return fmt.Sprintf("dry run: %s was not written, the change would be:\n%s", c.Path, c.Diff)
+}
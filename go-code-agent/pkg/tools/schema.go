@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema used to describe tool arguments.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	// AdditionalProperties false rejects arguments not listed in Properties.
+	AdditionalProperties *bool    `json:"additionalProperties,omitempty"`
+	Minimum              *float64 `json:"minimum,omitempty"`
+}
+
+// Object describes an argument object, extra properties are rejected.
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	closed := false
+	if properties == nil {
+		properties = map[string]*Schema{}
+	}
+	return &Schema{Type: "object", Properties: properties, Required: required, AdditionalProperties: &closed}
+}
+
+// String describes a string argument.
+func String(desc string) *Schema { return &Schema{Type: "string", Description: desc} }
+
+// Integer describes an integer argument.
+func Integer(desc string) *Schema { return &Schema{Type: "integer", Description: desc} }
+
+// Boolean describes a boolean argument.
+func Boolean(desc string) *Schema { return &Schema{Type: "boolean", Description: desc} }
+
+// Array describes a list argument whose elements match items.
+func Array(desc string, items *Schema) *Schema {
+	return &Schema{Type: "array", Description: desc, Items: items}
+}
+
+// Validate checks a decoded JSON value against the schema, the error names
+// the offending argument so the model can correct its call.
+func (s *Schema) Validate(v any) error {
+	return s.validate("arguments", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if s == nil {
+		return nil
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object, got %s", path, jsonType(v))
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: unknown argument %q, expected one of %s", path, name, strings.Join(s.propertyNames(), ", "))
+				}
+				continue
+			}
+			if err := prop.validate(name, obj[name]); err != nil {
+				return err
+			}
+		}
+	case "array":
+		list, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array, got %s", path, jsonType(v))
+		}
+		for i, item := range list {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: want string, got %s", path, jsonType(v))
+		}
+	case "integer", "number":
+		f, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s: want %s, got %s", path, s.Type, jsonType(v))
+		}
+		if s.Type == "integer" && f != math.Trunc(f) {
+			return fmt.Errorf("%s: want integer, got %v", path, f)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: must be >= %v", path, *s.Minimum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %s", path, jsonType(v))
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, s.Enum)
+	}
+	return nil
+}
+
+// inEnum compares scalars only, objects and arrays never match
+func inEnum(enum []any, v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	return slices.Contains(enum, v)
+}
+
+func (s *Schema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -3,140 +3,193 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/agenticgokit/agenticgokit/v1beta"
+	"github.com/example/go-code-agent/pkg/llm"
 )
 
+// DefaultTimeout bounds a tool call when neither the tool nor the set sets a timeout.
+const DefaultTimeout = 30 * time.Second
+
 // Func is the generic signature every registered tool is adapted to.
+// args have been validated against the tool's Parameters before the call.
 type Func func(ctx context.Context, args map[string]interface{}) (string, error)
 
-type entry struct {
-	info v1beta.ToolInfo
-	fn   Func
+// Tool is one function the model can call.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments, nil for none.
+	Parameters *Schema
+	// RawParameters, when set, is sent to the model instead of Parameters,
+	// for schemas defined elsewhere such as by an MCP server. Parameters
+	// then only validates the arguments.
+	RawParameters json.RawMessage
+	// Timeout bounds a single call, 0 uses the set's Timeout.
+	Timeout time.Duration
+	Run     Func
 }
 
-// Toolset collects tools and builds a v1beta handler that runs the call loop.
+// Toolset collects tools, describes them to the model and dispatches its calls.
 type Toolset struct {
-	system  string
-	entries map[string]entry
-	order   []string
+	system string
+	// Timeout is the default per-call timeout.
+	Timeout time.Duration
+	// MaxOutput caps the bytes of a tool result handed to the model so a
+	// large listing or file cannot crowd the context window, 0 keeps it whole.
+	MaxOutput int
+	entries   map[string]Tool
+	order     []string
 }
 
 func NewToolset(systemPrompt string) *Toolset {
-	return &Toolset{system: systemPrompt, entries: map[string]entry{}}
+	return &Toolset{system: systemPrompt, Timeout: DefaultTimeout, entries: map[string]Tool{}}
 }
 
-// Add registers one tool. Returns the set for chaining.
-func (t *Toolset) Add(name, desc string, params map[string]interface{}, fn Func) *Toolset {
-	t.entries[name] = entry{info: v1beta.ToolInfo{Name: name, Description: desc, Parameters: params}, fn: fn}
-	t.order = append(t.order, name)
-	return t
+// SystemPrompt is the prompt the set was created with.
+func (t *Toolset) SystemPrompt() string { return t.system }
+
+// Has reports whether a tool is registered under name.
+func (t *Toolset) Has(name string) bool {
+	_, ok := t.entries[name]
+	return ok
 }
 
-// Handler returns a v1beta.HandlerFunc that lets the LLM call the registered tools.
-func (t *Toolset) Handler(maxIterations int) func(context.Context, string, *v1beta.Capabilities) (string, error) {
-	infos := make([]v1beta.ToolInfo, 0, len(t.order))
-	for _, n := range t.order {
-		infos = append(infos, t.entries[n].info)
+// Add registers one tool, replacing a tool of the same name. Returns the set for chaining.
+func (t *Toolset) Add(tool Tool) *Toolset {
+	if tool.Parameters == nil {
+		tool.Parameters = Object(nil)
 	}
-	prompt := t.system + v1beta.FormatToolsPromptForLLM(infos)
-
-	return func(ctx context.Context, input string, caps *v1beta.Capabilities) (string, error) {
-		conversation := input
-		for i := 0; i < maxIterations; i++ {
-			reply, err := caps.LLM(prompt, conversation)
-			if err != nil {
-				return "", fmt.Errorf("llm call failed: %w", err)
-			}
-			calls := v1beta.ParseLLMToolCalls(reply)
-			if len(calls) == 0 {
-				return reply, nil // final answer
-			}
+	if _, ok := t.entries[tool.Name]; !ok {
+		t.order = append(t.order, tool.Name)
+	}
+	t.entries[tool.Name] = tool
+	return t
+}
 
-			var sb strings.Builder
-			sb.WriteString(conversation)
-			sb.WriteString("\n\n[tool results]\n")
-			for _, call := range calls {
-				name, _ := call["name"].(string)
-				args, _ := call["args"].(map[string]interface{})
-				e, ok := t.entries[name]
-				if !ok {
-					fmt.Fprintf(&sb, "- %s: unknown tool\n", name)
-					continue
-				}
-				out, err := e.fn(ctx, args)
-				if err != nil {
-					fmt.Fprintf(&sb, "- %s error: %v\n", name, err)
-					continue
-				}
-				fmt.Fprintf(&sb, "- %s:\n%s\n", name, out)
+// Definitions returns the tools in registration order for the chat request.
+func (t *Toolset) Definitions() []llm.Tool {
+	defs := make([]llm.Tool, 0, len(t.order))
+	for _, n := range t.order {
+		e := t.entries[n]
+		params := e.RawParameters
+		if params == nil {
+			var err error
+			if params, err = json.Marshal(e.Parameters); err != nil {
+				// a Schema always encodes, a failure is a programming error
+				panic(fmt.Sprintf("tool %s: encode schema: %v", n, err))
 			}
-			conversation = sb.String()
 		}
-		return caps.LLM(prompt, conversation+"\n\nProvide your final answer now without requesting more tools.")
+		defs = append(defs, llm.Tool{
+			Type:     "function",
+			Function: llm.Function{Name: n, Description: e.Description, Parameters: params},
+		})
 	}
+	return defs
 }
 
-// Arg is a small helper to read a string argument.
-func Arg(args map[string]interface{}, key string) string {
-	if v, ok := args[key]; ok {
-		return fmt.Sprintf("%v", v)
-	}
-	return ""
-}
-// SearchWorkspaceFiles walks the current working directory and returns all relative file paths.
-func SearchWorkspaceFiles(_ context.Context) (string, error) {
-	root, err := os.Getwd()
-	if err != nil {
-		return "", fmt.Errorf("getwd: %w", err)
+// Call validates the arguments of a tool call, runs the tool under its
+// timeout and returns the tool message answering the call. Failures are
+// reported to the model in the message rather than returned, so it can
+// correct the call.
+func (t *Toolset) Call(ctx context.Context, call llm.ToolCall) llm.Message {
+	msg := llm.Message{Role: llm.RoleTool, ToolCallID: call.ID, Name: call.Function.Name}
+
+	e, ok := t.entries[call.Function.Name]
+	if !ok {
+		msg.Content = fmt.Sprintf("error: unknown tool %q, available: %s", call.Function.Name, strings.Join(t.order, ", "))
+		return msg
 	}
-	var paths []string
-	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
+
+	args := map[string]interface{}{}
+	if raw := strings.TrimSpace(call.Function.Arguments); raw != "" {
+		var v any
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			msg.Content = fmt.Sprintf("error: arguments are not valid JSON: %v", err)
+			return msg
 		}
-		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
-			return filepath.SkipDir
+		obj, ok := v.(map[string]interface{})
+		if !ok && v != nil {
+			msg.Content = "error: arguments must be a JSON object"
+			return msg
 		}
-		if !d.IsDir() {
-			rel, _ := filepath.Rel(root, path)
-			paths = append(paths, rel)
+		if obj != nil {
+			args = obj
 		}
-		return nil
-	})
-	if err != nil {
-		return "", fmt.Errorf("walk: %w", err)
 	}
-	return strings.Join(paths, "\n"), nil
-}
+	if err := e.Parameters.Validate(args); err != nil {
+		msg.Content = fmt.Sprintf("error: invalid arguments: %v", err)
+		return msg
+	}
 
-// ReadFileContent reads and returns the full contents of a file.
-func ReadFileContent(_ context.Context, path string) (string, error) {
-	if path == "" {
-		return "", fmt.Errorf("path is required")
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = t.Timeout
 	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("read %s: %w", path, err)
+	if timeout <= 0 {
+		timeout = DefaultTimeout
 	}
-	return string(data), nil
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the tool runs aside so a tool ignoring ctx still cannot stall the loop
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := e.Run(callCtx, args)
+		done <- result{out, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			msg.Content = fmt.Sprintf("error: %v", r.err)
+		} else {
+			msg.Content = t.truncate(r.out)
+		}
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			msg.Content = fmt.Sprintf("error: %s cancelled", e.Name)
+		} else {
+			msg.Content = fmt.Sprintf("error: %s timed out after %s", e.Name, timeout)
+		}
+	}
+	return msg
 }
 
-// WriteFileContent creates parent directories as needed and writes content to path.
-func WriteFileContent(_ context.Context, path, content string) (string, error) {
-	if path == "" {
-		return "", fmt.Errorf("path is required")
+// truncate shortens out to MaxOutput and tells the model how to get the rest.
+func (t *Toolset) truncate(out string) string {
+	if t.MaxOutput <= 0 || len(out) <= t.MaxOutput {
+		return out
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return "", fmt.Errorf("mkdir: %w", err)
+	return Truncate(out, t.MaxOutput) + "\n[output truncated to fit the context window, " +
+		"narrow the call with a path, glob or line range]"
+}
+
+// Arg is a small helper to read a string argument.
+func Arg(args map[string]interface{}, key string) string {
+	if v, ok := args[key]; ok {
+		return fmt.Sprintf("%v", v)
 	}
-	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
-		return "", fmt.Errorf("write %s: %w", path, err)
+	return ""
+}
+
+// IntArg reads an integer argument, fallback when it is absent.
+func IntArg(args map[string]interface{}, key string, fallback int) int {
+	if v, ok := args[key].(float64); ok {
+		return int(v)
 	}
-	return fmt.Sprintf("wrote %d bytes to %s", len(content), path), nil
+	return fallback
 }
 
+// BoolArg reads a boolean argument, false when it is absent.
+func BoolArg(args map[string]interface{}, key string) bool {
+	v, _ := args[key].(bool)
+	return v
+}
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace confines the file tools to a root directory. Every path the
+// model passes is resolved against the root, and rejected when it, or a
+// symlink along it, leads outside. Writes go through Approve and are
+// recorded in Journal so they can be undone.
+type Workspace struct {
+	root string
+	// Approve is asked before every write, nil applies writes without asking.
+	Approve Approver
+	// DryRun previews writes as diffs without touching the files.
+	DryRun  bool
+	Journal *Journal
+}
+
+// NewWorkspace returns a workspace rooted at dir, symlinks in dir itself are resolved.
+func NewWorkspace(dir string) (*Workspace, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("workspace root: %w", err)
+	}
+	root, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("workspace root: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("workspace root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("workspace root %s is not a directory", root)
+	}
+	return &Workspace{root: root, Journal: &Journal{}}, nil
+}
+
+// Root is the absolute, symlink-free workspace directory.
+func (w *Workspace) Root() string { return w.root }
+
+// Resolve maps a path given by the model to an absolute path inside the
+// workspace. Relative paths are taken from the root; absolute paths must lie
+// under it. The existing part of the path is resolved through its symlinks,
+// dangling ones included, so a link pointing out of the workspace is
+// refused as well.
+func (w *Workspace) Resolve(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("path is required")
+	}
+	abs := filepath.Clean(path)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(w.root, abs)
+	}
+	if !within(w.root, abs) {
+		return "", fmt.Errorf("%s is outside the workspace %s", path, w.root)
+	}
+	real, err := evalExisting(abs)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", path, err)
+	}
+	if !within(w.root, real) {
+		return "", fmt.Errorf("%s leads outside the workspace through a symlink", path)
+	}
+	return real, nil
+}
+
+// Rel returns path relative to the root, for output shown to the model.
+func (w *Workspace) Rel(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// maxLinks bounds the dangling symlinks followed by evalExisting, like the
+// kernel's limit on nested links.
+const maxLinks = 40
+
+// evalExisting resolves the symlinks of the longest existing prefix of path
+// and appends the part that does not exist yet. A dangling symlink exists
+// but is not resolved by EvalSymlinks, it is followed by hand: writing
+// through it would create its target.
+func evalExisting(path string) (string, error) {
+	var missing []string
+	links := 0
+	for p := path; ; {
+		real, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{real}, missing...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if info, err := os.Lstat(p); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			if links++; links > maxLinks {
+				return "", fmt.Errorf("%s: too many levels of symbolic links", path)
+			}
+			target, err := os.Readlink(p)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(p), target)
+			}
+			p = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return path, nil
+		}
+		missing = append([]string{filepath.Base(p)}, missing...)
+		p = parent
+	}
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// walk visits the files under dir, hidden directories are skipped and
+// symlinks are not followed.
+func (w *Workspace) walk(dir string, fn func(path string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		return fn(path, d)
+	})
+}
+
+// SearchWorkspaceFiles walks dir, the workspace root when empty, and returns
+// the relative paths of its files. glob, when set, filters them by base
+// name, e.g. "*_test.go".
+func (w *Workspace) SearchWorkspaceFiles(ctx context.Context, dir, glob string) (string, error) {
+	if glob != "" {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob: %w", err)
+		}
+	}
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := w.Resolve(dir)
+	if err != nil {
+		return "", err
+	}
+	var paths []string
+	err = w.walk(abs, func(path string, d fs.DirEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if glob != "" {
+			if ok, _ := filepath.Match(glob, d.Name()); !ok {
+				return nil
+			}
+		}
+		paths = append(paths, w.Rel(path))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("walk: %w", err)
+	}
+	if len(paths) == 0 {
+		return "no files found", nil
+	}
+	return strings.Join(paths, "\n"), nil
+}
+
+// ReadFileContent reads and returns the full contents of a file.
+func (w *Workspace) ReadFileContent(_ context.Context, path string) (string, error) {
+	abs, err := w.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// WriteFileContent creates parent directories as needed and writes content to path.
+func (w *Workspace) WriteFileContent(ctx context.Context, path, content string) (string, error) {
+	abs, err := w.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	c, applied, err := w.write(ctx, abs, content)
+	switch {
+	case err != nil:
+		return "", err
+	case c.Existed && c.Diff == "":
+		return fmt.Sprintf("%s already has this content, nothing written", c.Path), nil
+	case !applied:
+		return dryRunNote(c), nil
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), c.Path), nil
+}
+
+// ListDirectory lists the entries of a directory with their sizes,
+// directories first. A directory's size is the total of the files below it.
+func (w *Workspace) ListDirectory(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	abs, err := w.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", fmt.Errorf("list %s: %w", path, err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s/\n", w.Rel(abs))
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		full := filepath.Join(abs, e.Name())
+		switch {
+		case e.Type()&fs.ModeSymlink != 0:
+			target, _ := os.Readlink(full)
+			fmt.Fprintf(&sb, "  %-10s %s -> %s\n", "link", e.Name(), target)
+		case e.IsDir():
+			var size int64
+			files := 0
+			_ = w.walk(full, func(_ string, d fs.DirEntry) error {
+				if info, err := d.Info(); err == nil {
+					size += info.Size()
+				}
+				files++
+				return nil
+			})
+			fmt.Fprintf(&sb, "  %-10s %s/ (%d files)\n", humanSize(size), e.Name(), files)
+		default:
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&sb, "  %-10s %s\n", humanSize(info.Size()), e.Name())
+		}
+	}
+	return sb.String(), nil
+}
+
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
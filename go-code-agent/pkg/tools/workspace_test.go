@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSymlinks(t *testing.T) {
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(tmp, "ws")
+	outside := filepath.Join(tmp, "outside")
+	for _, dir := range []string{filepath.Join(root, "src"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"dangling":       filepath.Join(outside, "pwned.txt"),
+		"dangling-rel":   "../outside/pwned.txt",
+		"outdir":         outside,
+		"dangling-dir":   filepath.Join(outside, "missing"),
+		"chain":          "dangling",
+		"inside":         "src/new.go",
+		"inside-dir":     "src",
+		"loop":           "loop",
+		"src/parent-rel": "../../outside/x",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := NewWorkspace(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		"dangling",
+		"dangling-rel",
+		"outdir/file.txt",
+		"dangling-dir/file.txt",
+		"dangling-dir/sub/file.txt",
+		"chain",
+		"src/parent-rel",
+		"../outside/file.txt",
+		filepath.Join(outside, "file.txt"),
+	} {
+		if got, err := w.Resolve(path); err == nil {
+			t.Errorf("Resolve(%q) = %s, want an error", path, got)
+		}
+	}
+	if _, err := w.Resolve("loop"); err == nil {
+		t.Error("Resolve(loop) should fail")
+	}
+
+	for path, want := range map[string]string{
+		"src/main.go":            filepath.Join(root, "src", "main.go"),
+		"inside":                 filepath.Join(root, "src", "new.go"),
+		"inside-dir/a/b.go":      filepath.Join(root, "src", "a", "b.go"),
+		filepath.Join(root, "x"): filepath.Join(root, "x"),
+	} {
+		got, err := w.Resolve(path)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %s, %v, want %s", path, got, err, want)
+		}
+	}
+
+	// writing through a dangling link must not create its target
+	if _, err := w.WriteFileContent(context.Background(), "dangling", "x"); err == nil {
+		t.Error("write through a dangling link should fail")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "pwned.txt")); !os.IsNotExist(err) {
+		t.Errorf("file created outside the workspace: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package transcript saves a conversation as JSONL, one chat message per
+// line, so an agent session can be resumed later.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/example/go-code-agent/pkg/llm"
+)
+
+// maxLine bounds a single message, large tool results included
+const maxLine = 16 * 1024 * 1024
+
+// Load reads the messages of the transcript at path. A last line cut short,
+// as left by a crash while writing, is ignored.
+func Load(path string) ([]llm.Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript: %w", err)
+	}
+	defer f.Close()
+
+	var msgs []llm.Message
+	var bad error
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for line := 1; sc.Scan(); line++ {
+		if bad != nil {
+			return nil, bad
+		}
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var m llm.Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			bad = fmt.Errorf("%s:%d: %w", path, line, err)
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	return complete(msgs), nil
+}
+
+// complete cuts off a trailing turn whose tool calls were not all answered,
+// the chat API rejects a conversation with calls lacking results.
+func complete(msgs []llm.Message) []llm.Message {
+	pending := map[string]bool{}
+	lastGood := 0
+	for i, m := range msgs {
+		for _, c := range m.ToolCalls {
+			pending[c.ID] = true
+		}
+		if m.Role == llm.RoleTool {
+			delete(pending, m.ToolCallID)
+		}
+		if len(pending) == 0 {
+			lastGood = i + 1
+		}
+	}
+	return msgs[:lastGood]
+}
+
+// Append adds msgs to the transcript at path, creating the file and its
+// directory as needed.
+func Append(path string, msgs []llm.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create transcript dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open transcript: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			f.Close()
+			return fmt.Errorf("write transcript: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	return f.Close()
+}
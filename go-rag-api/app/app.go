@@ -3,74 +3,231 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/dkr290/go-advanced-projects/go-rag-api/chat"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/chunk"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/config"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/server"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector/local"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/vector/pgvector"
 )
 
+// Run starts the watcher and the interactive chat REPL
 func Run(parentCtx context.Context, cfg config.Config) error {
 	// define some better logging
 	logger := log.New(os.Stderr, "[rag] ", log.LstdFlags)
 	ctx, cancel := context.WithCancel(parentCtx)
 	defer cancel()
 
-	client := llm.New(cfg)
-
-	store, err := openStore(ctx, cfg)
+	ingest, err := ingestOptions(cfg)
+	if err != nil {
+		return err
+	}
+	client, store, err := setup(ctx, cfg, logger)
 	if err != nil {
-		return fmt.Errorf("vector store disabled: %v", err)
+		return err
 	}
 	defer store.Close()
 
-	logger.Println("vector store ready")
-
-	logger.Printf("chat model=%q base_url=%q", cfg.ChatModel, cfg.ChatBaseURL)
-	logger.Printf("embedding model=%q base_url=%q", cfg.EmbeddingModel, cfg.EmbeddingBaseURL)
-
 	var wg sync.WaitGroup
-	if store != nil {
-		wg.Go(func() {
-			opts := chunk.Options{
-				SourceDir:    cfg.IngestDir,
-				ProcessedDir: cfg.ProcessedDir,
-			}
-			if err := chunk.Watch(
-				ctx,
-				opts,
-				client,
-				store,
-				logger,
-			); err != nil &&
-				ctx.Err() == nil {
-				logger.Printf("watcher stopped: %v", err)
-			}
-		})
-		logger.Printf("watching %s for new documents", cfg.IngestDir)
-	}
+	startWatcher(ctx, &wg, ingest, client, store, logger)
 
 	replErr := chat.RunREPL(ctx, client, chat.Options{
 		SystemPromptFile: cfg.SystemPromptFile,
+		Retriever:        newRetriever(cfg, client, client, store, logger),
+		HistoryTokens:    cfg.ChatHistoryTokens,
+		SessionDir:       cfg.ChatSessionDir,
+		Session:          cfg.ChatSession,
 	})
 	cancel()
 	wg.Wait()
 	return replErr
 }
 
-func openStore(ctx context.Context, cfg config.Config) (vector.Store, error) {
-	s, err := pgvector.New(ctx, pgvector.Options{
-		DSN:          cfg.DatabaseURL,
-		EmbeddingDim: cfg.EmbeddingDIM,
+// Serve starts the watcher and the HTTP API, it blocks until ctx is cancelled
+func Serve(parentCtx context.Context, cfg config.Config) error {
+	logger := log.New(os.Stderr, "[rag] ", log.LstdFlags)
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+
+	ingest, err := ingestOptions(cfg)
+	if err != nil {
+		return err
+	}
+	client, store, err := setup(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	systemPrompt, err := chat.SeedHistory(cfg.SystemPromptFile)
+	if err != nil {
+		return fmt.Errorf("initialize system prompt %w", err)
+	}
+
+	var wg sync.WaitGroup
+	startWatcher(ctx, &wg, ingest, client, store, logger)
+
+	srv := server.New(server.Options{
+		Addr:         cfg.ServerAddr,
+		Model:        cfg.ChatModel,
+		SystemPrompt: systemPrompt,
+		Ingest:       ingest,
+	}, client, client, store, newRetriever(cfg, client, client, store, logger), logger)
+
+	serveErr := srv.ListenAndServe(ctx)
+	cancel()
+	wg.Wait()
+	return serveErr
+}
+
+func setup(ctx context.Context, cfg config.Config, logger *log.Logger) (*llm.Client, vector.Store, error) {
+	switch cfg.RetrievalReranker {
+	case "", "llm":
+	default:
+		return nil, nil, fmt.Errorf("RETRIEVAL_RERANKER: unknown re-ranker %q", cfg.RetrievalReranker)
+	}
+	client := llm.New(cfg)
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("vector store disabled: %v", err)
+	}
+
+	logger.Println("vector store ready")
+
+	logger.Printf("chat model=%q base_url=%q", cfg.ChatModel, cfg.ChatBaseURL)
+	logger.Printf("embedding model=%q base_url=%q", cfg.EmbeddingModel, cfg.EmbeddingBaseURL)
+	logger.Printf("retrieval top_k=%d min_score=%.2f hybrid=%t reranker=%q",
+		cfg.RetrievalTopK, cfg.RetrievalMinScore, cfg.RetrievalHybrid, cfg.RetrievalReranker)
+	return client, store, nil
+}
+
+func startWatcher(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	opts chunk.Options,
+	embedder llm.Embedder,
+	store vector.Store,
+	logger *log.Logger,
+) {
+	wg.Go(func() {
+		if err := chunk.Watch(
+			ctx,
+			opts,
+			embedder,
+			store,
+			logger,
+		); err != nil &&
+			ctx.Err() == nil {
+			logger.Printf("watcher stopped: %v", err)
+		}
 	})
+	logger.Printf("watching %s for new documents", opts.SourceDir)
+}
+
+// ingestOptions builds the ingestion settings and opens the ledger,
+// the result is shared by the watcher and the upload endpoint
+func ingestOptions(cfg config.Config) (chunk.Options, error) {
+	strategies, err := chunk.ParseStrategies(cfg.ChunkStrategies)
+	if err != nil {
+		return chunk.Options{}, fmt.Errorf("CHUNK_STRATEGIES: %w", err)
+	}
+	ledger, err := chunk.OpenLedger(cfg.IngestLedger)
 	if err != nil {
-		return nil, err
+		return chunk.Options{}, err
 	}
+	return chunk.Options{
+		SourceDir:      cfg.IngestDir,
+		ProcessedDir:   cfg.ProcessedDir,
+		ChunkSize:      cfg.ChunkSize,
+		ChunkOverlap:   cfg.ChunkOverlap,
+		MaxTokens:      cfg.ChunkMaxTokens,
+		Strategies:     strategies,
+		Ledger:         ledger,
+		Workers:        cfg.IngestWorkers,
+		EmbedBatchSize: cfg.EmbedBatchSize,
+		EmbedRetries:   cfg.EmbedRetries,
+		KeepFiles:      cfg.IngestKeepFiles,
+	}, nil
+}
+
+// Status prints the ingest status of every file in the ledger,
+// with failedOnly just the files whose last attempt failed
+func Status(cfg config.Config, w io.Writer, failedOnly bool) error {
+	ledger, err := chunk.OpenLedger(cfg.IngestLedger)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tSTATUS\tCHUNKS\tEMBEDDED\tUPDATED\tERROR")
+	for _, e := range ledger.List() {
+		if failedOnly && e.Status != chunk.StatusFailed {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n",
+			e.Source,
+			e.Status,
+			len(e.Chunks),
+			e.Embedded,
+			e.UpdatedAt.Local().Format(time.DateTime),
+			e.Error,
+		)
+	}
+	return tw.Flush()
+}
+
+func newRetriever(
+	cfg config.Config,
+	embedder llm.Embedder,
+	chatter llm.Chatter,
+	store vector.Store,
+	logger *log.Logger,
+) *rag.Retriever {
+	r := &rag.Retriever{
+		Embedder:   embedder,
+		Store:      store,
+		TopK:       cfg.RetrievalTopK,
+		MinScore:   float32(cfg.RetrievalMinScore),
+		Hybrid:     cfg.RetrievalHybrid,
+		Candidates: cfg.RetrievalCandidates,
+		Logger:     logger,
+	}
+	if cfg.RetrievalReranker == "llm" {
+		r.Reranker = rag.LLMReranker{Chat: chatter}
+	}
+	return r
+}
+
+func openStore(ctx context.Context, cfg config.Config) (vector.Store, error) {
+	switch cfg.VectorStore {
+	case "local":
+		return local.New(local.Options{
+			Path:         cfg.LocalStorePath,
+			EmbeddingDim: cfg.EmbeddingDIM,
+			Metric:       local.Metric(cfg.VectorMetric),
+		})
+	case "pgvector":
+		s, err := pgvector.New(ctx, pgvector.Options{
+			DSN:          cfg.DatabaseURL,
+			EmbeddingDim: cfg.EmbeddingDIM,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	return s, nil
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown VECTOR_STORE %q, want pgvector or local", cfg.VectorStore)
+	}
 }
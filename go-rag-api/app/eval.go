@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/chat"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/chunk"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/config"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/eval"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector/local"
+)
+
+// EvalOptions are the settings of rag eval
+type EvalOptions struct {
+	// Dataset is the JSONL (or .json) file of questions and expected sources
+	Dataset string
+	// Docs, when set, is ingested into a throwaway local store with the
+	// configured chunking, instead of evaluating the configured store
+	Docs string
+	// K is the cut-off of the metrics, defaults to RETRIEVAL_TOP_K
+	K int
+	// Judge answers every question and has the chat model rate the answer's faithfulness
+	Judge bool
+	// Stub replaces the embedding and chat models by llm.Stub, it needs Docs
+	Stub bool
+	// JSON prints the report as JSON instead of a table
+	JSON bool
+}
+
+// Eval scores retrieval against a dataset and writes the report to w
+func Eval(ctx context.Context, cfg config.Config, opts EvalOptions, w io.Writer) error {
+	logger := log.New(os.Stderr, "[rag] ", log.LstdFlags)
+
+	cases, err := eval.LoadDataset(opts.Dataset)
+	if err != nil {
+		return err
+	}
+	if opts.Stub && opts.Docs == "" {
+		return errors.New("--stub needs --docs, the configured store holds embeddings of the configured model")
+	}
+
+	var (
+		embedder llm.Embedder
+		chatter  llm.Chatter
+		judge    llm.Chatter
+	)
+	if opts.Stub {
+		embedder = llm.Stub{Dim: cfg.EmbeddingDIM}
+		chatter = llm.Stub{}
+		// the stub judge always rates 1, it exercises the pipeline, not the model
+		judge = llm.Stub{Reply: "1"}
+	} else {
+		client := llm.New(cfg)
+		embedder, chatter, judge = client, client, client
+	}
+
+	var store vector.Store
+	if opts.Docs != "" {
+		store, err = ingestScratch(ctx, cfg, opts.Docs, embedder, logger)
+	} else {
+		store, err = openStore(ctx, cfg)
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	e := eval.Evaluator{
+		Retriever: newRetriever(cfg, embedder, chatter, store, logger),
+		K:         opts.K,
+	}
+	if e.K <= 0 {
+		e.K = cfg.RetrievalTopK
+	}
+	if opts.Judge {
+		systemPrompt, err := chat.SeedHistory(cfg.SystemPromptFile)
+		if err != nil {
+			return fmt.Errorf("initialize system prompt %w", err)
+		}
+		e.Chat, e.Judge, e.SystemPrompt = chatter, judge, systemPrompt
+	}
+
+	logger.Printf("evaluating %d cases k=%d hybrid=%t reranker=%q judge=%t",
+		len(cases), e.K, cfg.RetrievalHybrid, cfg.RetrievalReranker, opts.Judge)
+	report, err := e.Run(ctx, cases)
+	if err != nil {
+		return err
+	}
+	if opts.JSON {
+		return report.WriteJSON(w)
+	}
+	return report.WriteTable(w)
+}
+
+// ingestScratch ingests every supported file under dir into a local store
+// in a temporary directory, removed again when the store is closed
+func ingestScratch(
+	ctx context.Context,
+	cfg config.Config,
+	dir string,
+	embedder llm.Embedder,
+	logger *log.Logger,
+) (vector.Store, error) {
+	strategies, err := chunk.ParseStrategies(cfg.ChunkStrategies)
+	if err != nil {
+		return nil, fmt.Errorf("CHUNK_STRATEGIES: %w", err)
+	}
+	opts := chunk.Options{
+		ChunkSize:      cfg.ChunkSize,
+		ChunkOverlap:   cfg.ChunkOverlap,
+		MaxTokens:      cfg.ChunkMaxTokens,
+		Strategies:     strategies,
+		EmbedBatchSize: cfg.EmbedBatchSize,
+		EmbedRetries:   cfg.EmbedRetries,
+	}
+
+	tmp, err := os.MkdirTemp("", "rag-eval-")
+	if err != nil {
+		return nil, err
+	}
+	store, err := local.New(local.Options{
+		Path:         filepath.Join(tmp, "vectors.gob"),
+		EmbeddingDim: cfg.EmbeddingDIM,
+		Metric:       local.Metric(cfg.VectorMetric),
+	})
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+	scratch := scratchStore{Store: store, dir: tmp}
+
+	files, chunks := 0, 0
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !chunk.SupportedFormat(path) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		n, err := chunk.Ingest(ctx, rel, content, opts, embedder, store)
+		if err != nil {
+			return fmt.Errorf("ingest %s: %w", rel, err)
+		}
+		files++
+		chunks += n
+		return nil
+	})
+	if err != nil {
+		scratch.Close()
+		return nil, err
+	}
+	logger.Printf("ingested %d files into %d chunks from %s", files, chunks, dir)
+	return scratch, nil
+}
+
+// scratchStore removes the directory of a throwaway store on Close
+type scratchStore struct {
+	*local.Store
+	dir string
+}
+
+func (s scratchStore) Close() error {
+	return errors.Join(s.Store.Close(), os.RemoveAll(s.dir))
+}
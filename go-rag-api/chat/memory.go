@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/chunk"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+)
+
+const (
+	// messageOverhead approximates the tokens a message costs besides its content
+	messageOverhead = 4
+	// keepTurns is how many of the latest messages are never summarised
+	keepTurns = 2
+)
+
+const summaryPrompt = `You maintain the running summary of a conversation between a user and an assistant.
+Merge the previous summary and the new turns into one concise summary. Keep facts, names,
+numbers, decisions and open questions, drop greetings and repetition. Reply with the summary only.`
+
+const summaryPreamble = "Summary of the earlier conversation:\n"
+
+// Memory is the conversation sent to the model: the system prompt, a rolling
+// summary of the turns that no longer fit the token budget and the recent turns
+type Memory struct {
+	System  []llm.Message
+	Summary string
+	Turns   []llm.Message
+	// Budget is the token budget of the history, 0 keeps every turn
+	Budget int
+}
+
+// Messages returns the history to send before the next question
+func (m *Memory) Messages() []llm.Message {
+	messages := make([]llm.Message, 0, len(m.System)+len(m.Turns)+1)
+	messages = append(messages, m.System...)
+	if m.Summary != "" {
+		messages = append(messages, llm.Message{Role: "system", Content: summaryPreamble + m.Summary})
+	}
+	return append(messages, m.Turns...)
+}
+
+// Add records a question and its answer
+func (m *Memory) Add(question string, reply llm.Message) {
+	m.Turns = append(m.Turns, llm.Message{Role: "user", Content: question}, reply)
+}
+
+// Reset forgets the conversation, the system prompt stays
+func (m *Memory) Reset() {
+	m.Summary = ""
+	m.Turns = nil
+}
+
+// Tokens estimates the size of the history
+func (m *Memory) Tokens() int {
+	return countTokens(m.Messages())
+}
+
+// Fit brings the history back under Budget. Once it is exceeded the oldest
+// turns are folded into the summary by chatter until the history uses about
+// half of the budget, so summarisation does not run on every turn.
+// When chatter is nil or fails the old turns are dropped, the error is
+// returned for the caller to report
+func (m *Memory) Fit(ctx context.Context, chatter llm.Chatter) error {
+	if m.Budget <= 0 || m.Tokens() <= m.Budget {
+		return nil
+	}
+
+	target := m.Budget / 2
+	size := m.Tokens()
+	cut := 0
+	for cut < len(m.Turns)-keepTurns && size > target {
+		size -= messageTokens(m.Turns[cut])
+		cut++
+	}
+	// cut between turns, never between a question and its answer
+	if cut%2 == 1 && cut < len(m.Turns)-keepTurns {
+		cut++
+	}
+	if cut == 0 {
+		return nil
+	}
+
+	old := m.Turns[:cut]
+	m.Turns = append([]llm.Message(nil), m.Turns[cut:]...)
+	if chatter == nil {
+		return nil
+	}
+	summary, err := summarise(ctx, chatter, m.Summary, old)
+	if err != nil {
+		return fmt.Errorf("summarise %d messages, dropped them: %w", len(old), err)
+	}
+	m.Summary = summary
+	return nil
+}
+
+func summarise(ctx context.Context, chatter llm.Chatter, previous string, turns []llm.Message) (string, error) {
+	var sb strings.Builder
+	if previous != "" {
+		sb.WriteString("Previous summary:\n")
+		sb.WriteString(previous)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("New turns:\n")
+	for _, t := range turns {
+		fmt.Fprintf(&sb, "%s: %s\n", t.Role, t.Content)
+	}
+
+	reply, err := chatter.ChatStream(ctx, []llm.Message{
+		{Role: "system", Content: summaryPrompt},
+		{Role: "user", Content: sb.String()},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(reply.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
+
+func countTokens(messages []llm.Message) int {
+	n := 0
+	for _, msg := range messages {
+		n += messageTokens(msg)
+	}
+	return n
+}
+
+func messageTokens(msg llm.Message) int {
+	return messageOverhead + chunk.EstimateTokens(msg.Content)
+}
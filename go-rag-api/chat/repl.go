@@ -8,26 +8,76 @@ import (
 	"io/fs"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
 )
 
 type Options struct {
 	SystemPromptFile string
+	// Retriever grounds every answer in the document collection, nil disables retrieval
+	Retriever *rag.Retriever
+	// HistoryTokens is the token budget of the conversation history, older
+	// turns are summarised once it is exceeded. 0 keeps every turn
+	HistoryTokens int
+	// SessionDir holds the named sessions of /save and /load
+	SessionDir string
+	// Session resumes the named session, or starts it when it was never saved.
+	// A named session is saved after every answer
+	Session string
+}
+
+const helpText = `commands:
+  /reset          forget the conversation
+  /save [name]    save the conversation, later answers are saved too
+  /load [name]    resume a saved conversation, without name list them
+  /sources        sources of the last answer
+  /topk [n]       show or set how many excerpts are retrieved
+  /model [name]   show or switch the chat model
+  /help           this help
+  /exit, q        quit`
+
+type repl struct {
+	client    *llm.Client
+	retriever *rag.Retriever
+	memory    Memory
+	sessions  SessionStore
+	session   string
+	sources   []rag.Source
 }
 
 func RunREPL(ctx context.Context, client *llm.Client, opts Options) error {
 	in := bufio.NewScanner(os.Stdin)
 	in.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 
-	history, err := seedHistory(opts.SystemPromptFile)
+	history, err := SeedHistory(opts.SystemPromptFile)
 	if err != nil {
 		return fmt.Errorf("initialize chat history %w", err)
 	}
-	fmt.Println("Chat session started. Type Q/q to quit")
+	r := &repl{
+		client:   client,
+		memory:   Memory{System: history, Budget: opts.HistoryTokens},
+		sessions: SessionStore{Dir: opts.SessionDir},
+	}
+	if opts.Retriever != nil {
+		// /topk changes the copy, not the caller's retriever
+		retriever := *opts.Retriever
+		r.retriever = &retriever
+	}
+	if opts.Session != "" {
+		if err := r.load(opts.Session); errors.Is(err, ErrNoSession) {
+			r.session = opts.Session
+			fmt.Printf("New session %q.\n", opts.Session)
+		} else if err != nil {
+			return err
+		}
+	}
+	fmt.Println("Chat session started. Type Q/q to quit, /help for commands")
 
 	for {
 		fmt.Print("\n> ")
@@ -47,29 +97,195 @@ func RunREPL(ctx context.Context, client *llm.Client, opts Options) error {
 			fmt.Println("GoodBye.")
 			return nil
 		}
+		if strings.HasPrefix(input, "/") {
+			r.command(input)
+			continue
+		}
+		r.ask(ctx, input)
+	}
+}
 
-		// add message to the history
-		history = append(history, llm.Message{Role: "user", Content: input})
+func (r *repl) ask(ctx context.Context, input string) {
+	// RETRIEVE: look up the excerpts relevant to the question
+	var hits []vector.Result
+	if r.retriever != nil {
+		spin := startSpinner("searching")
+		found, err := r.retriever.Retrieve(ctx, input)
+		spin.Stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "retrieval failed, answering without documents: %v\n", err)
+		}
+		hits = found
+	}
+	messages := rag.Augment(r.memory.Messages(), input, hits)
 
-		// Stream response
-		spin := startSpinner("thinking")
-		var stopOnce sync.Once
-		fmt.Print("🤖 ")
-		reply, err := client.ChatStream(ctx, history, func(s string) {
-			stopOnce.Do(spin.Stop)
-			fmt.Print(s)
-		})
+	// Stream response
+	spin := startSpinner("thinking")
+	var stopOnce sync.Once
+	fmt.Print("🤖 ")
+	reply, err := r.client.ChatStream(ctx, messages, func(s string) {
 		stopOnce.Do(spin.Stop)
-		fmt.Println()
+		fmt.Print(s)
+	})
+	stopOnce.Do(spin.Stop)
+	fmt.Println()
 
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return
+	}
+
+	// the excerpts are only sent for this turn, the history keeps the plain question
+	r.memory.Add(input, reply)
+	r.sources = rag.Sources(hits)
+	printSources(r.sources)
+
+	if r.memory.Budget > 0 && r.memory.Tokens() > r.memory.Budget {
+		spin := startSpinner("summarising")
+		err := r.memory.Fit(ctx, r.client)
+		spin.Stop()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			history = history[:len(history)-1] // remove user message on error
-			continue
+			fmt.Fprintf(os.Stderr, "history: %v\n", err)
+		}
+	}
+	if r.session != "" {
+		if err := r.save(r.session); err != nil {
+			fmt.Fprintf(os.Stderr, "save session: %v\n", err)
 		}
+	}
+}
 
-		history = append(history, reply)
+// command runs a slash command, errors are reported and the REPL goes on
+func (r *repl) command(input string) {
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
 
+	switch strings.ToLower(name) {
+	case "/help":
+		fmt.Println(helpText)
+	case "/reset":
+		r.memory.Reset()
+		r.sources = nil
+		fmt.Println("Conversation cleared.")
+	case "/save":
+		if arg == "" {
+			arg = r.session
+		}
+		if arg == "" {
+			fmt.Println("usage: /save <name>")
+			return
+		}
+		if err := r.save(arg); err != nil {
+			fmt.Fprintf(os.Stderr, "save session: %v\n", err)
+			return
+		}
+		r.session = arg
+		fmt.Printf("Saved session %q.\n", arg)
+	case "/load":
+		if arg == "" {
+			r.listSessions()
+			return
+		}
+		if err := r.load(arg); err != nil {
+			fmt.Fprintf(os.Stderr, "load session: %v\n", err)
+		}
+	case "/sources":
+		if len(r.sources) == 0 {
+			fmt.Println("No sources for the last answer.")
+			return
+		}
+		printSources(r.sources)
+	case "/topk":
+		if r.retriever == nil {
+			fmt.Println("Retrieval is disabled.")
+			return
+		}
+		if arg == "" {
+			fmt.Printf("top_k=%d\n", r.retriever.TopK)
+			return
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			fmt.Println("usage: /topk <positive number>")
+			return
+		}
+		r.retriever.TopK = n
+		fmt.Printf("top_k=%d\n", n)
+	case "/model":
+		if arg != "" {
+			r.client = r.client.WithModel(arg)
+		}
+		fmt.Printf("model=%q\n", r.client.Model())
+	default:
+		fmt.Printf("unknown command %s, /help lists the commands\n", name)
+	}
+}
+
+func (r *repl) save(name string) error {
+	sess := Session{
+		Name:    name,
+		Model:   r.client.Model(),
+		Summary: r.memory.Summary,
+		Turns:   r.memory.Turns,
+		Sources: r.sources,
+	}
+	if r.retriever != nil {
+		sess.TopK = r.retriever.TopK
+	}
+	return r.sessions.Save(sess)
+}
+
+func (r *repl) load(name string) error {
+	sess, err := r.sessions.Load(name)
+	if err != nil {
+		return err
+	}
+	r.memory.Summary = sess.Summary
+	r.memory.Turns = sess.Turns
+	r.sources = sess.Sources
+	if sess.Model != "" && sess.Model != r.client.Model() {
+		r.client = r.client.WithModel(sess.Model)
+	}
+	if sess.TopK > 0 && r.retriever != nil {
+		r.retriever.TopK = sess.TopK
+	}
+	r.session = name
+	fmt.Printf("Resumed session %q: %d messages, last saved %s.\n",
+		name, len(sess.Turns), sess.UpdatedAt.Local().Format(time.DateTime))
+	return nil
+}
+
+func (r *repl) listSessions() {
+	names, err := r.sessions.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return
+	}
+	if len(names) == 0 {
+		fmt.Println("No saved sessions.")
+		return
+	}
+	fmt.Println("Sessions:")
+	for _, name := range names {
+		marker := " "
+		if name == r.session {
+			marker = "*"
+		}
+		fmt.Printf(" %s %s\n", marker, name)
+	}
+}
+
+func printSources(sources []rag.Source) {
+	if len(sources) == 0 {
+		return
+	}
+	fmt.Println("\nSources:")
+	for i, src := range sources {
+		name := src.Name
+		if src.Location != "" {
+			name += " (" + src.Location + ")"
+		}
+		fmt.Printf("  [%d] %s (relevance %.2f)\n", i+1, name, src.Score)
 	}
 }
 
@@ -117,7 +333,8 @@ func (s *spinner) Stop() {
 	<-s.done
 }
 
-func seedHistory(path string) ([]llm.Message, error) {
+// SeedHistory returns the system prompt read from path as the start of a conversation
+func SeedHistory(path string) ([]llm.Message, error) {
 	if path == "" {
 		return nil, nil
 	}
@@ -0,0 +1,116 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+)
+
+const sessionExt = ".json"
+
+var sessionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrNoSession is returned when a named session was never saved
+var ErrNoSession = errors.New("session not found")
+
+// Session is a saved conversation, the system prompt is not part of it
+// and is read again from the prompt file when the session is resumed
+type Session struct {
+	Name    string        `json:"name"`
+	Model   string        `json:"model,omitempty"`
+	TopK    int           `json:"top_k,omitempty"`
+	Summary string        `json:"summary,omitempty"`
+	Turns   []llm.Message `json:"turns"`
+	// Sources are the documents behind the last answer
+	Sources   []rag.Source `json:"sources,omitempty"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// SessionStore keeps named sessions as JSON files in Dir
+type SessionStore struct {
+	Dir string
+}
+
+// Save writes the session through a temporary file so a crash never leaves it half written
+func (s SessionStore) Save(sess Session) error {
+	path, err := s.path(sess.Name)
+	if err != nil {
+		return err
+	}
+	sess.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", s.Dir, err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace session: %w", err)
+	}
+	return nil
+}
+
+// Load reads a named session, ErrNoSession if it was never saved
+func (s SessionStore) Load(name string) (Session, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return Session{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Session{}, fmt.Errorf("%q: %w", name, ErrNoSession)
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("read session: %w", err)
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return Session{}, fmt.Errorf("parse session %s: %w", path, err)
+	}
+	sess.Name = name
+	return sess, nil
+}
+
+// List returns the names of the saved sessions
+func (s SessionStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), sessionExt)
+		if ok && !e.IsDir() && sessionName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s SessionStore) path(name string) (string, error) {
+	if s.Dir == "" {
+		return "", fmt.Errorf("no session directory configured")
+	}
+	if !sessionName.MatchString(name) {
+		return "", fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(s.Dir, name+sessionExt), nil
+}
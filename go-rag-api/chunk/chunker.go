@@ -0,0 +1,322 @@
+package chunk
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Piece is one chunk of a document with the metadata the strategy found for it
+type Piece struct {
+	Text string
+	// Metadata is merged into the chunk metadata, e.g. the markdown heading trail
+	Metadata map[string]string
+}
+
+// Chunker splits the text of a document section into chunks.
+// Pieces are substrings of text so their line range can be located
+type Chunker interface {
+	Split(text string) []Piece
+}
+
+// Strategy names a Chunker implementation
+type Strategy string
+
+const (
+	// StrategyFixed cuts by character count on word boundaries
+	StrategyFixed Strategy = "fixed"
+	// StrategyMarkdown splits at markdown headings and keeps the heading trail
+	StrategyMarkdown Strategy = "markdown"
+	// StrategySentence packs whole paragraphs and sentences up to the chunk size
+	StrategySentence Strategy = "sentence"
+	// StrategyToken packs paragraphs and sentences up to an estimated token count
+	StrategyToken Strategy = "token"
+	// StrategyRecursive splits on a list of separators, coarsest first (code)
+	StrategyRecursive Strategy = "recursive"
+)
+
+const (
+	defaultMaxTokens = 256
+	// charsPerToken is the rough size of a token for English text and code
+	charsPerToken = 4
+)
+
+// codeSeparators try to keep declarations whole before falling back to lines
+var codeSeparators = []string{
+	"\nfunc ", "\ntype ", "\nclass ", "\ndef ", "\nfn ", "\nimpl ",
+	"\npublic ", "\nprivate ", "\nexport ", "\nconst ", "\nvar ",
+	"\n\n", "\n", " ",
+}
+
+// lineSeparators keep records of line oriented formats (CSV) together
+var lineSeparators = []string{"\n", " "}
+
+// ParseStrategies parses a list like ".md=markdown,.txt=sentence,go=recursive".
+// Keys are file extensions (with the dot) or loader formats
+func ParseStrategies(s string) (map[string]Strategy, error) {
+	strategies := make(map[string]Strategy)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("chunk strategy %q: want key=strategy", pair)
+		}
+		strategy := Strategy(strings.ToLower(strings.TrimSpace(value)))
+		switch strategy {
+		case StrategyFixed, StrategyMarkdown, StrategySentence, StrategyToken, StrategyRecursive:
+		default:
+			return nil, fmt.Errorf("chunk strategy %q: unknown strategy %q", pair, value)
+		}
+		strategies[strings.ToLower(strings.TrimSpace(key))] = strategy
+	}
+	return strategies, nil
+}
+
+// chunker returns the Chunker for a file: an override for its extension or
+// loader format from opts.Strategies, otherwise the default for the format
+func (o Options) chunker(name, format string) Chunker {
+	strategy, ok := o.Strategies[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		strategy, ok = o.Strategies[format]
+	}
+	if !ok {
+		switch format {
+		case "markdown":
+			strategy = StrategyMarkdown
+		case "text", "pdf", "html", "docx":
+			strategy = StrategySentence
+		default:
+			strategy = StrategyRecursive
+		}
+	}
+
+	size, overlap := o.size()
+	switch strategy {
+	case StrategyFixed:
+		return Fixed{Size: size, Overlap: overlap}
+	case StrategyMarkdown:
+		return Markdown{Size: size, Overlap: overlap}
+	case StrategyToken:
+		maxTokens := o.MaxTokens
+		if maxTokens <= 0 {
+			maxTokens = defaultMaxTokens
+		}
+		return Token{MaxTokens: maxTokens, Overlap: overlap / charsPerToken}
+	case StrategyRecursive:
+		seps := codeSeparators
+		if format == "csv" {
+			seps = lineSeparators
+		}
+		return Recursive{Size: size, Overlap: overlap, Separators: seps}
+	default:
+		return Sentence{Size: size, Overlap: overlap}
+	}
+}
+
+// size returns the chunk size and overlap with the defaults applied
+func (o Options) size() (int, int) {
+	size := o.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	overlap := o.ChunkOverlap
+	if overlap <= 0 {
+		overlap = defaultChunkOverlap
+	}
+	return size, overlap
+}
+
+// Fixed is the plain character count splitter
+type Fixed struct {
+	Size    int
+	Overlap int
+}
+
+func (f Fixed) Split(text string) []Piece {
+	return pieces(chunk(text, f.Size, f.Overlap), nil)
+}
+
+func pieces(chunks []string, meta map[string]string) []Piece {
+	out := make([]Piece, len(chunks))
+	for i, c := range chunks {
+		out[i] = Piece{Text: c, Metadata: meta}
+	}
+	return out
+}
+
+// span is a byte range of the text being split
+type span struct {
+	start, end int
+}
+
+// splitFunc cuts a span into consecutive spans covering it,
+// it returns a single span when it finds nothing to cut at
+type splitFunc func(text string, sp span) []span
+
+// packer splits text into units no larger than limit and merges
+// consecutive units back into chunks of up to limit
+type packer struct {
+	limit   int
+	overlap int
+	// measure is the size of a string: runes or estimated tokens
+	measure func(string) int
+	// runesPerUnit converts limit into runes for the last resort hard cut
+	runesPerUnit int
+	// levels are tried in order on spans larger than limit
+	levels []splitFunc
+}
+
+func (p packer) split(text string) []string {
+	if strings.TrimSpace(text) == "" || p.limit <= 0 {
+		return nil
+	}
+	units := p.units(text, span{0, len(text)}, p.levels)
+	return p.pack(text, units)
+}
+
+// units keeps spans that fit whole and splits the others with the next level
+func (p packer) units(text string, sp span, levels []splitFunc) []span {
+	if p.measure(text[sp.start:sp.end]) <= p.limit {
+		return []span{sp}
+	}
+	if len(levels) == 0 {
+		return p.hardCut(text, sp)
+	}
+	parts := levels[0](text, sp)
+	if len(parts) <= 1 {
+		return p.units(text, sp, levels[1:])
+	}
+	var out []span
+	for _, part := range parts {
+		out = append(out, p.units(text, part, levels[1:])...)
+	}
+	return out
+}
+
+// hardCut splits a span without any separator into fixed rune windows
+func (p packer) hardCut(text string, sp span) []span {
+	window := p.limit * max(p.runesPerUnit, 1)
+	var out []span
+	start, n := sp.start, 0
+	for i := range text[sp.start:sp.end] {
+		if n == window {
+			out = append(out, span{start, sp.start + i})
+			start, n = sp.start+i, 0
+		}
+		n++
+	}
+	return append(out, span{start, sp.end})
+}
+
+// pack merges consecutive units up to limit, starting each chunk with the
+// trailing units of the previous one that fit in overlap
+func (p packer) pack(text string, units []span) []string {
+	// cost[i] is the size of unit i including the gap before it
+	cost := make([]int, len(units))
+	for i, u := range units {
+		from := u.start
+		if i > 0 {
+			from = units[i-1].end
+		}
+		cost[i] = p.measure(text[from:u.end])
+	}
+
+	var chunks []string
+	first := 0
+	for first < len(units) {
+		last, size := first, p.measure(text[units[first].start:units[first].end])
+		for last+1 < len(units) && size+cost[last+1] <= p.limit {
+			last++
+			size += cost[last]
+		}
+		if part := strings.TrimSpace(text[units[first].start:units[last].end]); part != "" {
+			chunks = append(chunks, part)
+		}
+		if last == len(units)-1 {
+			break
+		}
+
+		next, carried := last+1, 0
+		for next-1 > first && carried+cost[next-1] <= p.overlap {
+			next--
+			carried += cost[next]
+		}
+		first = next
+	}
+	return chunks
+}
+
+// splitAt cuts before every occurrence of sep, so the separator starts the
+// next span (a declaration keeps its keyword)
+func splitAt(sep string) splitFunc {
+	return func(text string, sp span) []span {
+		var out []span
+		start := sp.start
+		for i := sp.start + 1; i < sp.end; {
+			j := strings.Index(text[i:sp.end], sep)
+			if j < 0 {
+				break
+			}
+			cut := i + j
+			// leading newlines belong to the previous span
+			if strings.HasPrefix(sep, "\n") {
+				cut += len(sep) - len(strings.TrimLeft(sep, "\n"))
+			}
+			if cut > start && cut < sp.end {
+				out = append(out, span{start, cut})
+				start = cut
+			}
+			i = i + j + len(sep)
+		}
+		return append(out, span{start, sp.end})
+	}
+}
+
+// splitSentences cuts after sentence punctuation followed by
+// whitespace, and after line breaks
+func splitSentences(text string, sp span) []span {
+	var out []span
+	start := sp.start
+	for i := sp.start; i < sp.end; {
+		r, size := utf8.DecodeRuneInString(text[i:sp.end])
+		next := i + size
+		boundary := r == '\n'
+		if (r == '.' || r == '!' || r == '?') && next < sp.end {
+			n, _ := utf8.DecodeRuneInString(text[next:sp.end])
+			boundary = n == ' ' || n == '\n' || n == '\t'
+		}
+		if boundary && next < sp.end {
+			out = append(out, span{start, next})
+			start = next
+		}
+		i = next
+	}
+	return append(out, span{start, sp.end})
+}
+
+// splitWords cuts before every whitespace run
+func splitWords(text string, sp span) []span {
+	var out []span
+	start := sp.start
+	prevSpace := false
+	for i, r := range text[sp.start:sp.end] {
+		space := r == ' ' || r == '\n' || r == '\t'
+		if space && !prevSpace && sp.start+i > start {
+			out = append(out, span{start, sp.start + i})
+			start = sp.start + i
+		}
+		prevSpace = space
+	}
+	return append(out, span{start, sp.end})
+}
+
+var proseLevels = []splitFunc{splitAt("\n\n"), splitSentences, splitWords}
+
+// runeCount is the measure of the character based strategies
+func runeCount(s string) int {
+	return utf8.RuneCountInString(s)
+}
@@ -0,0 +1,66 @@
+package chunk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+)
+
+const (
+	defaultEmbedBatchSize = 32
+	defaultEmbedRetries   = 3
+	// embedBackoff is the wait before the first retry, doubled on every retry
+	embedBackoff = 500 * time.Millisecond
+)
+
+// embedBatches embeds texts as documents in calls of at most opts.EmbedBatchSize,
+// retrying every failed call with exponential back-off
+func embedBatches(ctx context.Context, texts []string, opts Options, embedder llm.Embedder) ([][]float32, error) {
+	size := opts.EmbedBatchSize
+	if size <= 0 {
+		size = defaultEmbedBatchSize
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		batch := texts[start:min(start+size, len(texts))]
+		got, err := embedWithRetry(ctx, batch, opts, embedder)
+		if err != nil {
+			return nil, fmt.Errorf("chunks %d-%d: %w", start, start+len(batch)-1, err)
+		}
+		vectors = append(vectors, got...)
+	}
+	return vectors, nil
+}
+
+func embedWithRetry(ctx context.Context, batch []string, opts Options, embedder llm.Embedder) ([][]float32, error) {
+	retries := opts.EmbedRetries
+	if retries <= 0 {
+		retries = defaultEmbedRetries
+	}
+
+	wait := embedBackoff
+	for attempt := 0; ; attempt++ {
+		// EMBED: these are documents, not queries
+		// so Nomic-style prefixes ("search_document: …") are applied correctly.
+		vectors, err := embedder.Embed(ctx, batch, false)
+		if err == nil && len(vectors) != len(batch) {
+			err = fmt.Errorf("embed got %d vectors for %d chunks", len(vectors), len(batch))
+		}
+		if err == nil {
+			return vectors, nil
+		}
+		if attempt == retries || ctx.Err() != nil {
+			return nil, fmt.Errorf("embed after %d attempts: %w", attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
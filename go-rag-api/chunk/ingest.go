@@ -2,14 +2,18 @@ package chunk
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/loader"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
 )
 
@@ -30,16 +34,46 @@ const (
 type Options struct {
 	SourceDir    string
 	ProcessedDir string
+	// ChunkSize and ChunkOverlap are in characters
 	ChunkSize    int
 	ChunkOverlap int
+	// MaxTokens is the chunk size of the token strategy
+	MaxTokens int
+	// Strategies overrides the chunking strategy per file extension (".md")
+	// or loader format ("markdown", "go"), see ParseStrategies
+	Strategies map[string]Strategy
+
+	// Ledger records what was ingested so unchanged files and chunks are
+	// skipped, nil re-embeds every file completely
+	Ledger *Ledger
+	// Workers bounds how many files are ingested at once
+	Workers int
+	// EmbedBatchSize is the most chunks sent in one embedding call
+	EmbedBatchSize int
+	// EmbedRetries is how often a failed embedding call is retried
+	EmbedRetries int
+	// KeepFiles leaves ingested files in SourceDir instead of moving them
+	// to ProcessedDir, edits and deletions are then picked up in place
+	KeepFiles bool
+}
+
+// defaultWorkers is how many files are ingested at once
+const defaultWorkers = 4
+
+func (o Options) workers() int {
+	if o.Workers <= 0 {
+		return defaultWorkers
+	}
+	return o.Workers
 }
 
 // processOne reads a single file and runs the full ingestion pipeline on it.
-// It delegates to preocessContent for the core logic.
-func processOne(ctx context.Context,path string,opts Options,embedder llm.Embedder, store vector.Store) error {
+// It delegates to preocessContent for the core logic, source is the key the
+// chunks are stored under (the path relative to the watched directory).
+func processOne(ctx context.Context,path, source string,opts Options,embedder llm.Embedder, store vector.Store) error {
 
 	// Quick format check before attempting to read
-	if !supportedFormat(path) {
+	if !loader.Supported(path) {
 		return fmt.Errorf("unsupported format %s", filepath.Ext(path))
 	}
 	// READ: load raw file contents
@@ -47,18 +81,35 @@ func processOne(ctx context.Context,path string,opts Options,embedder llm.Embedd
 	if err != nil {
 		return fmt.Errorf("read %w", err)
 	}
-	// Delegate to the core pipeline, passing only the base filename as the source identifier
-	n, err := processContent(ctx, filepath.Base(path), raw, opts, embedder, store)
-	if err != nil {
+	// Delegate to the core pipeline, passing the relative path as the source identifier
+	if _, err := processContent(ctx, source, raw, opts, embedder, store); err != nil {
 		return fmt.Errorf("process %s: %w", path, err)
 	}
-
-	fmt.Printf("ingested %s: %d chunks\n", path, n)
 	return nil
 }
 
+// CleanSource normalizes a source key: a slash separated relative path
+// without "..", the same file always maps to the same key
+func CleanSource(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// Ingest runs the ingestion pipeline on content that did not come from the
+// watched directory, such as an upload. source is the name stored with the chunks.
+func Ingest(
+	ctx context.Context,
+	source string,
+	content []byte,
+	opts Options,
+	embedder llm.Embedder,
+	store vector.Store,
+) (int, error) {
+	return processContent(ctx, source, content, opts, embedder, store)
+}
+
 // preocessContent is the core ingestion pipeline.
-// It takes the source basename and raw content, then executes CHUNK → EMBED → DELETE → UPSERT.
+// It takes the source key and raw content, then executes LOAD → CHUNK → EMBED → UPSERT → DELETE,
+// recording the outcome in the ledger when there is one.
 // ingest document from some source directoy
 func processContent(
 	ctx context.Context,
@@ -75,77 +126,268 @@ func processContent(
 	if store == nil {
 		return 0, errors.New("vector store is required")
 	}
-	base := filepath.Base(source)
-	if !supportedFormat(base) {
-		return 0, fmt.Errorf("unsupported format %s", filepath.Ext(base))
+	source = CleanSource(source)
+	if !loader.Supported(source) {
+		return 0, fmt.Errorf("unsupported format %s", filepath.Ext(source))
 	}
 
-	// CHUNK: apply user-provided or default chunk size / overlap
-	size := opts.ChunkSize
-	if size <= 0 {
-		size = defaultChunkSize
+	var res ingestResult
+	var err error
+	if opts.Ledger == nil {
+		res, err = ingestContent(ctx, source, content, LedgerEntry{}, opts, embedder, store)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		// the watcher marks files pending when it queues them, the entry
+		// before that still describes the stored chunks
+		prev, _ := opts.Ledger.lastSettled(source)
+		if err := opts.Ledger.MarkPending(source); err != nil {
+			return 0, err
+		}
+		res, err = ingestContent(ctx, source, content, prev, opts, embedder, store)
+		if err != nil {
+			return 0, errors.Join(err, opts.Ledger.MarkFailed(source, err))
+		}
+		if err := opts.Ledger.MarkDone(source, res.contentHash, res.ids, res.embedded); err != nil {
+			return 0, err
+		}
 	}
 
-	overlap := opts.ChunkOverlap
-	if overlap <= 0 {
-		overlap = defaultChunkOverlap
+	if res.skipped {
+		fmt.Fprintf(os.Stderr, "unchanged %s: %d chunks\n", source, len(res.ids))
+	} else {
+		fmt.Fprintf(os.Stderr, "updated %s: %d chunks, %d embedded\n", source, len(res.ids), res.embedded)
 	}
-	text := strings.TrimSpace(string(content))
-	if text == "" {
-		return 0, errors.New("file is empty")
+	return len(res.ids), nil
+}
+
+// ingestResult is what one run of the pipeline stored for a source
+type ingestResult struct {
+	contentHash string
+	// ids of all chunks now stored for the source
+	ids      []string
+	embedded int
+	// skipped is set when the content hash matched and nothing was done
+	skipped bool
+}
+
+// ingestContent diffs the chunks of content against prev, the last ledger
+// entry of the source. Chunks whose text is unchanged keep their ID and
+// embedding, only new text is sent to the embedder, and chunks that
+// disappeared are deleted once the new ones are stored
+func ingestContent(
+	ctx context.Context,
+	source string,
+	content []byte,
+	prev LedgerEntry,
+	opts Options,
+	embedder llm.Embedder,
+	store vector.Store,
+) (ingestResult, error) {
+	// LOAD: extract the text and its structure (pages, headings, lines)
+	doc, err := loader.Load(source, content)
+	if err != nil {
+		return ingestResult{}, err
+	}
+	if strings.TrimSpace(doc.Text()) == "" {
+		return ingestResult{}, errors.New("file is empty")
 	}
 	// CHUNK the text
-	// Split the text into overlapping chunks (boundary-aware: avoids cutting mid-word)
-	chunks := chunk(text, size, overlap)
+	// Split each section with the strategy picked for the file type
+	// so a chunk never spans two pages or headings and keeps their metadata
+	chunker := opts.chunker(source, doc.Format)
+
+	// the hash covers the chunking settings so changing them re-chunks the file
+	res := ingestResult{contentHash: contentHash(content, chunker)}
+	if prev.Status == StatusDone && prev.ContentHash == res.contentHash {
+		res.ids = prev.Chunks
+		res.skipped = true
+		return res, nil
+	}
+
+	var chunks []string
+	var chunkMeta []map[string]string
+	for _, section := range doc.Sections {
+		parts := chunker.Split(section.Text)
+		var lines []string
+		if section.StartLine > 0 {
+			texts := make([]string, len(parts))
+			for i, part := range parts {
+				texts[i] = part.Text
+			}
+			lines = lineRanges(section.Text, section.StartLine, texts)
+		}
+		for i, part := range parts {
+			meta := map[string]string{}
+			for k, v := range section.Metadata {
+				meta[k] = v
+			}
+			for k, v := range part.Metadata {
+				meta[k] = v
+			}
+			if lines != nil && lines[i] != "" {
+				meta[loader.MetaLines] = lines[i]
+			}
+			chunks = append(chunks, part.Text)
+			chunkMeta = append(chunkMeta, meta)
+		}
+	}
 	if len(chunks) == 0 {
-		return 0, errors.New("no chunks produced")
+		return ingestResult{}, errors.New("no chunks produced")
 	}
-	// EMBED: these are documents, not queries
-	// so Nomic-style prefixes ("search_document: …") are applied correctly.
-	vectors, err := embedder.Embed(ctx, chunks, false)
+
+	// IDs are derived from the chunk text, so an unchanged chunk keeps its ID
+	res.ids = chunkIDs(source, chunks)
+	previous := make(map[string]bool, len(prev.Chunks))
+	for _, id := range prev.Chunks {
+		previous[id] = true
+	}
+
+	// reuse the stored vectors of unchanged chunks when the store can return them
+	vectors := make([][]float32, len(chunks))
+	if getter, ok := store.(vector.EmbeddingGetter); ok && len(previous) > 0 {
+		var known []string
+		for _, id := range res.ids {
+			if previous[id] {
+				known = append(known, id)
+			}
+		}
+		stored, err := getter.Embeddings(ctx, known)
+		if err != nil {
+			return ingestResult{}, fmt.Errorf("load stored embeddings: %w", err)
+		}
+		for i, id := range res.ids {
+			vectors[i] = stored[id]
+		}
+	}
+
+	// EMBED: only the chunks without a vector, in batches with retries
+	var missing []int
+	var texts []string
+	for i := range chunks {
+		if vectors[i] == nil {
+			missing = append(missing, i)
+			texts = append(texts, chunks[i])
+		}
+	}
+	embedded, err := embedBatches(ctx, texts, opts, embedder)
 	if err != nil {
-		return 0, fmt.Errorf("embed %w", err)
+		return ingestResult{}, err
 	}
-	if len(vectors) != len(chunks) {
-		return 0, fmt.Errorf("embed got %d vectors for %d chunks", len(vectors), len(chunks))
+	for j, i := range missing {
+		vectors[i] = embedded[j]
 	}
-	// DELETE: clear previous chunks for this source
-	// DELETE: remove any prior vectors for this source so we don't leave stale data
-	if err := store.DeleteBySource(ctx, base); err != nil {
-		return 0, fmt.Errorf("clear previous chunks %w", err)
+	res.embedded = len(missing)
+
+	// DELETE: without a ledger entry the stored chunks are unknown (ingested
+	// before the ledger existed or with the ledger off), so clear the source first
+	if prev.Source == "" {
+		if err := store.DeleteBySource(ctx, source); err != nil {
+			return ingestResult{}, fmt.Errorf("clear previous chunks %w", err)
+		}
 	}
 	// UPSERT: build documents and upsert them
 	// UPSERT: construct vector.Document structs and upsert them into the store.
-	// Each document gets a stable ID and metadata so we can filter / debug later.
+	// Every chunk is written again, unchanged ones with their stored vector,
+	// so positions (chunk_index, lines) follow edits made around them.
 	ingestedAt := time.Now().UTC().Format(time.RFC3339)
 	// build the document
 	docs := make([]vector.Document, len(chunks))
 
 	for i, c := range chunks {
+		meta := chunkMeta[i]
+		meta["source"] = source
+		meta["chunk_index"] = fmt.Sprintf("%d", i)
+		meta["chunks"] = fmt.Sprintf("%d", len(chunks))
+		meta["ingested_at"] = ingestedAt
+		meta[loader.MetaFormat] = doc.Format
+		if doc.Title != "" {
+			meta[loader.MetaTitle] = doc.Title
+		}
 		docs[i] = vector.Document{
-			ID:        fmt.Sprintf("%s-chunk-%d", strings.ReplaceAll(base, ".", "_"), i),
+			ID:        res.ids[i],
 			Content:   c,
-			Metadata: map[string]string{
-				"source":      base,
-				"chunk_index": fmt.Sprintf("%d", i),
-				"chunks": fmt.Sprintf("%d", len(chunks)),
-				"ingested_at": ingestedAt,
-			},
+			Metadata:  meta,
 			Embedding: vectors[i],
 		}
 	}
 
 	if err := store.Upsert(ctx, docs); err != nil {
-		return 0, fmt.Errorf("upsert %w", err)
+		return ingestResult{}, fmt.Errorf("upsert %w", err)
 	}
 
-	return len(chunks), nil
+	// DELETE: drop the chunks of the previous version that are gone
+	current := make(map[string]bool, len(res.ids))
+	for _, id := range res.ids {
+		current[id] = true
+	}
+	var stale []string
+	for _, id := range prev.Chunks {
+		if !current[id] {
+			stale = append(stale, id)
+		}
+	}
+	if err := store.Delete(ctx, stale); err != nil {
+		return ingestResult{}, fmt.Errorf("delete stale chunks %w", err)
+	}
+
+	return res, nil
+}
+
+// contentHash identifies a version of a file together with the chunker
+// settings it was split with
+func contentHash(content []byte, chunker Chunker) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%T%+v\n", chunker, chunker)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-func supportedFormat(path string) bool {
-	switch strings.ToLower(filepath.Ext(path)) {
-	case ".txt", ".md", "markdown":
-		return true
+// chunkIDs names every chunk after its source and a hash of its text,
+// repeated texts get a counter so IDs stay unique
+func chunkIDs(source string, chunks []string) []string {
+	prefix := strings.ReplaceAll(source, ".", "_")
+	seen := make(map[string]int, len(chunks))
+	ids := make([]string, len(chunks))
+	for i, c := range chunks {
+		sum := sha256.Sum256([]byte(c))
+		h := hex.EncodeToString(sum[:8])
+		ids[i] = fmt.Sprintf("%s-%s", prefix, h)
+		if n := seen[h]; n > 0 {
+			ids[i] = fmt.Sprintf("%s-%d", ids[i], n)
+		}
+		seen[h]++
+	}
+	return ids
+}
+
+// SupportedFormat reports whether the file extension can be ingested
+func SupportedFormat(path string) bool {
+	return loader.Supported(path)
+}
+
+// lineRanges returns the "first-last" line range of every chunk of text,
+// where text starts at line startLine. Chunks are located in order since
+// they overlap and the same snippet may appear more than once.
+func lineRanges(text string, startLine int, chunks []string) []string {
+	ranges := make([]string, len(chunks))
+	cursor := 0
+	for i, c := range chunks {
+		idx := strings.Index(text[cursor:], c)
+		if idx < 0 {
+			// should not happen, fall back to searching the whole section
+			idx = strings.Index(text, c)
+			if idx < 0 {
+				continue
+			}
+		} else {
+			idx += cursor
+		}
+		first := startLine + strings.Count(text[:idx], "\n")
+		last := first + strings.Count(c, "\n")
+		ranges[i] = fmt.Sprintf("%d-%d", first, last)
+		cursor = idx + 1
 	}
-	return false
+	return ranges
 }
@@ -0,0 +1,184 @@
+package chunk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status is the ingestion state of a source
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusFailed  Status = "failed"
+	StatusDone    Status = "done"
+)
+
+// LedgerEntry records what was ingested for a source
+type LedgerEntry struct {
+	Source string `json:"source"`
+	Status Status `json:"status"`
+	// ContentHash covers the file content and the chunking settings,
+	// an equal hash means the stored chunks are up to date
+	ContentHash string `json:"content_hash,omitempty"`
+	// Chunks are the IDs of the stored chunks, they embed the chunk text hash
+	Chunks []string `json:"chunks,omitempty"`
+	// Embedded is how many chunks the last run sent to the embedder
+	Embedded  int       `json:"embedded"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Ledger is the on-disk record of ingested sources, it lets ingestion skip
+// unchanged files and re-embed only the chunks that changed
+type Ledger struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]LedgerEntry
+	// settled keeps the entries of pending sources as they were before
+	// they were marked, the state the stored chunks are in until the
+	// ingestion finishes. It is not saved, after a restart a pending
+	// source is ingested again
+	settled map[string]LedgerEntry
+}
+
+// OpenLedger loads the ledger at path, a missing file is an empty ledger
+func OpenLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path, entries: make(map[string]LedgerEntry), settled: make(map[string]LedgerEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read ledger: %w", err)
+	}
+	var entries []LedgerEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse ledger %s: %w", path, err)
+	}
+	for _, e := range entries {
+		l.entries[e.Source] = e
+	}
+	return l, nil
+}
+
+// Get returns the entry of a source
+func (l *Ledger) Get(source string) (LedgerEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[source]
+	return e, ok
+}
+
+// List returns all entries ordered by source
+func (l *Ledger) List() []LedgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sorted()
+}
+
+// lastSettled returns the entry of a source as the last finished ingestion
+// left it, also while the source is marked pending
+func (l *Ledger) lastSettled(source string) (LedgerEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.settled[source]; ok {
+		return e, true
+	}
+	e, ok := l.entries[source]
+	return e, ok
+}
+
+// MarkPending flags a source as queued, keeping what was stored before
+func (l *Ledger) MarkPending(source string) error {
+	return l.update(source, func(e *LedgerEntry) {
+		if _, ok := l.settled[source]; !ok && e.Status != StatusPending {
+			l.settled[source] = *e
+		}
+		e.Status = StatusPending
+		e.Error = ""
+	})
+}
+
+// MarkFailed records the error of the last attempt, the stored chunks
+// and content hash stay so the next attempt still diffs against them
+func (l *Ledger) MarkFailed(source string, err error) error {
+	return l.update(source, func(e *LedgerEntry) {
+		delete(l.settled, source)
+		e.Status = StatusFailed
+		e.Error = err.Error()
+	})
+}
+
+// MarkDone records the chunks now stored for a source
+func (l *Ledger) MarkDone(source, contentHash string, chunks []string, embedded int) error {
+	return l.update(source, func(e *LedgerEntry) {
+		delete(l.settled, source)
+		e.Status = StatusDone
+		e.ContentHash = contentHash
+		e.Chunks = chunks
+		e.Embedded = embedded
+		e.Error = ""
+	})
+}
+
+// Remove forgets a source
+func (l *Ledger) Remove(source string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.settled, source)
+	if _, ok := l.entries[source]; !ok {
+		return nil
+	}
+	delete(l.entries, source)
+	return l.save()
+}
+
+func (l *Ledger) update(source string, fn func(*LedgerEntry)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.entries[source]
+	e.Source = source
+	fn(&e)
+	e.UpdatedAt = time.Now().UTC()
+	l.entries[source] = e
+	return l.save()
+}
+
+func (l *Ledger) sorted() []LedgerEntry {
+	entries := make([]LedgerEntry, 0, len(l.entries))
+	for _, e := range l.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Source < entries[j].Source })
+	return entries
+}
+
+// save writes the ledger through a temporary file so a crash never leaves
+// it half written. Callers hold l.mu
+func (l *Ledger) save() error {
+	data, err := json.MarshalIndent(l.sorted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode ledger: %w", err)
+	}
+	dir := filepath.Dir(l.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("replace ledger: %w", err)
+	}
+	return nil
+}
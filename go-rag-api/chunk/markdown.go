@@ -0,0 +1,101 @@
+package chunk
+
+import (
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/loader"
+)
+
+// Markdown starts a chunk at every heading and stores the heading trail
+// ("Setup > Install") of each chunk. Sections larger than Size are split
+// like Sentence, chunks never span two sections
+type Markdown struct {
+	Size    int
+	Overlap int
+}
+
+func (m Markdown) Split(text string) []Piece {
+	body := Sentence{Size: m.Size, Overlap: m.Overlap}
+
+	var out []Piece
+	var trail []mdHeading
+	emit := func(section string) {
+		if strings.TrimSpace(section) == "" {
+			return
+		}
+		meta := map[string]string{}
+		if len(trail) > 0 {
+			titles := make([]string, len(trail))
+			for i, h := range trail {
+				titles[i] = h.text
+			}
+			meta[loader.MetaHeading] = strings.Join(titles, loader.HeadingSeparator)
+		}
+		for _, p := range body.Split(section) {
+			out = append(out, Piece{Text: p.Text, Metadata: meta})
+		}
+	}
+
+	start, inFence := 0, false
+	for offset := 0; offset < len(text); {
+		end := strings.IndexByte(text[offset:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += offset + 1
+		}
+		line := strings.TrimRight(text[offset:end], "\r\n")
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if level, title, ok := atxHeading(line); ok && !inFence {
+			emit(text[start:offset])
+			for len(trail) > 0 && trail[len(trail)-1].level >= level {
+				trail = trail[:len(trail)-1]
+			}
+			trail = append(trail, mdHeading{level: level, text: title})
+			start = offset
+		}
+		offset = end
+	}
+	emit(text[start:])
+
+	// a heading directly followed by a sub heading makes a chunk of one line
+	filtered := out[:0]
+	for _, p := range out {
+		if _, _, ok := atxHeading(p.Text); ok && !strings.Contains(p.Text, "\n") {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+type mdHeading struct {
+	level int
+	text  string
+}
+
+// atxHeading parses "## Title" lines, up to three spaces of indentation
+func atxHeading(line string) (int, string, bool) {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	if indent > 3 {
+		return 0, "", false
+	}
+	line = line[indent:]
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	rest := line[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, "", false
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rest), "#"))
+	if title == "" {
+		return 0, "", false
+	}
+	return level, title, true
+}
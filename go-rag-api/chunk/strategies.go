@@ -0,0 +1,79 @@
+package chunk
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Sentence packs whole paragraphs, then sentences, then words up to Size
+// characters, so chunks end where the text makes a pause
+type Sentence struct {
+	Size    int
+	Overlap int
+}
+
+func (s Sentence) Split(text string) []Piece {
+	p := packer{
+		limit:        s.Size,
+		overlap:      s.Overlap,
+		measure:      runeCount,
+		runesPerUnit: 1,
+		levels:       proseLevels,
+	}
+	return pieces(p.split(text), nil)
+}
+
+// Token is Sentence measured in estimated tokens instead of characters,
+// for embedding models with a hard token limit
+type Token struct {
+	MaxTokens int
+	Overlap   int
+}
+
+func (t Token) Split(text string) []Piece {
+	p := packer{
+		limit:        t.MaxTokens,
+		overlap:      t.Overlap,
+		measure:      EstimateTokens,
+		runesPerUnit: charsPerToken,
+		levels:       proseLevels,
+	}
+	return pieces(p.split(text), nil)
+}
+
+// EstimateTokens approximates the token count of s without a tokenizer:
+// every word costs one token per four characters, at least one
+func EstimateTokens(s string) int {
+	n := 0
+	for _, w := range strings.Fields(s) {
+		n += (utf8.RuneCountInString(w) + charsPerToken - 1) / charsPerToken
+	}
+	return n
+}
+
+// Recursive splits on Separators, coarsest first, only going down a level
+// for the parts that are still larger than Size
+type Recursive struct {
+	Size       int
+	Overlap    int
+	Separators []string
+}
+
+func (r Recursive) Split(text string) []Piece {
+	seps := r.Separators
+	if len(seps) == 0 {
+		seps = codeSeparators
+	}
+	levels := make([]splitFunc, len(seps))
+	for i, sep := range seps {
+		levels[i] = splitAt(sep)
+	}
+	p := packer{
+		limit:        r.Size,
+		overlap:      r.Overlap,
+		measure:      runeCount,
+		runesPerUnit: 1,
+		levels:       levels,
+	}
+	return pieces(p.split(text), nil)
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/loader"
 	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
 	"github.com/fsnotify/fsnotify"
 )
@@ -24,9 +27,13 @@ const documentDelay = 500 * time.Millisecond
 
 // implement function here tyhe directory is ./documents and preocessed files are moved in documents/processed
 
-// Watch watches the source directory for new or modified files.
+// Watch watches the source directory tree for new, modified and deleted files.
 // When a file appears, it waits for a short delay (debounce), processes it
-// through the ingestion pipeline, and moves it to the processed directory.
+// through the ingestion pipeline, and moves it to the same relative path in
+// the processed directory, unless opts.KeepFiles leaves it in place.
+// Files are stored under their path relative to the watched root, and
+// removing or renaming one (in the source tree, or in the processed tree
+// once moved there) deletes its chunks.
 // It blocks until ctx is cancelled.
 func Watch(
 	ctx context.Context,
@@ -40,106 +47,97 @@ func Watch(
 	if err != nil {
 		return fmt.Errorf("resolve source dir: %w", err)
 	}
-	processedDir, err := filepath.Abs(opts.ProcessedDir)
-	if err != nil {
-		return fmt.Errorf("resolve processed dir: %w", err)
-	}
-
-	if sourceDir == processedDir {
-		return errors.New("source and processed directories must be different")
+	var processedDir string
+	if !opts.KeepFiles {
+		processedDir, err = filepath.Abs(opts.ProcessedDir)
+		if err != nil {
+			return fmt.Errorf("resolve processed dir: %w", err)
+		}
+		if sourceDir == processedDir {
+			return errors.New("source and processed directories must be different")
+		}
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	fsw, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("new watcher: %w", err)
 	}
 
-	defer watcher.Close()
+	defer fsw.Close()
 
 	// Ensure both directories exist
-	if err := os.MkdirAll(opts.SourceDir, 0o755); err != nil {
+	if err := os.MkdirAll(sourceDir, 0o755); err != nil {
 		return fmt.Errorf("mkdir source: %w", err)
 	}
-	if err := os.MkdirAll(opts.ProcessedDir, 0o755); err != nil {
-		return fmt.Errorf("mkdir processed: %w", err)
+	if processedDir != "" {
+		if err := os.MkdirAll(processedDir, 0o755); err != nil {
+			return fmt.Errorf("mkdir processed: %w", err)
+		}
+	}
+
+	w := &treeWatcher{
+		ctx:          ctx,
+		opts:         opts,
+		embedder:     embedder,
+		store:        store,
+		logger:       logger,
+		fsw:          fsw,
+		sourceDir:    sourceDir,
+		processedDir: processedDir,
+		// Buffered channel to queue files for processing.
+		// The buffer allows the watcher goroutine to keep up even if
+		// the workers are busy embedding.
+		pending: make(chan string, 100),
+		queued:  make(map[string]bool),
+		dirty:   make(map[string]bool),
+		moving:  make(map[string]bool),
 	}
 
-	// Start watching the source directory
-	if err := watcher.Add(opts.SourceDir); err != nil {
+	// Start watching the source tree, and the processed tree for deletions
+	if err := w.addTree(sourceDir); err != nil {
 		return fmt.Errorf("add source dir to watcher: %w", err)
 	}
+	if processedDir != "" {
+		if err := w.addTree(processedDir); err != nil {
+			return fmt.Errorf("add processed dir to watcher: %w", err)
+		}
+	}
 
-	logger.Printf("watching %s for new documents", opts.SourceDir)
+	logger.Printf("watching %s for new documents", sourceDir)
 
-// Process any files that were already in sourceDir before we started watching
-	if err := batchProcessExisting(ctx, sourceDir, processedDir,opts, embedder, store, logger); err != nil {
+	// Process any files that were already in sourceDir before we started watching
+	if err := w.batchProcessExisting(); err != nil {
 		logger.Printf("batch process existing files: %v", err)
 	}
 
-	// Buffered channel to queue files for processing.
-	// The buffer allows the watcher goroutine to keep up even if
-	// the processor goroutine is busy embedding.
-	pending := make(chan string, 100)
-
-	// Worker goroutine: reads from the pending queue, debounces,
-	// processes, and moves the file to the processed directory.
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case path := <-pending:
-				// Debounce: give the writer time to finish
+	// Worker goroutines: read from the pending queue, process,
+	// and release the path for later events or queue it again
+	for range opts.workers() {
+		go func() {
+			for {
 				select {
 				case <-ctx.Done():
 					return
-				case <-time.After(documentDelay):
-				}
-
-				// File might have been removed before we got to it
-				if _, err := os.Stat(path); err != nil {
-					continue
-				}
-
-				// Run the full ingestion pipeline (READ → CHUNK → EMBED → DELETE → UPSERT)
-				if err := processOne(ctx, path, opts, embedder, store); err != nil {
-					logger.Printf("processOne %s: %v", path, err)
-					continue
-				}
-
-				// Move the processed file to the "processed" directory
-				base := filepath.Base(path)
-				dest := filepath.Join(opts.ProcessedDir, base)
-				if err := os.Rename(path, dest); err != nil {
-					logger.Printf("rename %s → %s: %v", path, dest, err)
-					continue
+				case path := <-w.pending:
+					w.start(path)
+					w.process(path)
+					w.release(path)
 				}
-
-				logger.Printf("processed %s → %s", path, dest)
 			}
-		}
-	}()
+		}()
+	}
 
 	// Main event loop
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case event, ok := <-watcher.Events:
+		case event, ok := <-fsw.Events:
 			if !ok {
 				return nil
 			}
-			// React to Create or Write events for regular files (not directories)
-			if event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
-				if info, err := os.Stat(event.Name); err == nil && !info.IsDir() {
-					select {
-					case pending <- event.Name:
-					default:
-						logger.Printf("pending queue full, skipping %s", event.Name)
-					}
-				}
-			}
-		case err, ok := <-watcher.Errors:
+			w.handle(event)
+		case err, ok := <-fsw.Errors:
 			if !ok {
 				return nil
 			}
@@ -148,61 +146,345 @@ func Watch(
 	}
 }
 
-// batchProcessExisting scans the source directory for files that were already
-// there before the watcher started, processes them concurrently, and moves
-// successful ones to the processed directory.
-func batchProcessExisting(
-	ctx context.Context,
-	sourceDir string,
-	processedDir string,
-	opts Options,
-	embedder llm.Embedder,
-	store vector.Store,
-	logger *log.Logger,
-) error {
-	entries, err := os.ReadDir(sourceDir)
+// treeWatcher is the state shared by the event loop and the workers
+type treeWatcher struct {
+	ctx      context.Context
+	opts     Options
+	embedder llm.Embedder
+	store    vector.Store
+	logger   *log.Logger
+	fsw      *fsnotify.Watcher
+
+	sourceDir string
+	// processedDir is empty when files are kept in place
+	processedDir string
+
+	pending chan string
+
+	mu sync.Mutex
+	// queued holds the paths waiting in pending or being processed, so a
+	// burst of write events for one file doesn't hand it to two workers
+	queued map[string]bool
+	// dirty holds the queued paths that changed after a worker started on
+	// them, they are queued again once it is done
+	dirty map[string]bool
+	// moving holds the paths we are moving to the processed tree, their
+	// rename events must not delete what was just ingested
+	moving map[string]bool
+}
+
+// handle reacts to one file system event
+func (w *treeWatcher) handle(event fsnotify.Event) {
+	path := event.Name
+	inProcessed := w.processedDir != "" && within(w.processedDir, path)
+
+	switch {
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		w.mu.Lock()
+		ours := w.moving[path]
+		delete(w.moving, path)
+		w.mu.Unlock()
+		if !ours {
+			root := w.sourceDir
+			if inProcessed {
+				root = w.processedDir
+			}
+			// editors save by renaming the old file away and writing a
+			// new one, only a path still missing after the delay is gone
+			go func() {
+				select {
+				case <-w.ctx.Done():
+					return
+				case <-time.After(documentDelay):
+				}
+				if _, err := os.Stat(path); err == nil {
+					return
+				}
+				w.remove(root, path)
+			}()
+		}
+
+	case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
+		info, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			// new folders are watched too, files copied in with them
+			// may have landed before the watch was added
+			if event.Op&fsnotify.Create != 0 {
+				if err := w.addTree(path); err != nil {
+					w.logger.Printf("watch %s: %v", path, err)
+				}
+				if !inProcessed {
+					w.enqueueTree(path)
+				}
+			}
+			return
+		}
+		// files arriving in the processed tree are our own moves
+		if !inProcessed {
+			w.enqueue(path)
+		}
+	}
+}
+
+// addTree watches dir and every folder below it, except the processed tree
+// when it lives inside the source tree
+func (w *treeWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.processedDir != "" && path == w.processedDir && dir != w.processedDir {
+			return filepath.SkipDir
+		}
+		return w.fsw.Add(path)
+	})
+}
+
+// supportedFiles lists the files below dir the loaders can read,
+// skipping the processed tree
+func (w *treeWatcher) supportedFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if w.processedDir != "" && path == w.processedDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if loader.Supported(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func (w *treeWatcher) enqueueTree(dir string) {
+	paths, err := w.supportedFiles(dir)
 	if err != nil {
-		return fmt.Errorf("read dir %s: %w", sourceDir, err)
+		w.logger.Printf("scan %s: %v", dir, err)
 	}
+	for _, p := range paths {
+		w.enqueue(p)
+	}
+}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var errs []error
+// errQueueFull is recorded in the ledger for files the workers could not take
+var errQueueFull = errors.New("pending queue full")
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+// enqueue hands a file to the workers. A file already queued is marked
+// dirty instead, so a change saved while it is ingested is not lost
+func (w *treeWatcher) enqueue(path string) {
+	if !loader.Supported(path) {
+		return
+	}
+	w.mu.Lock()
+	already := w.queued[path]
+	w.queued[path] = true
+	if already {
+		w.dirty[path] = true
+	}
+	w.mu.Unlock()
+	if already {
+		return
+	}
+
+	w.markPending(path)
+	w.send(path)
+}
+
+func (w *treeWatcher) send(path string) {
+	select {
+	case w.pending <- path:
+	default:
+		w.mu.Lock()
+		delete(w.queued, path)
+		delete(w.dirty, path)
+		w.mu.Unlock()
+		w.logger.Printf("%v, skipping %s", errQueueFull, path)
+		w.markFailed(path, errQueueFull)
+	}
+}
+
+// start clears the changes seen while the path waited, the worker reads
+// the file after them
+func (w *treeWatcher) start(path string) {
+	w.mu.Lock()
+	delete(w.dirty, path)
+	w.mu.Unlock()
+}
+
+// release frees the path for later events, or queues it again when it
+// changed while the worker was on it
+func (w *treeWatcher) release(path string) {
+	w.mu.Lock()
+	again := w.dirty[path]
+	delete(w.dirty, path)
+	if !again {
+		delete(w.queued, path)
+	}
+	w.mu.Unlock()
+	if again {
+		w.markPending(path)
+		w.send(path)
+	}
+}
+
+// relSource returns the key a file is stored under, its path relative to root
+func relSource(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return CleanSource(rel), true
+}
+
+func within(root, path string) bool {
+	_, ok := relSource(root, path)
+	return ok
+}
+
+// process debounces, ingests and moves one queued file
+func (w *treeWatcher) process(path string) {
+	// Debounce: give the writer time to finish
+	select {
+	case <-w.ctx.Done():
+		return
+	case <-time.After(documentDelay):
+	}
+
+	// File might have been removed before we got to it
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+
+	if err := w.ingest(path); err != nil {
+		w.logger.Printf("processOne %s: %v", path, err)
+		return
+	}
+	if w.processedDir == "" {
+		w.logger.Printf("processed %s", path)
+	}
+}
+
+// ingest runs the full ingestion pipeline (READ → CHUNK → EMBED → UPSERT → DELETE)
+// on a file and moves it to the processed tree
+func (w *treeWatcher) ingest(path string) error {
+	src, ok := relSource(w.sourceDir, path)
+	if !ok {
+		return fmt.Errorf("%s is outside %s", path, w.sourceDir)
+	}
+	if err := processOne(w.ctx, path, src, w.opts, w.embedder, w.store); err != nil {
+		return err
+	}
+	if w.processedDir == "" {
+		return nil
+	}
+
+	// Move the processed file to the same relative path in the "processed" directory
+	dest := filepath.Join(w.processedDir, filepath.FromSlash(src))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", filepath.Dir(dest), err)
+	}
+	w.mu.Lock()
+	w.moving[path] = true
+	w.mu.Unlock()
+	if err := os.Rename(path, dest); err != nil {
+		w.mu.Lock()
+		delete(w.moving, path)
+		w.mu.Unlock()
+		return fmt.Errorf("rename %s → %s: %w", path, dest, err)
+	}
+
+	w.logger.Printf("processed %s → %s", path, dest)
+	return nil
+}
+
+// remove deletes the chunks of a removed or renamed path. The path may have
+// been a folder, then every source below it goes
+func (w *treeWatcher) remove(root, path string) {
+	src, ok := relSource(root, path)
+	if !ok {
+		return
+	}
+
+	var sources []string
+	if loader.Supported(src) {
+		sources = append(sources, src)
+	} else {
+		stored, err := w.store.Sources(w.ctx)
+		if err != nil {
+			w.logger.Printf("list sources: %v", err)
+			return
 		}
+		for _, info := range stored {
+			if strings.HasPrefix(info.Source, src+"/") {
+				sources = append(sources, info.Source)
+			}
+		}
+	}
 
-		path := filepath.Join(sourceDir, entry.Name())
-		if !supportedFormat(path) {
+	for _, s := range sources {
+		if err := w.store.DeleteBySource(w.ctx, s); err != nil {
+			w.logger.Printf("delete %s: %v", s, err)
 			continue
 		}
+		if w.opts.Ledger != nil {
+			if err := w.opts.Ledger.Remove(s); err != nil {
+				w.logger.Printf("ledger: %v", err)
+			}
+		}
+		w.logger.Printf("removed %s", s)
+	}
+}
 
-		wg.Add(1)
-		go func(p string) {
-			defer wg.Done()
+// batchProcessExisting scans the source tree for files that were already
+// there before the watcher started, processes them with opts.Workers workers,
+// and moves successful ones to the processed directory.
+func (w *treeWatcher) batchProcessExisting() error {
+	paths, err := w.supportedFiles(w.sourceDir)
+	if err != nil {
+		return fmt.Errorf("read dir %s: %w", w.sourceDir, err)
+	}
+	for _, p := range paths {
+		w.markPending(p)
+	}
 
-			if err := processOne(ctx, p, opts, embedder, store); err != nil {
-				mu.Lock()
-				errs = append(errs, fmt.Errorf("%s: %w", p, err))
-				mu.Unlock()
-				return
-			}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
 
-			base := filepath.Base(p)
-			dest := filepath.Join(processedDir, base)
-			if err := os.Rename(p, dest); err != nil {
-				mu.Lock()
-				errs = append(errs, fmt.Errorf("rename %s: %w", p, err))
-				mu.Unlock()
-				return
+	jobs := make(chan string)
+	for range min(w.opts.workers(), len(paths)) {
+		wg.Go(func() {
+			for p := range jobs {
+				if err := w.ingest(p); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %w", p, err))
+					mu.Unlock()
+				}
 			}
-
-			logger.Printf("batch processed %s → %s", p, dest)
-		}(path)
+		})
 	}
 
+feed:
+	for _, p := range paths {
+		select {
+		case jobs <- p:
+		case <-w.ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
 	wg.Wait()
 
 	if len(errs) > 0 {
@@ -211,3 +493,29 @@ func batchProcessExisting(
 
 	return nil
 }
+
+// markPending records a queued file in the ledger, so the status command
+// shows it before a worker picks it up
+func (w *treeWatcher) markPending(path string) {
+	w.mark(path, (*Ledger).MarkPending)
+}
+
+// markFailed records a file that was dropped before a worker got to it
+func (w *treeWatcher) markFailed(path string, err error) {
+	w.mark(path, func(l *Ledger, src string) error {
+		return l.MarkFailed(src, err)
+	})
+}
+
+func (w *treeWatcher) mark(path string, update func(*Ledger, string) error) {
+	if w.opts.Ledger == nil {
+		return
+	}
+	src, ok := relSource(w.sourceDir, path)
+	if !ok {
+		return
+	}
+	if err := update(w.opts.Ledger, src); err != nil {
+		w.logger.Printf("ledger: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -11,18 +12,68 @@ import (
 	"github.com/dkr290/go-advanced-projects/go-rag-api/config"
 )
 
+const usage = `usage: rag [command]
+
+commands:
+  chat    interactive chat REPL (default), --session <name> resumes a saved conversation
+  serve   HTTP API server
+  eval    retrieval metrics for a dataset (eval --help for the flags)
+  status  ingest status of every file (--failed for failures only)
+`
+
 func main() {
 	// We neeed to
 	// - setup the app
 	// - set up config
 	// - set up llm client
-	// - set up Read-Eval-Print loop (REPL)
+	// - set up Read-Eval-Print loop (REPL) or the HTTP API
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
 	defer stop()
-	if err := app.Run(ctx, config.Load()); err != nil {
 
+	command := "chat"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var err error
+	switch command {
+	case "chat":
+		cfg := config.Load()
+		flags := flag.NewFlagSet("chat", flag.ExitOnError)
+		flags.StringVar(&cfg.ChatSession, "session", cfg.ChatSession, "named session to resume and save to")
+		_ = flags.Parse(os.Args[min(2, len(os.Args)):])
+		err = app.Run(ctx, cfg)
+	case "serve":
+		err = app.Serve(ctx, config.Load())
+	case "eval":
+		var opts app.EvalOptions
+		flags := flag.NewFlagSet("eval", flag.ExitOnError)
+		flags.StringVar(&opts.Dataset, "dataset", "", "JSONL file of {question, sources, answer} cases (required)")
+		flags.StringVar(&opts.Docs, "docs", "", "ingest this directory into a throwaway local store and evaluate it")
+		flags.IntVar(&opts.K, "k", 0, "metric cut-off, defaults to RETRIEVAL_TOP_K")
+		flags.BoolVar(&opts.Judge, "judge", false, "answer every question and have the chat model rate faithfulness")
+		flags.BoolVar(&opts.Stub, "stub", false, "offline stub embedder and chat, needs --docs")
+		flags.BoolVar(&opts.JSON, "json", false, "print the report as JSON")
+		_ = flags.Parse(os.Args[2:])
+		if opts.Dataset == "" {
+			fmt.Fprintln(os.Stderr, "eval: --dataset is required")
+			flags.Usage()
+			os.Exit(2)
+		}
+		err = app.Eval(ctx, config.Load(), opts, os.Stdout)
+	case "status":
+		failedOnly := len(os.Args) > 2 && os.Args[2] == "--failed"
+		err = app.Status(config.Load(), os.Stdout, failedOnly)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -5,7 +5,9 @@ CREATE TABLE documents (
     content text NOT NULL,
     metadata jsonb NOT NULL DEFAULT '{}'::jsonb,
     embedding vector(768) NOT NULL,
-    created_at timestamp with time zone NOT NULL DEFAULT now()
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    content_tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', content)) STORED
 );
 
 CREATE INDEX documents_embedding_idx ON documents USING hnsw (embedding vector_cosine_ops);
+CREATE INDEX documents_content_tsv_idx ON documents USING gin (content_tsv);
@@ -0,0 +1,77 @@
+// Package eval measures retrieval quality against a dataset of questions
+// with known source documents, so chunking and embedding changes can be
+// compared by numbers instead of by feel
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/chunk"
+)
+
+// Case is one question of a dataset
+type Case struct {
+	Question string `json:"question"`
+	// Sources are the documents that answer the question, named like the
+	// stored sources: the path relative to the ingest directory
+	Sources []string `json:"sources"`
+	// Answer is an optional reference answer given to the faithfulness judge
+	Answer string `json:"answer,omitempty"`
+}
+
+// LoadDataset reads the cases of path, a JSON array when the file ends in
+// .json and one JSON object per line otherwise (JSONL, blank lines and lines
+// starting with # are skipped)
+func LoadDataset(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read dataset: %w", err)
+	}
+
+	var cases []Case
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &cases); err != nil {
+			return nil, fmt.Errorf("parse dataset %s: %w", path, err)
+		}
+	} else {
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			text := strings.TrimSpace(sc.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			var c Case
+			if err := json.Unmarshal([]byte(text), &c); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			cases = append(cases, c)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read dataset: %w", err)
+		}
+	}
+
+	for i, c := range cases {
+		if strings.TrimSpace(c.Question) == "" {
+			return nil, fmt.Errorf("%s: case %d has no question", path, i+1)
+		}
+		if len(c.Sources) == 0 {
+			return nil, fmt.Errorf("%s: case %d (%q) has no expected sources", path, i+1, c.Question)
+		}
+		for j, s := range c.Sources {
+			cases[i].Sources[j] = chunk.CleanSource(s)
+		}
+	}
+	if len(cases) == 0 {
+		return nil, errors.New("dataset has no cases")
+	}
+	return cases, nil
+}
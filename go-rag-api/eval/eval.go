@@ -0,0 +1,244 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+const defaultK = 5
+
+const judgePrompt = `You check whether an answer is faithful to the excerpts it was written from.
+Rate from 0 (contradicts or is not supported by the excerpts) to 1 (every claim is supported).
+When a reference answer is given, an answer that disagrees with it is not faithful.
+Reply with only the number, e.g. 0.8.`
+
+var number = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// Evaluator runs every case through retrieval and scores the ranked sources
+type Evaluator struct {
+	Retriever *rag.Retriever
+	// K is the cut-off of the metrics and the chunks retrieved, default 5
+	K int
+	// Chat answers the questions and Judge rates the answers for faithfulness,
+	// faithfulness is skipped when either is nil
+	Chat  llm.Chatter
+	Judge llm.Chatter
+	// SystemPrompt starts the conversation of every answer
+	SystemPrompt []llm.Message
+}
+
+// Result is the outcome of one case
+type Result struct {
+	Question string   `json:"question"`
+	Expected []string `json:"expected"`
+	// Retrieved are the distinct sources of the retrieved chunks, in order
+	Retrieved []string `json:"retrieved"`
+	Recall    float64  `json:"recall"`
+	RR        float64  `json:"reciprocal_rank"`
+	NDCG      float64  `json:"ndcg"`
+	// Faithfulness is the judge's rating, nil when the answer was not judged
+	Faithfulness *float64 `json:"faithfulness,omitempty"`
+	Answer       string   `json:"answer,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Report holds the results and their averages, a failed case counts as 0
+type Report struct {
+	K       int      `json:"k"`
+	Cases   int      `json:"cases"`
+	Failed  int      `json:"failed"`
+	Recall  float64  `json:"recall"`
+	MRR     float64  `json:"mrr"`
+	NDCG    float64  `json:"ndcg"`
+	Judged  int      `json:"judged"`
+	Faith   float64  `json:"faithfulness"`
+	Results []Result `json:"results"`
+}
+
+// Run evaluates the cases, a case that fails is recorded and the run goes on.
+// The error is only set when ctx is cancelled
+func (e Evaluator) Run(ctx context.Context, cases []Case) (Report, error) {
+	k := e.K
+	if k <= 0 {
+		k = defaultK
+	}
+	retriever := *e.Retriever
+	retriever.TopK = k
+
+	report := Report{K: k, Cases: len(cases)}
+	for _, c := range cases {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		res := e.runCase(ctx, &retriever, c, k)
+		if res.Error != "" {
+			report.Failed++
+		}
+		report.Recall += res.Recall
+		report.MRR += res.RR
+		report.NDCG += res.NDCG
+		if res.Faithfulness != nil {
+			report.Judged++
+			report.Faith += *res.Faithfulness
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	n := float64(len(cases))
+	report.Recall /= n
+	report.MRR /= n
+	report.NDCG /= n
+	if report.Judged > 0 {
+		report.Faith /= float64(report.Judged)
+	}
+	return report, nil
+}
+
+func (e Evaluator) runCase(ctx context.Context, retriever *rag.Retriever, c Case, k int) Result {
+	res := Result{Question: c.Question, Expected: c.Sources}
+
+	hits, err := retriever.Retrieve(ctx, c.Question)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Retrieved = rankedSources(hits)
+
+	relevant := make(map[string]bool, len(c.Sources))
+	for _, s := range c.Sources {
+		relevant[s] = true
+	}
+	res.Recall = RecallAt(res.Retrieved, relevant, k)
+	res.RR = ReciprocalRank(res.Retrieved, relevant, k)
+	res.NDCG = NDCGAt(res.Retrieved, relevant, k)
+
+	if e.Chat == nil || e.Judge == nil {
+		return res
+	}
+	answer, score, err := e.faithfulness(ctx, c, hits)
+	res.Answer = answer
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Faithfulness = &score
+	return res
+}
+
+// faithfulness answers the question from the hits and has the judge rate the answer
+func (e Evaluator) faithfulness(ctx context.Context, c Case, hits []vector.Result) (string, float64, error) {
+	reply, err := e.Chat.ChatStream(ctx, rag.Augment(e.SystemPrompt, c.Question, hits), nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("answer: %w", err)
+	}
+	answer := strings.TrimSpace(reply.Content)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Question: %s\n\n", c.Question)
+	sb.WriteString("Excerpts:\n")
+	for i, h := range hits {
+		fmt.Fprintf(&sb, "[%d] %s\n", i+1, h.Content)
+	}
+	if c.Answer != "" {
+		fmt.Fprintf(&sb, "\nReference answer: %s\n", c.Answer)
+	}
+	fmt.Fprintf(&sb, "\nAnswer to rate: %s", answer)
+
+	verdict, err := e.Judge.ChatStream(ctx, []llm.Message{
+		{Role: "system", Content: judgePrompt},
+		{Role: "user", Content: sb.String()},
+	}, nil)
+	if err != nil {
+		return answer, 0, fmt.Errorf("judge: %w", err)
+	}
+	score, err := parseRating(verdict.Content)
+	if err != nil {
+		return answer, 0, fmt.Errorf("judge: %w", err)
+	}
+	return answer, score, nil
+}
+
+// parseRating reads the first number of the reply, clamped to 0..1
+func parseRating(reply string) (float64, error) {
+	m := number.FindString(reply)
+	if m == "" {
+		return 0, fmt.Errorf("no rating in reply %q", reply)
+	}
+	f, err := strconv.ParseFloat(m, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse rating: %w", err)
+	}
+	return min(max(f, 0), 1), nil
+}
+
+// rankedSources returns the distinct sources of the hits in retrieval order
+func rankedSources(hits []vector.Result) []string {
+	seen := make(map[string]bool, len(hits))
+	var sources []string
+	for _, h := range hits {
+		s := h.Metadata["source"]
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		sources = append(sources, s)
+	}
+	return sources
+}
+
+// WriteTable prints one row per case and the averages
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "QUESTION\tRECALL@%d\tRR\tNDCG@%d\tFAITH\tRETRIEVED\tERROR\n", r.K, r.K)
+	for _, res := range r.Results {
+		faith := "-"
+		if res.Faithfulness != nil {
+			faith = fmt.Sprintf("%.2f", *res.Faithfulness)
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.2f\t%s\t%s\t%s\n",
+			truncate(res.Question, 60),
+			res.Recall,
+			res.RR,
+			res.NDCG,
+			faith,
+			strings.Join(res.Retrieved, ", "),
+			res.Error,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\ncases=%d failed=%d recall@%d=%.3f mrr=%.3f ndcg@%d=%.3f",
+		r.Cases, r.Failed, r.K, r.Recall, r.MRR, r.K, r.NDCG)
+	if r.Judged > 0 {
+		fmt.Fprintf(w, " faithfulness=%.3f (%d judged)", r.Faith, r.Judged)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// WriteJSON prints the report as indented JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector/local"
+)
+
+const stubDim = 256
+
+// newRetriever stores one chunk per source, embedded with the stub
+func newRetriever(t *testing.T, docs map[string]string) *rag.Retriever {
+	t.Helper()
+	ctx := context.Background()
+	store, err := local.New(local.Options{Path: filepath.Join(t.TempDir(), "vectors.gob"), EmbeddingDim: stubDim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	embedder := llm.Stub{Dim: stubDim}
+	var batch []vector.Document
+	for source, text := range docs {
+		vectors, err := embedder.Embed(ctx, []string{text}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch = append(batch, vector.Document{
+			ID:        source + "#0",
+			Content:   text,
+			Metadata:  map[string]string{"source": source},
+			Embedding: vectors[0],
+		})
+	}
+	if err := store.Upsert(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	return &rag.Retriever{Embedder: embedder, Store: store}
+}
+
+var animals = map[string]string{
+	"cats.md": "cats purr and chase mice at night",
+	"dogs.md": "dogs bark at the mailman and fetch sticks",
+	"fish.md": "fish swim in water tanks and eat flakes",
+}
+
+func TestEvaluatorRun(t *testing.T) {
+	e := Evaluator{Retriever: newRetriever(t, animals), K: 2}
+	report, err := e.Run(context.Background(), []Case{
+		{Question: "why do cats purr and chase mice", Sources: []string{"cats.md"}},
+		{Question: "which dogs bark and fetch sticks", Sources: []string{"dogs.md", "fish.md"}},
+		{Question: "who wrote the manual", Sources: []string{"manual.md"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.K != 2 || report.Cases != 3 || report.Failed != 0 || report.Judged != 0 {
+		t.Fatalf("report %+v", report)
+	}
+
+	first := report.Results[0]
+	if len(first.Retrieved) != 2 || first.Retrieved[0] != "cats.md" {
+		t.Fatalf("retrieved %q", first.Retrieved)
+	}
+	if first.Recall != 1 || first.RR != 1 || first.NDCG != 1 {
+		t.Errorf("first case %+v", first)
+	}
+	// dogs.md is found first, fish.md only when it makes the top 2
+	second := report.Results[1]
+	if second.Retrieved[0] != "dogs.md" || second.RR != 1 {
+		t.Errorf("second case %+v", second)
+	}
+	if third := report.Results[2]; third.Recall != 0 || third.RR != 0 || third.NDCG != 0 {
+		t.Errorf("third case %+v", third)
+	}
+
+	var recall, mrr, ndcg float64
+	for _, r := range report.Results {
+		recall += r.Recall
+		mrr += r.RR
+		ndcg += r.NDCG
+	}
+	if !near(report.Recall, recall/3) || !near(report.MRR, mrr/3) || !near(report.NDCG, ndcg/3) {
+		t.Errorf("averages %+v", report)
+	}
+}
+
+func TestEvaluatorFaithfulness(t *testing.T) {
+	cases := []Case{{Question: "why do cats purr", Sources: []string{"cats.md"}}}
+
+	e := Evaluator{
+		Retriever: newRetriever(t, animals),
+		Chat:      llm.Stub{Reply: "Cats purr."},
+		Judge:     llm.Stub{Reply: "Rating: 0.75"},
+	}
+	report, err := e.Run(context.Background(), cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := report.Results[0]
+	if report.K != defaultK || report.Judged != 1 || report.Faith != 0.75 || res.Answer != "Cats purr." {
+		t.Fatalf("report %+v, result %+v", report, res)
+	}
+
+	// an unreadable verdict fails the case, the retrieval scores stay
+	e.Judge = llm.Stub{Reply: "looks fine to me"}
+	report, err = e.Run(context.Background(), cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = report.Results[0]
+	if report.Failed != 1 || report.Judged != 0 || res.Faithfulness != nil || res.RR != 1 ||
+		!strings.Contains(res.Error, "no rating") {
+		t.Errorf("report %+v, result %+v", report, res)
+	}
+}
+
+func TestRankedSources(t *testing.T) {
+	hits := []vector.Result{
+		{Document: vector.Document{ID: "b#0", Metadata: map[string]string{"source": "b"}}, Score: 0.9},
+		{Document: vector.Document{ID: "a#0", Metadata: map[string]string{"source": "a"}}, Score: 0.8},
+		{Document: vector.Document{ID: "b#1", Metadata: map[string]string{"source": "b"}}, Score: 0.8},
+		{Document: vector.Document{ID: "x", Metadata: map[string]string{}}, Score: 0.7},
+	}
+	if got := rankedSources(hits); !slices.Equal(got, []string{"b", "a"}) {
+		t.Errorf("rankedSources = %q", got)
+	}
+}
+
+func TestParseRating(t *testing.T) {
+	for reply, want := range map[string]float64{"0.8": 0.8, "Score: 1": 1, "7/10": 1, "-0.5": 0.5} {
+		got, err := parseRating(reply)
+		if err != nil || got != want {
+			t.Errorf("parseRating(%q) = %v, %v, want %v", reply, got, err, want)
+		}
+	}
+	if _, err := parseRating("good"); err == nil {
+		t.Error("expected an error without a number")
+	}
+}
@@ -0,0 +1,54 @@
+package eval
+
+import "math"
+
+// The metrics work on documents, not chunks: ranked is the list of distinct
+// sources in retrieval order and relevance is binary, a source either is
+// one of the expected ones or not
+
+// RecallAt is the share of the relevant sources found in the first k
+func RecallAt(ranked []string, relevant map[string]bool, k int) float64 {
+	if len(relevant) == 0 {
+		return 0
+	}
+	found := 0
+	for _, s := range ranked[:min(k, len(ranked))] {
+		if relevant[s] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(relevant))
+}
+
+// ReciprocalRank is 1/rank of the first relevant source in the first k, 0 when there is none.
+// Averaged over the dataset it is the MRR
+func ReciprocalRank(ranked []string, relevant map[string]bool, k int) float64 {
+	for i, s := range ranked[:min(k, len(ranked))] {
+		if relevant[s] {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// NDCGAt is the discounted cumulative gain of the first k against the best
+// possible ranking, 1 when all relevant sources come first
+func NDCGAt(ranked []string, relevant map[string]bool, k int) float64 {
+	var dcg, ideal float64
+	for i, s := range ranked[:min(k, len(ranked))] {
+		if relevant[s] {
+			dcg += discount(i)
+		}
+	}
+	for i := range min(k, len(relevant)) {
+		ideal += discount(i)
+	}
+	if ideal == 0 {
+		return 0
+	}
+	return dcg / ideal
+}
+
+func discount(rank int) float64 {
+	return 1 / math.Log2(float64(rank+2))
+}
@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"math"
+	"testing"
+)
+
+func set(sources ...string) map[string]bool {
+	m := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		m[s] = true
+	}
+	return m
+}
+
+// log2(3) and log2(5) discount the relevant sources at ranks 2 and 4
+var (
+	d2 = 1 / math.Log2(3)
+	d4 = 1 / math.Log2(5)
+)
+
+func TestMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranked   []string
+		relevant map[string]bool
+		k        int
+		recall   float64
+		rr       float64
+		ndcg     float64
+	}{
+		{
+			name:     "relevant at ranks 2 and 4",
+			ranked:   []string{"a", "b", "c", "d"},
+			relevant: set("b", "d"),
+			k:        4,
+			recall:   1,
+			rr:       0.5,
+			ndcg:     (d2 + d4) / (1 + d2),
+		},
+		{
+			name:     "cut-off drops the second hit",
+			ranked:   []string{"a", "b", "c", "d"},
+			relevant: set("b", "d"),
+			k:        2,
+			recall:   0.5,
+			rr:       0.5,
+			ndcg:     d2 / (1 + d2),
+		},
+		{
+			name:     "nothing relevant in the first k",
+			ranked:   []string{"a", "b", "c", "d"},
+			relevant: set("b", "d"),
+			k:        1,
+		},
+		{
+			// the relevant sources are tied, their order among themselves
+			// does not change the scores
+			name:     "relevant sources first",
+			ranked:   []string{"d", "b", "a"},
+			relevant: set("b", "d"),
+			k:        3,
+			recall:   1,
+			rr:       1,
+			ndcg:     1,
+		},
+		{
+			name:     "relevant sources first, swapped",
+			ranked:   []string{"b", "d", "a"},
+			relevant: set("b", "d"),
+			k:        3,
+			recall:   1,
+			rr:       1,
+			ndcg:     1,
+		},
+		{
+			name:     "k beyond the ranking",
+			ranked:   []string{"x", "a"},
+			relevant: set("a"),
+			k:        10,
+			recall:   1,
+			rr:       0.5,
+			ndcg:     d2,
+		},
+		{
+			// the ideal ranking has both relevant sources, only one was retrieved
+			name:     "relevant source never retrieved",
+			ranked:   []string{"a"},
+			relevant: set("a", "z"),
+			k:        5,
+			recall:   0.5,
+			rr:       1,
+			ndcg:     1 / (1 + d2),
+		},
+		{
+			name:     "no relevant sources",
+			ranked:   []string{"a", "b"},
+			relevant: set(),
+			k:        5,
+		},
+		{
+			name:     "nothing retrieved",
+			relevant: set("a"),
+			k:        5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RecallAt(tt.ranked, tt.relevant, tt.k); !near(got, tt.recall) {
+				t.Errorf("RecallAt = %v, want %v", got, tt.recall)
+			}
+			if got := ReciprocalRank(tt.ranked, tt.relevant, tt.k); !near(got, tt.rr) {
+				t.Errorf("ReciprocalRank = %v, want %v", got, tt.rr)
+			}
+			if got := NDCGAt(tt.ranked, tt.relevant, tt.k); !near(got, tt.ndcg) {
+				t.Errorf("NDCGAt = %v, want %v", got, tt.ndcg)
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
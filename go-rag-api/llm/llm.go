@@ -15,6 +15,11 @@ type Message struct {
 	Content string `json:"content"`
 }
 
+// Chatter streams a chat completion, onDelta receives every token as it arrives
+type Chatter interface {
+	ChatStream(ctx context.Context, messages []Message, onDelta func(string)) (Message, error)
+}
+
 type Client struct {
 	cfg config.Config
 	chatSDK openai.Client
@@ -49,6 +54,18 @@ func New(cfg config.Config) *Client {
 	}
 }
 
+// Model is the chat model used for completions
+func (c *Client) Model() string {
+	return c.cfg.ChatModel
+}
+
+// WithModel returns a client sharing the connections of c that chats with model
+func (c *Client) WithModel(model string) *Client {
+	clone := *c
+	clone.cfg.ChatModel = model
+	return &clone
+}
+
 func (c *Client) ChatStream(
 	ctx context.Context,
 	messages []Message,
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Stub is an offline Embedder and Chatter for tests and evaluation runs
+// without a model server. Embeddings hash the words of a text into Dim
+// buckets (feature hashing), so texts sharing words are similar.
+// Chat replies with Reply, or echoes the last user message when it is empty
+type Stub struct {
+	Dim   int
+	Reply string
+}
+
+func (s Stub) Embed(_ context.Context, texts []string, _ bool) ([][]float32, error) {
+	if s.Dim <= 0 {
+		return nil, errors.New("stub embedder: Dim must be > 0")
+	}
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = s.embed(t)
+	}
+	return out, nil
+}
+
+func (s Stub) embed(text string) []float32 {
+	v := make([]float32, s.Dim)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		h := fnv.New32a()
+		h.Write([]byte(w))
+		v[h.Sum32()%uint32(s.Dim)]++
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range v {
+			v[i] *= scale
+		}
+	}
+	return v
+}
+
+func (s Stub) ChatStream(_ context.Context, messages []Message, onDelta func(string)) (Message, error) {
+	reply := s.Reply
+	if reply == "" {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "user" {
+				reply = messages[i].Content
+				break
+			}
+		}
+	}
+	if onDelta != nil {
+		onDelta(reply)
+	}
+	return Message{Role: "assistant", Content: reply}, nil
+}
@@ -0,0 +1,54 @@
+package loader
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// codeLanguages maps the source file extensions we ingest to a language name
+var codeLanguages = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".java":  "java",
+	".kt":    "kotlin",
+	".rs":    "rust",
+	".c":     "c",
+	".h":     "c",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".rb":    "ruby",
+	".php":   "php",
+	".swift": "swift",
+	".scala": "scala",
+	".sh":    "shell",
+	".sql":   "sql",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".toml":  "toml",
+	".proto": "protobuf",
+}
+
+// Code loads a source file as one section starting at line 1, so every
+// chunk gets the line range it covers
+type Code struct {
+	Language string
+}
+
+func (c Code) Load(name string, content []byte) (Document, error) {
+	if !utf8.Valid(content) {
+		return Document{}, errors.New("content is not valid UTF-8")
+	}
+	return Document{
+		Format: c.Language,
+		Sections: []Section{{
+			Text:      string(content),
+			StartLine: 1,
+			Metadata:  map[string]string{MetaLanguage: c.Language},
+		}},
+	}, nil
+}
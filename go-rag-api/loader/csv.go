@@ -0,0 +1,83 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvRowsPerSection is how many records go into one section
+const csvRowsPerSection = 50
+
+// CSV loads a table as "column: value" lines, the first record is the header.
+// Records are grouped into sections carrying their row range
+type CSV struct{}
+
+func (CSV) Load(name string, content []byte) (Document, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err != nil {
+		return Document{}, fmt.Errorf("read header: %w", err)
+	}
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+	}
+
+	doc := Document{Format: "csv"}
+	var buf strings.Builder
+	firstRow, row := 0, 1
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		doc.Sections = append(doc.Sections, Section{
+			Text: strings.TrimSpace(buf.String()),
+			Metadata: map[string]string{
+				MetaRows:  fmt.Sprintf("%d-%d", firstRow, row),
+				"columns": strings.Join(header, ","),
+			},
+		})
+		buf.Reset()
+	}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Document{}, fmt.Errorf("read row %d: %w", row+1, err)
+		}
+		row++
+		if buf.Len() == 0 {
+			firstRow = row
+		}
+
+		fields := make([]string, 0, len(record))
+		for i, v := range record {
+			v = strings.Join(strings.Fields(v), " ")
+			if v == "" {
+				continue
+			}
+			col := fmt.Sprintf("column %d", i+1)
+			if i < len(header) && header[i] != "" {
+				col = header[i]
+			}
+			fields = append(fields, col+": "+v)
+		}
+		buf.WriteString(strings.Join(fields, "; "))
+		buf.WriteByte('\n')
+
+		if row-firstRow+1 >= csvRowsPerSection {
+			flush()
+		}
+	}
+	flush()
+	return doc, nil
+}
@@ -0,0 +1,177 @@
+package loader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DOCX loads a Word document, paragraphs styled as headings (Heading1..9
+// or Title) start a section with their heading trail
+type DOCX struct{}
+
+func (DOCX) Load(name string, content []byte) (Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return Document{}, fmt.Errorf("open docx: %w", err)
+	}
+
+	body, err := readZipFile(zr, "word/document.xml")
+	if err != nil {
+		return Document{}, err
+	}
+	paragraphs, err := docxParagraphs(body)
+	if err != nil {
+		return Document{}, fmt.Errorf("parse document.xml: %w", err)
+	}
+
+	doc := Document{Format: "docx"}
+	if core, err := readZipFile(zr, "docProps/core.xml"); err == nil {
+		doc.Title = docxTitle(core)
+	}
+
+	var trail headingTrail
+	var buf strings.Builder
+	flush := func() {
+		text := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if text != "" {
+			doc.Sections = append(doc.Sections, Section{Text: text, Metadata: trail.sectionMeta()})
+		}
+	}
+	for _, p := range paragraphs {
+		text := strings.TrimSpace(p.text)
+		if text == "" {
+			continue
+		}
+		if p.headingLevel > 0 {
+			flush()
+			trail = trail.push(p.headingLevel, text)
+			continue
+		}
+		buf.WriteString(text)
+		buf.WriteString("\n")
+	}
+	flush()
+	return doc, nil
+}
+
+type docxParagraph struct {
+	text         string
+	headingLevel int
+}
+
+// docxParagraphs streams the WordprocessingML body into paragraphs
+func docxParagraphs(r io.Reader) ([]docxParagraph, error) {
+	dec := xml.NewDecoder(r)
+	var paragraphs []docxParagraph
+	var cur *docxParagraph
+	var sb strings.Builder
+	inText := false
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return paragraphs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				cur = &docxParagraph{}
+				sb.Reset()
+			case "pStyle":
+				if cur != nil {
+					cur.headingLevel = docxHeadingLevel(attr(t, "val"))
+				}
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteByte('\t')
+			case "br", "cr":
+				sb.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if cur != nil {
+					cur.text = sb.String()
+					paragraphs = append(paragraphs, *cur)
+					cur = nil
+				}
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+}
+
+// docxHeadingLevel maps the built-in paragraph styles to a heading level
+func docxHeadingLevel(style string) int {
+	s := strings.ToLower(style)
+	if s == "title" {
+		return 1
+	}
+	if rest, ok := strings.CutPrefix(s, "heading"); ok {
+		if n, err := strconv.Atoi(rest); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func docxTitle(r io.Reader) string {
+	dec := xml.NewDecoder(r)
+	inTitle := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inTitle = t.Name.Local == "title"
+		case xml.EndElement:
+			inTitle = false
+		case xml.CharData:
+			if inTitle {
+				return strings.TrimSpace(string(t))
+			}
+		}
+	}
+}
+
+func attr(e xml.StartElement, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func readZipFile(zr *zip.Reader, name string) (io.Reader, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,180 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML loads the readable part of a page. Scripts, styles, navigation,
+// headers, footers and forms are dropped, <main> or <article> is preferred
+// over the whole body, and every h1-h6 starts a section with its heading trail
+type HTML struct{}
+
+// boilerplate elements never carry the content of the page
+var boilerplate = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Select:   true,
+}
+
+// blockElements end the current line of text
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.Ul: true, atom.Ol: true, atom.Table: true, atom.Pre: true,
+	atom.Blockquote: true, atom.Section: true, atom.Article: true,
+	atom.Dt: true, atom.Dd: true, atom.Figcaption: true, atom.Hr: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+func (HTML) Load(name string, content []byte) (Document, error) {
+	root, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return Document{}, fmt.Errorf("parse html: %w", err)
+	}
+
+	doc := Document{Format: "html"}
+	if t := findFirst(root, atom.Title); t != nil {
+		doc.Title = collapseSpace(textContent(t))
+	}
+
+	body := findFirst(root, atom.Main)
+	if body == nil {
+		body = findFirst(root, atom.Article)
+	}
+	if body == nil {
+		body = findFirst(root, atom.Body)
+	}
+	if body == nil {
+		body = root
+	}
+
+	w := &htmlWalker{}
+	w.walk(body)
+	w.flush()
+	doc.Sections = w.sections
+	return doc, nil
+}
+
+type htmlWalker struct {
+	trail    headingTrail
+	buf      strings.Builder
+	sections []Section
+	pre      int
+}
+
+func (w *htmlWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre > 0 {
+			w.buf.WriteString(n.Data)
+			return
+		}
+		if text := collapseSpace(n.Data); text != "" {
+			if w.buf.Len() > 0 && !strings.HasSuffix(w.buf.String(), "\n") {
+				w.buf.WriteByte(' ')
+			}
+			w.buf.WriteString(text)
+		}
+		return
+	case html.ElementNode:
+		if boilerplate[n.DataAtom] || hasHiddenAttr(n) {
+			return
+		}
+		if level, ok := headingLevels[n.DataAtom]; ok {
+			w.flush()
+			if text := collapseSpace(textContent(n)); text != "" {
+				w.trail = w.trail.push(level, text)
+			}
+			return
+		}
+		if n.DataAtom == atom.Pre {
+			w.pre++
+			defer func() { w.pre-- }()
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	if n.Type == html.ElementNode && blockElements[n.DataAtom] {
+		w.newline()
+	}
+}
+
+func (w *htmlWalker) newline() {
+	if w.buf.Len() > 0 && !strings.HasSuffix(w.buf.String(), "\n") {
+		w.buf.WriteByte('\n')
+	}
+}
+
+// flush closes the section collected under the current heading trail
+func (w *htmlWalker) flush() {
+	text := strings.TrimSpace(w.buf.String())
+	w.buf.Reset()
+	if text == "" {
+		return
+	}
+	w.sections = append(w.sections, Section{Text: text, Metadata: w.trail.sectionMeta()})
+}
+
+func hasHiddenAttr(n *html.Node) bool {
+	for _, a := range n.Attr {
+		switch {
+		case a.Key == "hidden", a.Key == "aria-hidden" && a.Val == "true":
+			return true
+		case a.Key == "role" && (a.Val == "navigation" || a.Val == "banner" || a.Val == "contentinfo"):
+			return true
+		}
+	}
+	return false
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,136 @@
+// Package loader extracts text and its structure from the document formats
+// the ingestion pipeline accepts
+package loader
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Metadata keys set by the loaders, they end up in vector.Document.Metadata
+const (
+	MetaFormat   = "format"
+	MetaTitle    = "title"
+	MetaLanguage = "language"
+	// MetaPage is the 1-based page number of a PDF
+	MetaPage = "page"
+	// MetaHeading is the heading trail of a section, e.g. "Setup > Install"
+	MetaHeading = "heading"
+	// MetaLines is the line range of a chunk in the source file, e.g. "10-42"
+	MetaLines = "lines"
+	// MetaRows is the record range of a CSV section, e.g. "2-51"
+	MetaRows = "rows"
+)
+
+// HeadingSeparator joins the headings of a heading trail
+const HeadingSeparator = " > "
+
+// Section is a part of a document with the structure it came from
+type Section struct {
+	// Text is the extracted plain text
+	Text string
+	// StartLine is the line of the source file Text starts at,
+	// 0 when lines are meaningless for the format (PDF, DOCX, HTML, CSV)
+	StartLine int
+	// Metadata is the structural metadata of the section (page, heading, rows)
+	Metadata map[string]string
+}
+
+// Document is the result of loading a file
+type Document struct {
+	// Format names the loader used, e.g. "pdf" or "go"
+	Format string
+	// Title is the document title when the format carries one
+	Title    string
+	Sections []Section
+}
+
+// Text returns the text of all sections
+func (d Document) Text() string {
+	parts := make([]string, 0, len(d.Sections))
+	for _, s := range d.Sections {
+		parts = append(parts, s.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Loader extracts a Document from the raw content of a file
+type Loader interface {
+	Load(name string, content []byte) (Document, error)
+}
+
+// loaders maps lower-case file extensions to their Loader
+var loaders = map[string]Loader{
+	".txt":      Text{Format: "text"},
+	".md":       Text{Format: "markdown"},
+	".markdown": Text{Format: "markdown"},
+	".pdf":      PDF{},
+	".html":     HTML{},
+	".htm":      HTML{},
+	".docx":     DOCX{},
+	".csv":      CSV{},
+}
+
+func init() {
+	for ext, lang := range codeLanguages {
+		loaders[ext] = Code{Language: lang}
+	}
+}
+
+// For returns the loader for the file extension of name
+func For(name string) (Loader, bool) {
+	l, ok := loaders[strings.ToLower(filepath.Ext(name))]
+	return l, ok
+}
+
+// Supported reports whether a loader exists for the file extension of name
+func Supported(name string) bool {
+	_, ok := For(name)
+	return ok
+}
+
+// Load extracts the document using the loader matching the file extension
+func Load(name string, content []byte) (Document, error) {
+	l, ok := For(name)
+	if !ok {
+		return Document{}, fmt.Errorf("unsupported format %s", filepath.Ext(name))
+	}
+	doc, err := l.Load(name, content)
+	if err != nil {
+		return Document{}, fmt.Errorf("load %s: %w", filepath.Base(name), err)
+	}
+	return doc, nil
+}
+
+// headingTrail keeps the open headings while walking a document
+type headingTrail []heading
+
+type heading struct {
+	level int
+	text  string
+}
+
+// push opens a heading, closing the ones at the same or a deeper level
+func (t headingTrail) push(level int, text string) headingTrail {
+	for len(t) > 0 && t[len(t)-1].level >= level {
+		t = t[:len(t)-1]
+	}
+	return append(t, heading{level: level, text: text})
+}
+
+func (t headingTrail) String() string {
+	parts := make([]string, len(t))
+	for i, h := range t {
+		parts[i] = h.text
+	}
+	return strings.Join(parts, HeadingSeparator)
+}
+
+// sectionMeta returns the metadata for a section under the trail
+func (t headingTrail) sectionMeta() map[string]string {
+	if len(t) == 0 {
+		return map[string]string{}
+	}
+	return map[string]string{MetaHeading: t.String()}
+}
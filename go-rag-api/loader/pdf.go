@@ -0,0 +1,59 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDF loads one section per page with the page number in the metadata
+type PDF struct{}
+
+func (PDF) Load(name string, content []byte) (doc Document, err error) {
+	// the pdf reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return Document{}, fmt.Errorf("open pdf: %w", err)
+	}
+
+	doc.Format = "pdf"
+	if info := r.Trailer().Key("Info"); !info.IsNull() {
+		doc.Title = strings.TrimSpace(info.Key("Title").Text())
+	}
+
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		// cache fonts so the charmaps are parsed once per document
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+		text, err := p.GetPlainText(fonts)
+		if err != nil {
+			return Document{}, fmt.Errorf("page %d: %w", i, err)
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		doc.Sections = append(doc.Sections, Section{
+			Text:     text,
+			Metadata: map[string]string{MetaPage: strconv.Itoa(i)},
+		})
+	}
+	return doc, nil
+}
@@ -0,0 +1,25 @@
+package loader
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// Text loads plain text and markdown as a single section starting at line 1
+type Text struct {
+	Format string
+}
+
+func (t Text) Load(name string, content []byte) (Document, error) {
+	if !utf8.Valid(content) {
+		return Document{}, errors.New("content is not valid UTF-8")
+	}
+	return Document{
+		Format: t.Format,
+		Sections: []Section{{
+			Text:      string(content),
+			StartLine: 1,
+			Metadata:  map[string]string{},
+		}},
+	}, nil
+}
@@ -0,0 +1,45 @@
+package rag
+
+import (
+	"sort"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// rrfK dampens the weight of the top ranks in reciprocal rank fusion,
+// 60 is the value from the original paper
+const rrfK = 60
+
+// Fuse merges ranked result lists with reciprocal rank fusion: a chunk scores
+// the sum of 1/(rrfK+rank) over the lists it appears in. The fused Score is
+// normalized so 1 means ranked first in every list
+func Fuse(lists ...[]vector.Result) []vector.Result {
+	if len(lists) == 0 {
+		return nil
+	}
+
+	scores := make(map[string]float64)
+	docs := make(map[string]vector.Result)
+	for _, list := range lists {
+		for rank, r := range list {
+			scores[r.ID] += 1 / float64(rrfK+rank+1)
+			if _, ok := docs[r.ID]; !ok {
+				docs[r.ID] = r
+			}
+		}
+	}
+
+	best := float64(len(lists)) / float64(rrfK+1)
+	fused := make([]vector.Result, 0, len(docs))
+	for id, r := range docs {
+		r.Score = float32(scores[id] / best)
+		fused = append(fused, r)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score == fused[j].Score {
+			return fused[i].ID < fused[j].ID
+		}
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
@@ -27,10 +27,14 @@ func formatContext(hits []vector.Result) string {
 			source = unknownSource
 		}
 
+		if loc := Location(h.Metadata); loc != "" {
+			source += " (" + loc + ")"
+		}
+
 		// or this one
 		fmt.Fprintf(
 			&sb,
-			"[%d] Source: %s (similarity %.2f)\n%s\n\n",
+			"[%d] Source: %s (relevance %.2f)\n%s\n\n",
 			i+1,
 			source,
 			h.Score,
@@ -0,0 +1,90 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// Reranker reorders the retrieved chunks by relevance to the question
+// before they are cut to TopK and reach the prompt
+type Reranker interface {
+	Rerank(ctx context.Context, question string, hits []vector.Result) ([]vector.Result, error)
+}
+
+// maxRerankExcerpt caps the characters of each chunk sent to the LLM re-ranker
+const maxRerankExcerpt = 1200
+
+const rerankPrompt = `You rate how useful text passages are for answering a question.
+Score every passage from 0 (irrelevant) to 10 (answers the question directly).
+Reply with only a JSON array of numbers, one score per passage in the given order, e.g. [7, 0, 3].`
+
+// LLMReranker asks a chat model to score every chunk against the question.
+// Score becomes the model's rating scaled to 0..1
+type LLMReranker struct {
+	Chat llm.Chatter
+}
+
+func (l LLMReranker) Rerank(ctx context.Context, question string, hits []vector.Result) ([]vector.Result, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Question: %s\n\n", question)
+	for i, h := range hits {
+		excerpt := []rune(h.Content)
+		if len(excerpt) > maxRerankExcerpt {
+			excerpt = excerpt[:maxRerankExcerpt]
+		}
+		fmt.Fprintf(&sb, "[%d]\n%s\n\n", i+1, string(excerpt))
+	}
+
+	reply, err := l.Chat.ChatStream(ctx, []llm.Message{
+		{Role: "system", Content: rerankPrompt},
+		{Role: "user", Content: strings.TrimSpace(sb.String())},
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+
+	scores, err := parseScores(reply.Content, len(hits))
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+
+	reranked := make([]vector.Result, len(hits))
+	copy(reranked, hits)
+	for i := range reranked {
+		reranked[i].Score = float32(scores[i] / 10)
+	}
+	// stable so equal ratings keep the retrieval order
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+	return reranked, nil
+}
+
+// parseScores reads the JSON array of the reply, tolerating text around it
+func parseScores(reply string, want int) ([]float64, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no score list in reply %q", reply)
+	}
+	var scores []float64
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("parse scores: %w", err)
+	}
+	if len(scores) != want {
+		return nil, fmt.Errorf("got %d scores for %d passages", len(scores), want)
+	}
+	for i, s := range scores {
+		scores[i] = min(max(s, 0), 10)
+	}
+	return scores, nil
+}
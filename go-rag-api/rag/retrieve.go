@@ -0,0 +1,188 @@
+// Package rag holds the retrieval step that grounds chat answers in the document collection
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/loader"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+const defaultTopK = 5
+
+// Retriever embeds a question and looks up the closest chunks in the vector store
+type Retriever struct {
+	Embedder llm.Embedder
+	Store    vector.Store
+	// TopK is the number of chunks requested from the store
+	TopK int
+	// MinScore drops chunks whose similarity is below it
+	MinScore float32
+	// Hybrid adds a full-text query fused with the similarity search,
+	// when the store implements vector.KeywordSearcher
+	Hybrid bool
+	// Filter restricts the chunks searched, e.g. to some sources
+	Filter vector.Filter
+	// Reranker reorders the candidates, nil keeps the retrieval order.
+	// When it fails the retrieval order is kept as well
+	Reranker Reranker
+	// Candidates is how many chunks each search returns for fusion and
+	// re-ranking, defaults to TopK when neither is used and 4*TopK otherwise
+	Candidates int
+	// Logger reports failed re-rankings, nil uses the standard logger
+	Logger *log.Logger
+}
+
+// Source is a document cited in an answer, with the best score of its chunks
+type Source struct {
+	Name string `json:"name"`
+	// Location is where in the document the chunks came from, e.g. "page 3"
+	Location string  `json:"location,omitempty"`
+	Score    float32 `json:"score"`
+}
+
+// Retrieve returns the chunks relevant to the question, most similar first
+func (r *Retriever) Retrieve(ctx context.Context, question string) ([]vector.Result, error) {
+	if r.Embedder == nil || r.Store == nil {
+		return nil, errors.New("retriever needs an embedder and a store")
+	}
+
+	// EMBED: this is a query, so Nomic-style "search_query: " prefixes are applied
+	vectors, err := r.Embedder.Embed(ctx, []string{question}, true)
+	if err != nil {
+		return nil, fmt.Errorf("embed question: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embed got %d vectors for 1 question", len(vectors))
+	}
+
+	topK := r.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	keyword, hybrid := r.Store.(vector.KeywordSearcher)
+	hybrid = hybrid && r.Hybrid
+	candidates := topK
+	if hybrid || r.Reranker != nil {
+		candidates = r.Candidates
+		if candidates < topK {
+			candidates = 4 * topK
+		}
+	}
+
+	hits, err := r.Store.Query(ctx, vectors[0], candidates, r.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("query store: %w", err)
+	}
+
+	// MinScore is a similarity threshold, so it applies before fusion
+	relevant := hits[:0]
+	for _, h := range hits {
+		if h.Score >= r.MinScore {
+			relevant = append(relevant, h)
+		}
+	}
+
+	if hybrid {
+		// keyword matches pass without a threshold, an exact term hit is
+		// what the similarity search tends to miss
+		matches, err := keyword.KeywordQuery(ctx, question, candidates, r.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("keyword query: %w", err)
+		}
+		relevant = Fuse(relevant, matches)
+	}
+
+	if r.Reranker != nil {
+		reranked, err := r.Reranker.Rerank(ctx, question, relevant)
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil:
+			r.logf("%v, keeping the retrieval order", err)
+		default:
+			relevant = reranked
+		}
+	}
+
+	if len(relevant) > topK {
+		relevant = relevant[:topK]
+	}
+	return relevant, nil
+}
+
+func (r *Retriever) logf(format string, args ...any) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Augment returns the messages to send for this turn: the history, the
+// excerpts as an extra system message and the question itself.
+// The history is not modified so the excerpts don't pile up across turns
+func Augment(history []llm.Message, question string, hits []vector.Result) []llm.Message {
+	messages := make([]llm.Message, 0, len(history)+2)
+	messages = append(messages, history...)
+	if excerpts := formatContext(hits); excerpts != "" {
+		messages = append(messages, llm.Message{Role: "system", Content: excerpts})
+	}
+	return append(messages, llm.Message{Role: "user", Content: question})
+}
+
+// Sources returns the distinct document locations behind the hits, best score first
+func Sources(hits []vector.Result) []Source {
+	type key struct{ name, location string }
+	best := make(map[key]float32)
+	for _, h := range hits {
+		name, ok := h.Metadata["source"]
+		if !ok {
+			name = unknownSource
+		}
+		k := key{name: name, location: Location(h.Metadata)}
+		if score, seen := best[k]; !seen || h.Score > score {
+			best[k] = h.Score
+		}
+	}
+
+	sources := make([]Source, 0, len(best))
+	for k, score := range best {
+		sources = append(sources, Source{Name: k.name, Location: k.location, Score: score})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if sources[i].Score == sources[j].Score {
+			if sources[i].Name == sources[j].Name {
+				return sources[i].Location < sources[j].Location
+			}
+			return sources[i].Name < sources[j].Name
+		}
+		return sources[i].Score > sources[j].Score
+	})
+	return sources
+}
+
+// Location describes where a chunk sits in its document from the loader
+// metadata, e.g. "page 3" or "Setup > Install, lines 10-42"
+func Location(meta map[string]string) string {
+	var parts []string
+	if page := meta[loader.MetaPage]; page != "" {
+		parts = append(parts, "page "+page)
+	}
+	if heading := meta[loader.MetaHeading]; heading != "" {
+		parts = append(parts, heading)
+	}
+	if lines := meta[loader.MetaLines]; lines != "" {
+		parts = append(parts, "lines "+lines)
+	}
+	if rows := meta[loader.MetaRows]; rows != "" {
+		parts = append(parts, "rows "+rows)
+	}
+	return strings.Join(parts, ", ")
+}
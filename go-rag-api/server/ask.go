@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+type askRequest struct {
+	Question  string `json:"question"`
+	SessionID string `json:"session_id"`
+	Stream    bool   `json:"stream"`
+	filterRequest
+}
+
+// filterRequest narrows retrieval to some sources or an ingestion window
+type filterRequest struct {
+	Sources        []string  `json:"sources,omitempty"`
+	IngestedAfter  time.Time `json:"ingested_after,omitzero"`
+	IngestedBefore time.Time `json:"ingested_before,omitzero"`
+}
+
+func (f filterRequest) filter() vector.Filter {
+	return vector.Filter{
+		Sources:        f.Sources,
+		IngestedAfter:  f.IngestedAfter,
+		IngestedBefore: f.IngestedBefore,
+	}
+}
+
+type askResponse struct {
+	SessionID string       `json:"session_id"`
+	Answer    string       `json:"answer"`
+	Sources   []rag.Source `json:"sources"`
+}
+
+// handleAsk answers a question within a session. With "stream": true or an
+// Accept: text/event-stream header the tokens are sent as server-sent events:
+// "session", then one "token" per delta, "sources" and finally "done"
+func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	var req askRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		writeError(w, http.StatusBadRequest, "question is required")
+		return
+	}
+	stream := req.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	sess := s.sessions.get(req.SessionID)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	hits := s.retrieve(r, req.Question, req.filter())
+	messages := rag.Augment(sess.history, req.Question, hits)
+	sources := rag.Sources(hits)
+
+	if !stream {
+		reply, err := s.chat.ChatStream(r.Context(), messages, nil)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "chat failed: "+err.Error())
+			return
+		}
+		sess.history = append(sess.history, llm.Message{Role: "user", Content: req.Question}, reply)
+		writeJSON(w, http.StatusOK, askResponse{SessionID: sess.id, Answer: reply.Content, Sources: sources})
+		return
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sse.event("session", map[string]string{"session_id": sess.id})
+	reply, err := s.chat.ChatStream(r.Context(), messages, func(delta string) {
+		sse.event("token", map[string]string{"delta": delta})
+	})
+	if err != nil {
+		sse.event("error", map[string]string{"error": err.Error()})
+		return
+	}
+	sess.history = append(sess.history, llm.Message{Role: "user", Content: req.Question}, reply)
+	sse.event("sources", sources)
+	sse.event("done", askResponse{SessionID: sess.id, Answer: reply.Content, Sources: sources})
+}
+
+// retrieve looks up the excerpts for a question, a failing lookup only
+// costs the grounding so it is logged and the answer goes on without it
+func (s *Server) retrieve(r *http.Request, question string, filter vector.Filter) []vector.Result {
+	if s.retriever == nil {
+		return nil
+	}
+	retriever := *s.retriever
+	retriever.Filter = filter
+	hits, err := retriever.Retrieve(r.Context(), question)
+	if err != nil {
+		s.logger.Printf("retrieve: %v", err)
+		return nil
+	}
+	return hits
+}
+
+// sseWriter writes server-sent events and flushes after each one
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// event writes a named event, an empty name sends a plain data line
+func (s *sseWriter) event(name string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.raw(name, string(data))
+}
+
+func (s *sseWriter) raw(name, data string) {
+	if name != "" {
+		fmt.Fprintf(s.w, "event: %s\n", name)
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	s.flusher.Flush()
+}
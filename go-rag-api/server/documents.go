@@ -0,0 +1,175 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/chunk"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+type ingestRequest struct {
+	Source  string `json:"source"`
+	Content string `json:"content"`
+}
+
+type ingestResult struct {
+	Source string `json:"source"`
+	Chunks int    `json:"chunks"`
+	Error  string `json:"error,omitempty"`
+}
+
+type searchRequest struct {
+	Query    string  `json:"query"`
+	TopK     int     `json:"top_k"`
+	MinScore float32 `json:"min_score"`
+	filterRequest
+}
+
+type searchHit struct {
+	ID       string            `json:"id"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata"`
+	Score    float32           `json:"score"`
+}
+
+// handleUpload ingests documents sent either as multipart files (field "file",
+// repeatable) or as JSON {"source": "notes.md", "content": "..."}
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var results []ingestResult
+	switch mediaType {
+	case "multipart/form-data":
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid upload: "+err.Error())
+			return
+		}
+		files := r.MultipartForm.File["file"]
+		if len(files) == 0 {
+			writeError(w, http.StatusBadRequest, `no "file" parts in upload`)
+			return
+		}
+		for _, fh := range files {
+			f, err := fh.Open()
+			if err != nil {
+				results = append(results, ingestResult{Source: fh.Filename, Error: err.Error()})
+				continue
+			}
+			content, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				results = append(results, ingestResult{Source: fh.Filename, Error: err.Error()})
+				continue
+			}
+			results = append(results, s.ingest(r, fh.Filename, content))
+		}
+	default:
+		var req ingestRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		if req.Source == "" || req.Content == "" {
+			writeError(w, http.StatusBadRequest, "source and content are required")
+			return
+		}
+		results = append(results, s.ingest(r, req.Source, []byte(req.Content)))
+	}
+
+	status := http.StatusCreated
+	for _, res := range results {
+		if res.Error != "" {
+			status = http.StatusUnprocessableEntity
+			break
+		}
+	}
+	writeJSON(w, status, map[string]any{"documents": results})
+}
+
+func (s *Server) ingest(r *http.Request, source string, content []byte) ingestResult {
+	// uploads are keyed by relative path like the watched directory
+	source = chunk.CleanSource(source)
+	if !chunk.SupportedFormat(source) {
+		return ingestResult{Source: source, Error: "unsupported format " + filepath.Ext(source)}
+	}
+	n, err := chunk.Ingest(r.Context(), source, content, s.opts.Ingest, s.embedder, s.store)
+	if err != nil {
+		return ingestResult{Source: source, Error: err.Error()}
+	}
+	s.logger.Printf("ingested upload %s: %d chunks", source, n)
+	return ingestResult{Source: source, Chunks: n}
+}
+
+func (s *Server) handleListDocuments(w http.ResponseWriter, r *http.Request) {
+	sources, err := s.store.Sources(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if sources == nil {
+		sources = []vector.SourceInfo{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"documents": sources})
+}
+
+func (s *Server) handleDeleteDocument(w http.ResponseWriter, r *http.Request) {
+	source := strings.TrimSpace(r.PathValue("source"))
+	if source == "" {
+		writeError(w, http.StatusBadRequest, "source is required")
+		return
+	}
+	if err := s.store.DeleteBySource(r.Context(), source); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// forget the file too, or re-adding the same content would be skipped
+	if ledger := s.opts.Ingest.Ledger; ledger != nil {
+		if err := ledger.Remove(source); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSearch returns the raw similarity hits for a query, without the chat model
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	if s.retriever == nil {
+		writeError(w, http.StatusServiceUnavailable, "retrieval is disabled")
+		return
+	}
+
+	retriever := *s.retriever
+	if req.TopK > 0 {
+		retriever.TopK = req.TopK
+	}
+	if req.MinScore > 0 {
+		retriever.MinScore = req.MinScore
+	}
+	retriever.Filter = req.filter()
+	hits, err := retriever.Retrieve(r.Context(), req.Query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	out := make([]searchHit, len(hits))
+	for i, h := range hits {
+		out[i] = searchHit{ID: h.ID, Content: h.Content, Metadata: h.Metadata, Score: h.Score}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": out})
+}
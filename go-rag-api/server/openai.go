@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// OpenAI compatible request and response shapes, only the fields we use
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []llm.Message `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatCompletionDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatCompletionChoice struct {
+	Index        int                  `json:"index"`
+	Message      *llm.Message         `json:"message,omitempty"`
+	Delta        *chatCompletionDelta `json:"delta,omitempty"`
+	FinishReason *string              `json:"finish_reason"`
+}
+
+type chatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []chatCompletionChoice `json:"choices"`
+}
+
+// handleChatCompletions is a drop-in /v1/chat/completions for OpenAI clients.
+// The last user message is used for retrieval and the excerpts are added
+// transparently, the conversation itself is the one sent by the client
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatCompletionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	last := len(req.Messages) - 1
+	if last < 0 || req.Messages[last].Role != "user" || strings.TrimSpace(req.Messages[last].Content) == "" {
+		writeError(w, http.StatusBadRequest, "the last message must be a non-empty user message")
+		return
+	}
+
+	history := req.Messages[:last]
+	if !hasSystemMessage(history) {
+		history = append(append([]llm.Message(nil), s.opts.SystemPrompt...), history...)
+	}
+	question := req.Messages[last].Content
+	messages := rag.Augment(history, question, s.retrieve(r, question, vector.Filter{}))
+
+	// the chat always runs on the configured model, req.Model is ignored
+	// and the response says which model answered
+	resp := chatCompletionResponse{
+		ID:      "chatcmpl-" + newSessionID()[:24],
+		Created: time.Now().Unix(),
+		Model:   s.opts.Model,
+	}
+	stop := "stop"
+
+	if !req.Stream {
+		reply, err := s.chat.ChatStream(r.Context(), messages, nil)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "chat failed: "+err.Error())
+			return
+		}
+		resp.Object = "chat.completion"
+		resp.Choices = []chatCompletionChoice{{Message: &reply, FinishReason: &stop}}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Object = "chat.completion.chunk"
+	resp.Choices = []chatCompletionChoice{{Delta: &chatCompletionDelta{Role: "assistant"}}}
+	sse.event("", resp)
+
+	_, err = s.chat.ChatStream(r.Context(), messages, func(delta string) {
+		resp.Choices = []chatCompletionChoice{{Delta: &chatCompletionDelta{Content: delta}}}
+		sse.event("", resp)
+	})
+	if err != nil {
+		sse.event("", map[string]any{"error": map[string]string{"message": fmt.Sprintf("chat failed: %v", err)}})
+		return
+	}
+	resp.Choices = []chatCompletionChoice{{Delta: &chatCompletionDelta{}, FinishReason: &stop}}
+	sse.event("", resp)
+	sse.raw("", "[DONE]")
+}
+
+func hasSystemMessage(messages []llm.Message) bool {
+	for _, m := range messages {
+		if m.Role == "system" {
+			return true
+		}
+	}
+	return false
+}
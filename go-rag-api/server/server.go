@@ -0,0 +1,116 @@
+// Package server exposes the RAG pipeline over HTTP
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/chunk"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/rag"
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// maxUploadSize caps the size of a document upload
+const maxUploadSize = 32 << 20
+
+type Options struct {
+	Addr string
+	// Model is reported in the OpenAI compatible responses
+	Model string
+	// SystemPrompt starts every new session
+	SystemPrompt []llm.Message
+	// Ingest holds the chunking options used for uploads
+	Ingest chunk.Options
+}
+
+type Server struct {
+	opts      Options
+	chat      llm.Chatter
+	embedder  llm.Embedder
+	store     vector.Store
+	retriever *rag.Retriever
+	sessions  *sessionStore
+	logger    *log.Logger
+}
+
+func New(
+	opts Options,
+	chat llm.Chatter,
+	embedder llm.Embedder,
+	store vector.Store,
+	retriever *rag.Retriever,
+	logger *log.Logger,
+) *Server {
+	return &Server{
+		opts:      opts,
+		chat:      chat,
+		embedder:  embedder,
+		store:     store,
+		retriever: retriever,
+		sessions:  newSessionStore(opts.SystemPrompt),
+		logger:    logger,
+	}
+}
+
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /ask", s.handleAsk)
+	mux.HandleFunc("GET /sessions/{id}", s.handleGetSession)
+	mux.HandleFunc("DELETE /sessions/{id}", s.handleDeleteSession)
+	mux.HandleFunc("POST /documents", s.handleUpload)
+	mux.HandleFunc("GET /documents", s.handleListDocuments)
+	mux.HandleFunc("DELETE /documents/{source...}", s.handleDeleteDocument)
+	mux.HandleFunc("POST /search", s.handleSearch)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return mux
+}
+
+// ListenAndServe serves the API until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.opts.Addr,
+		Handler:           s.Routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Printf("http api listening on %s", s.opts.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUploadSize))
+	return dec.Decode(dst)
+}
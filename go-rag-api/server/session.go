@@ -0,0 +1,109 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/llm"
+)
+
+// sessionTTL is how long an idle session is kept
+const sessionTTL = 2 * time.Hour
+
+// session is one server-side conversation, mu serializes the turns
+type session struct {
+	mu      sync.Mutex
+	id      string
+	history []llm.Message
+	updated time.Time
+}
+
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	seed     []llm.Message
+}
+
+func newSessionStore(seed []llm.Message) *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]*session),
+		seed:     seed,
+	}
+}
+
+// get returns the session with the id, a new one is started when the id is
+// empty or unknown. Idle sessions are dropped on the way
+func (s *sessionStore) get(id string) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, sess := range s.sessions {
+		if now.Sub(sess.updated) > sessionTTL {
+			delete(s.sessions, key)
+		}
+	}
+
+	if sess, ok := s.sessions[id]; ok && id != "" {
+		sess.updated = now
+		return sess
+	}
+	if id == "" {
+		id = newSessionID()
+	}
+	sess := &session{
+		id:      id,
+		history: append([]llm.Message(nil), s.seed...),
+		updated: now,
+	}
+	s.sessions[id] = sess
+	return sess
+}
+
+func (s *sessionStore) lookup(id string) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	return sess, ok
+}
+
+func (s *sessionStore) delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[id]
+	delete(s.sessions, id)
+	return ok
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.sessions.lookup(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	sess.mu.Lock()
+	history := append([]llm.Message(nil), sess.history...)
+	sess.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"session_id": sess.id,
+		"messages":   history,
+	})
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.delete(r.PathValue("id")) {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
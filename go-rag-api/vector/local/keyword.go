@@ -0,0 +1,87 @@
+package local
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// KeywordQuery ranks the chunks containing the words of text with BM25,
+// so hybrid retrieval works without Postgres full-text search
+func (s *Store) KeywordQuery(
+	ctx context.Context,
+	text string,
+	topK int,
+	filter vector.Filter,
+) ([]vector.Result, error) {
+	query := termCounts(text)
+	if topK <= 0 || len(query) == 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// document frequencies and average length over the filtered chunks
+	var candidates []*record
+	df := make(map[string]int, len(query))
+	var totalLen int
+	for _, r := range s.docs {
+		if !filter.Match(r.Document.Metadata) {
+			continue
+		}
+		candidates = append(candidates, r)
+		totalLen += r.length
+		for term := range query {
+			if r.terms[term] > 0 {
+				df[term]++
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	avgLen := float64(totalLen) / float64(len(candidates))
+	n := float64(len(candidates))
+
+	var results []vector.Result
+	for _, r := range candidates {
+		var score float64
+		length := float64(r.length)
+		for term := range query {
+			tf := float64(r.terms[term])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[term])+0.5)/(float64(df[term])+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgLen))
+		}
+		if score > 0 {
+			results = append(results, vector.Result{Document: r.Document, Score: float32(score)})
+		}
+	}
+	return top(results, topK), nil
+}
+
+// termCounts splits text into lower-case words and counts them,
+// words of one character are dropped
+func termCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(w)) > 1 {
+			counts[w]++
+		}
+	}
+	return counts
+}
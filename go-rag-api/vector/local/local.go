@@ -0,0 +1,411 @@
+// Package local is an embedded vector store kept in memory and persisted to a
+// single file, so the pipeline runs without a database server. Search is an
+// exact scan over all chunks (a flat index), which is fast enough for the few
+// hundred thousand chunks a laptop or CI run deals with. Writes are appended
+// to a log next to the snapshot, which is rewritten only once the log has
+// grown past it and on Close
+package local
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// Metric is the distance used to rank chunks
+type Metric string
+
+const (
+	// Cosine scores by cosine similarity, -1..1
+	Cosine Metric = "cosine"
+	// Dot scores by inner product, for embeddings normalized by the model
+	Dot Metric = "dot"
+	// L2 scores by 1/(1+euclidean distance), 0..1
+	L2 Metric = "l2"
+)
+
+// fileVersion is bumped when the snapshot layout changes
+const fileVersion = 1
+
+type Options struct {
+	// Path is the snapshot file, created on the first compaction. The
+	// log of the writes since is kept in Path.wal
+	Path         string
+	EmbeddingDim int
+	// Metric defaults to Cosine
+	Metric Metric
+}
+
+// Store is a vector.Store held in memory, every write is logged to disk
+// before it returns
+type Store struct {
+	opts Options
+
+	mu   sync.RWMutex
+	docs map[string]*record
+	// wal is opened on the first write, walSize and snapSize decide when
+	// the log is compacted
+	wal      *os.File
+	walSize  int64
+	snapSize int64
+}
+
+// record is a stored chunk, exported fields so gob can encode it
+type record struct {
+	Document  vector.Document
+	UpdatedAt time.Time
+	// norm caches the length of the embedding for cosine
+	norm float64
+	// terms and length cache the word counts for keyword queries
+	terms  map[string]int
+	length int
+}
+
+// snapshot is the on-disk layout
+type snapshot struct {
+	Version      int
+	EmbeddingDim int
+	Records      []record
+}
+
+func New(opts Options) (*Store, error) {
+	if opts.Path == "" {
+		return nil, errors.New("local: Path is required")
+	}
+	if opts.EmbeddingDim <= 0 {
+		return nil, errors.New("local: EmbeddingDim must be > 0")
+	}
+	switch opts.Metric {
+	case "":
+		opts.Metric = Cosine
+	case Cosine, Dot, L2:
+	default:
+		return nil, fmt.Errorf("local: unknown metric %q", opts.Metric)
+	}
+
+	s := &Store{opts: opts, docs: make(map[string]*record)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the snapshot, a missing file is an empty store
+func (s *Store) load() error {
+	f, err := os.Open(s.opts.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open %s: %w", s.opts.Path, err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil {
+		s.snapSize = info.Size()
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return fmt.Errorf("decode %s: %w", s.opts.Path, err)
+	}
+	if snap.Version != fileVersion {
+		return fmt.Errorf("%s: unsupported version %d", s.opts.Path, snap.Version)
+	}
+	if snap.EmbeddingDim != s.opts.EmbeddingDim {
+		return fmt.Errorf(
+			"%s: stored embeddings have %d dimensions, configured %d",
+			s.opts.Path,
+			snap.EmbeddingDim,
+			s.opts.EmbeddingDim,
+		)
+	}
+	for i := range snap.Records {
+		r := snap.Records[i]
+		r.index()
+		s.docs[r.Document.ID] = &r
+	}
+	return nil
+}
+
+// save writes the snapshot to a temporary file and renames it over the old
+// one, a crash never leaves a half written store. Callers hold s.mu
+func (s *Store) save() error {
+	snap := snapshot{
+		Version:      fileVersion,
+		EmbeddingDim: s.opts.EmbeddingDim,
+		Records:      make([]record, 0, len(s.docs)),
+	}
+	for _, r := range s.docs {
+		snap.Records = append(snap.Records, *r)
+	}
+	sort.Slice(snap.Records, func(i, j int) bool {
+		return snap.Records[i].Document.ID < snap.Records[j].Document.ID
+	})
+
+	dir := filepath.Dir(s.opts.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.opts.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("stat snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.opts.Path); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+	s.snapSize = info.Size()
+	return nil
+}
+
+// index fills the caches that are not persisted
+func (r *record) index() {
+	var sum float64
+	for _, v := range r.Document.Embedding {
+		sum += float64(v) * float64(v)
+	}
+	r.norm = math.Sqrt(sum)
+	r.terms = termCounts(r.Document.Content)
+	r.length = 0
+	for _, c := range r.terms {
+		r.length += c
+	}
+}
+
+// Upsert inserts or replaces documents by ID
+func (s *Store) Upsert(ctx context.Context, docs []vector.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	for _, d := range docs {
+		if len(d.Embedding) != s.opts.EmbeddingDim {
+			return fmt.Errorf(
+				"document %s: embedding has %d dimensions, want %d",
+				d.ID,
+				len(d.Embedding),
+				s.opts.EmbeddingDim,
+			)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	e := walEntry{Upserts: make([]record, len(docs))}
+	for i, d := range docs {
+		e.Upserts[i] = record{Document: d, UpdatedAt: now}
+	}
+	return s.write(e)
+}
+
+// Query scans every chunk matching filter and returns the topK closest
+func (s *Store) Query(
+	ctx context.Context,
+	embedding []float32,
+	topK int,
+	filter vector.Filter,
+) ([]vector.Result, error) {
+	if topK <= 0 {
+		return nil, nil
+	}
+	if len(embedding) != s.opts.EmbeddingDim {
+		return nil, fmt.Errorf(
+			"query embedding has %d dimensions, want %d",
+			len(embedding),
+			s.opts.EmbeddingDim,
+		)
+	}
+
+	var qnorm float64
+	for _, v := range embedding {
+		qnorm += float64(v) * float64(v)
+	}
+	qnorm = math.Sqrt(qnorm)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]vector.Result, 0, len(s.docs))
+	for _, r := range s.docs {
+		if !filter.Match(r.Document.Metadata) {
+			continue
+		}
+		results = append(results, vector.Result{
+			Document: r.Document,
+			Score:    s.score(embedding, qnorm, r),
+		})
+	}
+	return top(results, topK), nil
+}
+
+func (s *Store) score(q []float32, qnorm float64, r *record) float32 {
+	e := r.Document.Embedding
+	switch s.opts.Metric {
+	case L2:
+		var sum float64
+		for i := range q {
+			d := float64(q[i]) - float64(e[i])
+			sum += d * d
+		}
+		return float32(1 / (1 + math.Sqrt(sum)))
+	case Dot:
+		return float32(dot(q, e))
+	default:
+		if qnorm == 0 || r.norm == 0 {
+			return 0
+		}
+		return float32(dot(q, e) / (qnorm * r.norm))
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// top sorts results by score, ties by ID so results are stable, and cuts to k
+func top(results []vector.Result, k int) []vector.Result {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score == results[j].Score {
+			return results[i].ID < results[j].ID
+		}
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Embeddings returns the stored vectors of the documents found among ids
+func (s *Store) Embeddings(ctx context.Context, ids []string) (map[string][]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	embeddings := make(map[string][]float32, len(ids))
+	for _, id := range ids {
+		if r, ok := s.docs[id]; ok {
+			embeddings[id] = r.Document.Embedding
+		}
+	}
+	return embeddings, nil
+}
+
+// Delete removes documents by ID
+func (s *Store) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var e walEntry
+	for _, id := range ids {
+		if _, ok := s.docs[id]; ok {
+			e.Deletes = append(e.Deletes, id)
+		}
+	}
+	if len(e.Deletes) == 0 {
+		return nil
+	}
+	return s.write(e)
+}
+
+// DeleteBySource removes all documents associated with a specific source
+func (s *Store) DeleteBySource(ctx context.Context, source string) error {
+	if source == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var e walEntry
+	for id, r := range s.docs {
+		if r.Document.Metadata["source"] == source {
+			e.Deletes = append(e.Deletes, id)
+		}
+	}
+	if len(e.Deletes) == 0 {
+		return nil
+	}
+	return s.write(e)
+}
+
+// Sources lists the distinct sources in the store, ordered by name
+func (s *Store) Sources(ctx context.Context) ([]vector.SourceInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bySource := make(map[string]*vector.SourceInfo)
+	for _, r := range s.docs {
+		name := r.Document.Metadata["source"]
+		info, ok := bySource[name]
+		if !ok {
+			info = &vector.SourceInfo{Source: name}
+			bySource[name] = info
+		}
+		info.Chunks++
+		if r.UpdatedAt.After(info.IngestedAt) {
+			info.IngestedAt = r.UpdatedAt
+		}
+	}
+
+	sources := make([]vector.SourceInfo, 0, len(bySource))
+	for _, info := range bySource {
+		sources = append(sources, *info)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Source < sources[j].Source })
+	return sources, nil
+}
+
+// Close folds the log into the snapshot, writes are already on disk
+// without it
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.walSize > 0 {
+		err = s.compact()
+	}
+	if s.wal != nil {
+		err = errors.Join(err, s.wal.Close())
+		s.wal = nil
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+const testDim = 3
+
+func open(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := New(Options{Path: path, EmbeddingDim: testDim})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func doc(id, source, ingestedAt, content string, embedding ...float32) vector.Document {
+	return vector.Document{
+		ID:        id,
+		Content:   content,
+		Metadata:  map[string]string{"source": source, "ingested_at": ingestedAt},
+		Embedding: embedding,
+	}
+}
+
+// ids returns the IDs of the results in their order
+func ids(results []vector.Result) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.ID
+	}
+	return out
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.gob")
+
+	s := open(t, path)
+	if err := s.Upsert(ctx, []vector.Document{
+		doc("a#0", "a.md", "2024-01-01T00:00:00Z", "cats purr", 1, 0, 0),
+		doc("a#1", "a.md", "2024-01-01T00:00:00Z", "cats sleep", 0.9, 0.1, 0),
+		doc("b#0", "b.md", "2024-06-01T00:00:00Z", "dogs bark", 0, 1, 0),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a second write replaces a#1, only the log holds both
+	if err := s.Upsert(ctx, []vector.Document{
+		doc("a#1", "a.md", "2024-01-01T00:00:00Z", "cats hunt", 0, 0, 1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("snapshot written before the log outgrew it: %v", err)
+	}
+
+	// reopening without Close, like after a crash, replays the log
+	s = open(t, path)
+	got, err := s.Query(ctx, []float32{1, 0, 0}, 3, vector.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(got), []string{"a#0", "a#1", "b#0"}) || got[1].Content != "cats hunt" {
+		t.Fatalf("after replay %v", got)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter vector.Filter
+		want   []string
+	}{
+		{"sources", vector.Filter{Sources: []string{"b.md"}}, []string{"b#0"}},
+		{"ingested after", vector.Filter{IngestedAfter: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, []string{"b#0"}},
+		{"ingested before", vector.Filter{IngestedBefore: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}, []string{"a#0", "a#1"}},
+	} {
+		got, err := s.Query(ctx, []float32{1, 0, 0}, 10, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids(got), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids(got), tt.want)
+		}
+	}
+	hits, err := s.KeywordQuery(ctx, "cats", 10, vector.Filter{Sources: []string{"a.md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Errorf("keyword query returned %v", ids(hits))
+	}
+
+	if err := s.DeleteBySource(ctx, "a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path + ".wal"); err == nil && info.Size() != 0 {
+		t.Errorf("log holds %d bytes after Close", info.Size())
+	}
+
+	// the snapshot alone has the deletion
+	s = open(t, path)
+	defer s.Close()
+	sources, err := s.Sources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].Source != "b.md" || sources[0].Chunks != 1 {
+		t.Fatalf("sources %+v", sources)
+	}
+	embeddings, err := s.Embeddings(ctx, []string{"a#0", "b#0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embeddings) != 1 || !slices.Equal(embeddings["b#0"], []float32{0, 1, 0}) {
+		t.Errorf("embeddings %v", embeddings)
+	}
+}
+
+func TestStoreTornLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.gob")
+
+	s := open(t, path)
+	if err := s.Upsert(ctx, []vector.Document{doc("a#0", "a.md", "", "cats", 1, 0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	// a crash in the middle of the next entry leaves part of it behind
+	f, err := os.OpenFile(path+".wal", os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{0, 0, 1, 0, 'x'}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s = open(t, path)
+	if err := s.Upsert(ctx, []vector.Document{doc("b#0", "b.md", "", "dogs", 0, 1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the write after the torn entry replaced it
+	s = open(t, path)
+	defer s.Close()
+	got, err := s.Query(ctx, []float32{1, 0, 0}, 10, vector.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(got), []string{"a#0", "b#0"}) {
+		t.Errorf("after the torn entry %v", ids(got))
+	}
+}
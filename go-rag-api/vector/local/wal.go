@@ -0,0 +1,134 @@
+package local
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// minCompactSize keeps small stores from rewriting the snapshot on every
+// write, the log is only folded in once it reaches this size
+const minCompactSize = 1 << 20
+
+// walEntry is one write, appended to the log as a 4 byte big endian length
+// followed by a self-contained gob encoding. Replaying an entry twice gives
+// the same store, so a crash between a compaction and the log truncation
+// is harmless
+type walEntry struct {
+	Upserts []record
+	Deletes []string
+}
+
+func (s *Store) walPath() string {
+	return s.opts.Path + ".wal"
+}
+
+// replay applies the log written since the last snapshot. A torn entry at
+// the end, left by a crash during a write, is dropped
+func (s *Store) replay() error {
+	f, err := os.Open(s.walPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open %s: %w", s.walPath(), err)
+	}
+	defer f.Close()
+
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(f, size[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("read %s: %w", s.walPath(), err)
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(f, buf); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("read %s: %w", s.walPath(), err)
+		}
+		var e walEntry
+		if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&e); err != nil {
+			return fmt.Errorf("decode %s: %w", s.walPath(), err)
+		}
+		s.apply(e)
+		s.walSize += int64(len(size) + len(buf))
+	}
+}
+
+func (s *Store) apply(e walEntry) {
+	for i := range e.Upserts {
+		r := e.Upserts[i]
+		r.index()
+		s.docs[r.Document.ID] = &r
+	}
+	for _, id := range e.Deletes {
+		delete(s.docs, id)
+	}
+}
+
+// write logs e, applies it and compacts once the log outgrows the
+// snapshot, so a write costs its own size and the snapshot rewrites are
+// amortized over the writes since the last one. Callers hold s.mu
+func (s *Store) write(e walEntry) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return fmt.Errorf("encode log entry: %w", err)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+
+	if s.wal == nil {
+		if err := os.MkdirAll(filepath.Dir(s.opts.Path), 0o755); err != nil {
+			return fmt.Errorf("create %s: %w", filepath.Dir(s.opts.Path), err)
+		}
+		f, err := os.OpenFile(s.walPath(), os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", s.walPath(), err)
+		}
+		// cut a torn entry replay stopped at
+		if err := f.Truncate(s.walSize); err != nil {
+			f.Close()
+			return fmt.Errorf("truncate %s: %w", s.walPath(), err)
+		}
+		s.wal = f
+	}
+	if _, err := s.wal.WriteAt(b, s.walSize); err != nil {
+		return fmt.Errorf("write %s: %w", s.walPath(), err)
+	}
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", s.walPath(), err)
+	}
+	s.walSize += int64(len(b))
+	s.apply(e)
+
+	if s.walSize >= max(s.snapSize, minCompactSize) {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes the snapshot and empties the log. Callers hold s.mu
+func (s *Store) compact() error {
+	if err := s.save(); err != nil {
+		return err
+	}
+	if s.wal != nil {
+		if err := s.wal.Truncate(0); err != nil {
+			return fmt.Errorf("truncate %s: %w", s.walPath(), err)
+		}
+	} else if err := os.Remove(s.walPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove %s: %w", s.walPath(), err)
+	}
+	s.walSize = 0
+	return nil
+}
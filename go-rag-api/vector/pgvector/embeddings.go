@@ -0,0 +1,36 @@
+package pgvector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// Embeddings returns the stored vectors of the documents found among ids
+func (s *Store) Embeddings(ctx context.Context, ids []string) (map[string][]float32, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	const embeddingsSQL = `SELECT id, embedding FROM documents WHERE id = ANY($1)`
+	rows, err := s.pool.Query(ctx, embeddingsSQL, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	embeddings := make(map[string][]float32, len(ids))
+	for rows.Next() {
+		var id string
+		var v pgvector.Vector
+		if err := rows.Scan(&id, &v); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		embeddings[id] = v.Slice()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration failed: %w", err)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,77 @@
+package pgvector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// textSearchConfig is the Postgres text search configuration of content_tsv,
+// queries must use the same one to hit the index
+const textSearchConfig = "english"
+
+// KeywordQuery runs a full-text search over the chunk contents.
+// text is parsed with websearch_to_tsquery, so quoted phrases, "or" and
+// -exclusions work, and results are ranked with ts_rank_cd
+func (s *Store) KeywordQuery(ctx context.Context, text string, topK int, filter vector.Filter) ([]vector.Result, error) {
+	if topK <= 0 || strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	where, args := filterSQL(filter, []any{text, topK})
+	match := "content_tsv @@ websearch_to_tsquery('" + textSearchConfig + "', $1)"
+	if where == "" {
+		where = "WHERE " + match
+	} else {
+		where += " AND " + match
+	}
+	querySQL := `
+		SELECT
+			id,
+			content,
+			metadata,
+			ts_rank_cd(content_tsv, websearch_to_tsquery('` + textSearchConfig + `', $1)) AS rank
+		FROM documents
+		` + where + `
+		ORDER BY rank DESC
+		LIMIT $2
+	`
+
+	rows, err := s.pool.Query(ctx, querySQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("keyword query failed: %w", err)
+	}
+	defer rows.Close()
+
+	return scanResults(rows, func(rank float64) float32 {
+		return float32(rank)
+	})
+}
+
+// filterSQL renders the WHERE clause of filter, appending its parameters
+// after the ones already in args
+func filterSQL(filter vector.Filter, args []any) (string, []any) {
+	var conds []string
+	param := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if len(filter.Sources) > 0 {
+		conds = append(conds, "metadata->>'source' = ANY("+param(filter.Sources)+")")
+	}
+	if !filter.IngestedAfter.IsZero() {
+		conds = append(conds, "(metadata->>'ingested_at')::timestamptz >= "+param(filter.IngestedAfter))
+	}
+	if !filter.IngestedBefore.IsZero() {
+		conds = append(conds, "(metadata->>'ingested_at')::timestamptz < "+param(filter.IngestedBefore))
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
@@ -129,6 +129,9 @@ func (s *Store) migrate(ctx context.Context, embeddingDim int) error {
 		);
 		CREATE INDEX IF NOT EXISTS idx_documents_embedding ON documents USING hnsw (embedding vector_cosine_ops);
 CREATE INDEX IF NOT EXISTS idx_documents_metadata ON documents USING GIN (metadata);
+		ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_tsv TSVECTOR
+			GENERATED ALWAYS AS (to_tsvector('english', content)) STORED;
+		CREATE INDEX IF NOT EXISTS idx_documents_content_tsv ON documents USING GIN (content_tsv);
 
 	`
 	_, err := s.pool.Exec(ctx, fmt.Sprintf(migrateSQL, embeddingDim))
@@ -6,64 +6,70 @@ import (
 	"fmt"
 
 	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+	"github.com/jackc/pgx/v5"
 	"github.com/pgvector/pgvector-go"
 )
 
-func (s *Store) Query(ctx context.Context, embedding []float32, topK int) ([]vector.Result, error) {
+func (s *Store) Query(ctx context.Context, embedding []float32, topK int, filter vector.Filter) ([]vector.Result, error) {
 	if topK <= 0 {
 		return nil, nil
 	}
 
-	const querySQL = `
+	where, args := filterSQL(filter, []any{pgvector.NewVector(embedding), topK})
+	querySQL := `
 		SELECT 
 			id,
 			content,
 			metadata,
-			embedding <=> $1) AS distance
+			(embedding <=> $1) AS distance
 		FROM documents 
+		` + where + `
 		ORDER BY embedding <=> $1
 		LIMIT $2
 	`
 
-
-	rows, err := s.pool.Query(ctx, querySQL, pgvector.NewVector(embedding), topK)
+	rows, err := s.pool.Query(ctx, querySQL, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
 
+	return scanResults(rows, func(distance float64) float32 {
+		return float32(1 - distance)
+	})
+}
+
+// scanResults reads id, content, metadata and a score column,
+// score converts the last column into Result.Score
+func scanResults(rows pgx.Rows, score func(float64) float32) ([]vector.Result, error) {
 	var results []vector.Result
-	for rows.Next(){
-    var r vector.Result
+	for rows.Next() {
+		var r vector.Result
 		var metaRaw []byte
-		var distance float64
-
-		if err := rows.Scan(&r.ID,&r.Content,&metaRaw,&distance);err != nil {
-
+		var value float64
 
+		if err := rows.Scan(&r.ID, &r.Content, &metaRaw, &value); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		if err := unMarshalMetadata(metaRaw, &r.Metadata);err != nil {
-
-			return nil , fmt.Errorf("metadata for %s: %w", r.ID,err)
+		if err := unMarshalMetadata(metaRaw, &r.Metadata); err != nil {
+			return nil, fmt.Errorf("metadata for %s: %w", r.ID, err)
 		}
 
-		r.Score = float32(1 - distance)
-    results = append(results, r)
+		r.Score = score(value)
+		results = append(results, r)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("row iteration failed: %w", err)
 	}
-	return  results,nil
+	return results, nil
 }
 
-
-func unMarshalMetadata(raw []byte,dst *map[string]string) error {
-   if len(raw) == 0 {
-     *dst = nil 
-		 return nil
-	 }
- return json.Unmarshal(raw, dst)
+func unMarshalMetadata(raw []byte, dst *map[string]string) error {
+	if len(raw) == 0 {
+		*dst = nil
+		return nil
+	}
+	return json.Unmarshal(raw, dst)
 }
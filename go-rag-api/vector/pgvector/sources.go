@@ -0,0 +1,44 @@
+package pgvector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dkr290/go-advanced-projects/go-rag-api/vector"
+)
+
+// Sources lists the distinct sources in the store, ordered by name
+func (s *Store) Sources(ctx context.Context) ([]vector.SourceInfo, error) {
+	const sourcesSQL = `
+		SELECT
+			metadata->>'source' AS source,
+			COUNT(*) AS chunks,
+			MAX(created_at) AS ingested_at
+		FROM documents
+		GROUP BY 1
+		ORDER BY 1
+	`
+
+	rows, err := s.pool.Query(ctx, sourcesSQL)
+	if err != nil {
+		return nil, fmt.Errorf("query sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []vector.SourceInfo
+	for rows.Next() {
+		var info vector.SourceInfo
+		var source *string
+		if err := rows.Scan(&source, &info.Chunks, &info.IngestedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if source != nil {
+			info.Source = *source
+		}
+		sources = append(sources, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration failed: %w", err)
+	}
+	return sources, nil
+}
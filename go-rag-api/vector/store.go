@@ -1,7 +1,11 @@
 // Package vector for vector embedding operations
 package vector
 
-import "context"
+import (
+	"context"
+	"slices"
+	"time"
+)
 
 // Document represents a document with its vector embedding
 type Document struct {
@@ -23,6 +27,47 @@ type Result struct {
 	Score float32
 }
 
+// SourceInfo summarizes one ingested source
+type SourceInfo struct {
+	// Source is the source key stored in the "source" metadata
+	Source string `json:"source"`
+	// Chunks is the number of documents stored for the source
+	Chunks int `json:"chunks"`
+	// IngestedAt is when the source was last written
+	IngestedAt time.Time `json:"ingested_at"`
+}
+
+// Filter narrows a query by chunk metadata, the zero value matches everything
+type Filter struct {
+	// Sources keeps chunks whose "source" metadata is one of these
+	Sources []string
+	// IngestedAfter and IngestedBefore bound the "ingested_at" metadata
+	IngestedAfter  time.Time
+	IngestedBefore time.Time
+}
+
+// Match reports whether a document with this metadata passes the filter,
+// for stores that filter in Go
+func (f Filter) Match(meta map[string]string) bool {
+	if len(f.Sources) > 0 && !slices.Contains(f.Sources, meta["source"]) {
+		return false
+	}
+	if f.IngestedAfter.IsZero() && f.IngestedBefore.IsZero() {
+		return true
+	}
+	at, err := time.Parse(time.RFC3339, meta["ingested_at"])
+	if err != nil {
+		return false
+	}
+	if !f.IngestedAfter.IsZero() && at.Before(f.IngestedAfter) {
+		return false
+	}
+	if !f.IngestedBefore.IsZero() && !at.Before(f.IngestedBefore) {
+		return false
+	}
+	return true
+}
+
 // Store interface defines the contract for vector storage operations
 // This interface allows easy switching between different vector database implementations
 // such as PostgreSQL with pgvector, Weaviate, or other vector databases
@@ -32,10 +77,10 @@ type Store interface {
 	Upsert(ctx context.Context, docs []Document) error
 
 	// Query performs a similarity search using vector embeddings
-	// Returns up to topK most similar documents sorted by similarity score
+	// Returns up to topK most similar documents matching filter sorted by similarity score
 	// The embedding parameter should contain the query vector
 	// Returns an error if the operation fails
-	Query(ctx context.Context, embedding []float32, topK int) ([]Result, error)
+	Query(ctx context.Context, embedding []float32, topK int, filter Filter) ([]Result, error)
 
 	// Delete removes documents with the specified IDs from the vector store
 	Delete(ctx context.Context, ids []string) error
@@ -43,6 +88,24 @@ type Store interface {
 	// DeleteBySource removes all documents associated with a specific source
 	DeleteBySource(ctx context.Context, source string) error
 
+	// Sources lists the distinct sources in the store, ordered by name
+	Sources(ctx context.Context) ([]SourceInfo, error)
+
 	// Close releases any resources held by the store
 	Close() error
 }
+
+// KeywordSearcher is implemented by stores with a full-text index,
+// the retriever fuses its results with the similarity search
+type KeywordSearcher interface {
+	// KeywordQuery returns up to topK documents matching the words of text
+	// sorted by text rank, Score is the rank of the store and not a similarity
+	KeywordQuery(ctx context.Context, text string, topK int, filter Filter) ([]Result, error)
+}
+
+// EmbeddingGetter is implemented by stores that can return stored vectors,
+// incremental ingestion reuses them for chunks whose text did not change
+type EmbeddingGetter interface {
+	// Embeddings returns the vectors of the documents found among ids
+	Embeddings(ctx context.Context, ids []string) (map[string][]float32, error)
+}
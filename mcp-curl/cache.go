@@ -0,0 +1,188 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the response cache.
+const (
+	DefaultCacheEntries = 100
+	DefaultCacheBytes   = 32 << 20
+)
+
+// CacheEntry is a stored GET response.
+type CacheEntry struct {
+	// URL is the requested URL the entry is stored under.
+	URL string
+	// FinalURL is where the response came from, after redirects.
+	FinalURL     string
+	ContentType  string
+	ETag         string
+	LastModified string
+	Header       http.Header
+	Body         []byte
+	Fetched      time.Time
+	// Vary holds the request headers named by the response's Vary header
+	// with the values they had, the entry only answers requests sending
+	// the same values.
+	Vary map[string]string
+}
+
+// matches reports whether the entry may answer a request with header h.
+func (e CacheEntry) matches(h http.Header) bool {
+	for name, value := range e.Vary {
+		if h.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Cache keeps the most recently fetched pages. An entry with an ETag or a
+// Last-Modified date is revalidated by a conditional request, an unchanged
+// page then costs a 304 instead of the whole body.
+type Cache struct {
+	MaxEntries int
+	MaxBytes   int
+	// OnStore and OnEvict are told about pages entering and leaving the
+	// cache, to publish them as resources. They run outside the lock, one
+	// Put at a time and in the order the cache changed.
+	OnStore func(CacheEntry)
+	OnEvict func(url string)
+
+	// publish is taken before mu is released, so the callbacks of two Puts
+	// cannot interleave and leave the resources behind the cache
+	publish sync.Mutex
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+// NewCache returns a cache holding at most maxEntries responses of
+// maxBytes in total.
+func NewCache(maxEntries, maxBytes int) *Cache {
+	return &Cache{MaxEntries: maxEntries, MaxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// Get returns the entry stored for url.
+func (c *Cache) Get(url string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[url]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(CacheEntry), true
+}
+
+// Entries returns the stored entries, the most recently used first.
+func (c *Cache) Entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]CacheEntry, 0, c.order.Len())
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		out = append(out, el.Value.(CacheEntry))
+	}
+	return out
+}
+
+// Put stores e, evicting the least recently used entries beyond the limits.
+// A body larger than MaxBytes is not stored.
+func (c *Cache) Put(e CacheEntry) {
+	if c.MaxBytes > 0 && len(e.Body) > c.MaxBytes {
+		return
+	}
+	c.mu.Lock()
+	if el, ok := c.entries[e.URL]; ok {
+		c.size -= len(el.Value.(CacheEntry).Body)
+		c.order.Remove(el)
+	}
+	c.entries[e.URL] = c.order.PushFront(e)
+	c.size += len(e.Body)
+
+	var evicted []string
+	for c.order.Len() > 1 && ((c.MaxEntries > 0 && c.order.Len() > c.MaxEntries) || (c.MaxBytes > 0 && c.size > c.MaxBytes)) {
+		old := c.order.Remove(c.order.Back()).(CacheEntry)
+		delete(c.entries, old.URL)
+		c.size -= len(old.Body)
+		evicted = append(evicted, old.URL)
+	}
+	c.publish.Lock()
+	c.mu.Unlock()
+	defer c.publish.Unlock()
+
+	if c.OnEvict != nil {
+		for _, url := range evicted {
+			c.OnEvict(url)
+		}
+	}
+	if c.OnStore != nil {
+		c.OnStore(e)
+	}
+}
+
+// ownHeaders reports whether the caller set request headers other than
+// Accept. Such a request bypasses the cache: any header may carry a key or
+// change the answer, and the cache is shared by every client of the server
+// and published as resources.
+func ownHeaders(headers map[string]string) bool {
+	for name := range headers {
+		if http.CanonicalHeaderKey(name) != "Accept" {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheable reports whether a response may be stored, following its
+// Cache-Control. A response setting cookies or varying on everything is
+// not stored either.
+func cacheable(h http.Header) bool {
+	for _, directive := range strings.Split(strings.ToLower(h.Get("Cache-Control")), ",") {
+		switch strings.TrimSpace(directive) {
+		case "no-store", "private":
+			return false
+		}
+	}
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, name := range varyHeaders(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// varyHeaders lists the header names of the Vary header of a response.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyValues records the values req sent for the headers resp varies on.
+func varyValues(req *http.Request, resp http.Header) map[string]string {
+	names := varyHeaders(resp)
+	if len(names) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		values[name] = req.Header.Get(name)
+	}
+	return values
+}
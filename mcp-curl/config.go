@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the server configuration, read from a JSON file and then
+// overridden by MCP_CURL_* environment variables:
+//
+//	{
+//	  "allowed_schemes": ["https"],
+//	  "allow_hosts": ["*.example.com", "10.20.0.0/16"],
+//	  "deny_hosts": ["internal.example.com"],
+//	  "allow_private": false,
+//	  "timeout": "20s",
+//	  "max_bytes": 524288,
+//	  "max_redirects": 3,
+//	  "user_agent": "mcp-curl/1.0",
+//	  "cache_entries": 100
+//	}
+type Config struct {
+	Schemes      []string `json:"allowed_schemes,omitempty"`
+	AllowHosts   []string `json:"allow_hosts,omitempty"`
+	DenyHosts    []string `json:"deny_hosts,omitempty"`
+	AllowPrivate bool     `json:"allow_private,omitempty"`
+	Timeout      Duration `json:"timeout,omitempty"`
+	MaxBytes     int64    `json:"max_bytes,omitempty"`
+	MaxRedirects *int     `json:"max_redirects,omitempty"`
+	UserAgent    string   `json:"user_agent,omitempty"`
+	// CacheEntries is how many responses are kept, a negative count disables the cache.
+	CacheEntries int `json:"cache_entries,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "30s" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig reads the file at path, when path is set, and applies the
+// environment overrides:
+//
+//	MCP_CURL_ALLOWED_SCHEMES, MCP_CURL_ALLOW_HOSTS, MCP_CURL_DENY_HOSTS  comma separated lists
+//	MCP_CURL_ALLOW_PRIVATE  true or false
+//	MCP_CURL_TIMEOUT        duration like 30s
+//	MCP_CURL_MAX_BYTES, MCP_CURL_MAX_REDIRECTS, MCP_CURL_USER_AGENT, MCP_CURL_CACHE_ENTRIES
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv() error {
+	list := func(key string, dst *[]string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+	list("MCP_CURL_ALLOWED_SCHEMES", &c.Schemes)
+	list("MCP_CURL_ALLOW_HOSTS", &c.AllowHosts)
+	list("MCP_CURL_DENY_HOSTS", &c.DenyHosts)
+
+	if v := os.Getenv("MCP_CURL_ALLOW_PRIVATE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("MCP_CURL_ALLOW_PRIVATE: %w", err)
+		}
+		c.AllowPrivate = b
+	}
+	if v := os.Getenv("MCP_CURL_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("MCP_CURL_TIMEOUT: %w", err)
+		}
+		c.Timeout = Duration(d)
+	}
+	if v := os.Getenv("MCP_CURL_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("MCP_CURL_MAX_BYTES: %w", err)
+		}
+		c.MaxBytes = n
+	}
+	if v := os.Getenv("MCP_CURL_MAX_REDIRECTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MCP_CURL_MAX_REDIRECTS: %w", err)
+		}
+		c.MaxRedirects = &n
+	}
+	if v := os.Getenv("MCP_CURL_USER_AGENT"); v != "" {
+		c.UserAgent = v
+	}
+	if v := os.Getenv("MCP_CURL_CACHE_ENTRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MCP_CURL_CACHE_ENTRIES: %w", err)
+		}
+		c.CacheEntries = n
+	}
+	return nil
+}
+
+// Policy returns the URL policy of the config, http and https when it
+// names no schemes.
+func (c Config) Policy() Policy {
+	p := DefaultPolicy()
+	if len(c.Schemes) > 0 {
+		p.Schemes = nil
+		for _, s := range c.Schemes {
+			p.Schemes = append(p.Schemes, strings.ToLower(s))
+		}
+	}
+	p.AllowHosts = c.AllowHosts
+	p.DenyHosts = c.DenyHosts
+	p.AllowPrivate = c.AllowPrivate
+	return p
+}
+
+// Fetcher returns a fetcher with the config's policy and limits.
+func (c Config) Fetcher() *Fetcher {
+	f := NewFetcher(c.Policy())
+	if c.Timeout > 0 {
+		f.Timeout = time.Duration(c.Timeout)
+	}
+	if c.MaxBytes > 0 {
+		f.MaxBytes = c.MaxBytes
+	}
+	if c.MaxRedirects != nil && *c.MaxRedirects >= 0 {
+		f.MaxRedirects = *c.MaxRedirects
+	}
+	if c.UserAgent != "" {
+		f.UserAgent = c.UserAgent
+	}
+	switch {
+	case c.CacheEntries == 0:
+		f.Cache = NewCache(DefaultCacheEntries, DefaultCacheBytes)
+	case c.CacheEntries > 0:
+		f.Cache = NewCache(c.CacheEntries, DefaultCacheBytes)
+	}
+	return f
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Codes of the structured tool errors.
+const (
+	// CodeDenied is a URL the policy does not allow.
+	CodeDenied = "url_denied"
+	// CodeInvalidRequest is an argument the tool cannot use.
+	CodeInvalidRequest = "invalid_request"
+	// CodeTimeout is a request that got no answer in time.
+	CodeTimeout = "timeout"
+	// CodeFetchFailed is any other failure to get a response.
+	CodeFetchFailed = "fetch_failed"
+)
+
+// ToolError is a failed call as reported to the client: the message as
+// text content and the whole error in the "error" field of the structured
+// output, so clients can tell a denied URL from a network failure.
+type ToolError struct {
+	Code    string `json:"code" jsonschema:"url_denied, invalid_request, timeout or fetch_failed"`
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+}
+
+func (e *ToolError) Error() string {
+	if e.Code == CodeDenied {
+		return "denied: " + e.Message
+	}
+	return e.Message
+}
+
+func invalid(target, message string) *ToolError {
+	return &ToolError{Code: CodeInvalidRequest, Message: message, URL: target}
+}
+
+// asToolError classifies err for the client. A ToolError wrapped by the
+// HTTP client, as the policy's errors from redirects and dials are, keeps
+// its code.
+func asToolError(err error, target string) *ToolError {
+	var te *ToolError
+	if errors.As(err, &te) {
+		out := *te
+		if out.URL == "" {
+			out.URL = target
+		}
+		return &out
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &ToolError{Code: CodeTimeout, Message: err.Error(), URL: target}
+	}
+	return &ToolError{Code: CodeFetchFailed, Message: err.Error(), URL: target}
+}
+
+// errorResult is the result of a failed call, the structured output carries te.
+func errorResult(te *ToolError) *mcp.CallToolResult {
+	return &mcp.CallToolResult{IsError: true, Content: []mcp.Content{&mcp.TextContent{Text: te.Error()}}}
+}
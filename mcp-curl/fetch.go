@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Output modes of a fetch.
+const (
+	ModeMarkdown = "markdown"
+	ModeText     = "text"
+	ModeRaw      = "raw"
+)
+
+// Defaults and upper bounds of the per-request limits.
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxBytes     = 1 << 20
+	DefaultMaxRedirects = 5
+	defaultUserAgent    = "mcp-curl/1.0"
+)
+
+// Fetcher makes the HTTP requests of the tools. The per-request options may
+// lower its limits but never raise them.
+type Fetcher struct {
+	// Policy is checked before the request, Transport has to enforce it on
+	// redirects and resolved addresses, as Policy.Transport does.
+	Policy       Policy
+	Transport    http.RoundTripper
+	Timeout      time.Duration
+	MaxBytes     int64
+	MaxRedirects int
+	UserAgent    string
+	// Cache stores GET responses, nil disables caching.
+	Cache *Cache
+}
+
+// NewFetcher returns a fetcher enforcing policy with the default limits.
+func NewFetcher(policy Policy) *Fetcher {
+	return &Fetcher{
+		Policy:       policy,
+		Transport:    policy.Transport(),
+		Timeout:      DefaultTimeout,
+		MaxBytes:     DefaultMaxBytes,
+		MaxRedirects: DefaultMaxRedirects,
+		UserAgent:    defaultUserAgent,
+	}
+}
+
+type FetchInput struct {
+	URL     string            `json:"url" jsonschema:"http or https URL to fetch"`
+	Method  string            `json:"method,omitempty" jsonschema:"HTTP method, default GET"`
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"request headers"`
+	Body    string            `json:"body,omitempty" jsonschema:"request body, e.g. JSON for a POST"`
+	Mode    string            `json:"mode,omitempty" jsonschema:"markdown (default), text or raw; HTML is converted to readable markdown or text, raw returns the body as is"`
+	// TimeoutSeconds, MaxBytes and MaxRedirects only lower the server limits.
+	TimeoutSeconds int   `json:"timeout_seconds,omitempty" jsonschema:"give up after this many seconds"`
+	MaxBytes       int64 `json:"max_bytes,omitempty" jsonschema:"read at most this many bytes of the body"`
+	MaxRedirects   *int  `json:"max_redirects,omitempty" jsonschema:"follow at most this many redirects, 0 follows none"`
+	// NoCache keeps the request away from the cache, for calls whose
+	// answer may be personal.
+	NoCache bool `json:"-"`
+}
+
+type FetchOutput struct {
+	// URL is where the response came from, after redirects.
+	URL         string            `json:"url"`
+	Status      int               `json:"status"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Content     string            `json:"content"`
+	Links       []Link            `json:"links,omitempty"`
+	// Truncated is set when the body was longer than max_bytes.
+	Truncated bool `json:"truncated,omitempty"`
+	// Cached is set when the page had not changed since it was last fetched.
+	Cached bool `json:"cached,omitempty"`
+	// Error is set instead of the rest when the call failed.
+	Error *ToolError `json:"error,omitempty"`
+}
+
+// Response is a received response with its body read up to the size limit.
+type Response struct {
+	// URL is where the response came from, after redirects.
+	URL       *url.URL
+	Status    int
+	Header    http.Header
+	Body      []byte
+	Truncated bool
+	// Cached is set when the body came from the cache after the server
+	// answered 304 Not Modified.
+	Cached bool
+}
+
+// Do performs the request of in, its Mode is ignored. Complete GET
+// responses are stored in the cache and revalidated on the next request.
+// Its errors are ToolErrors.
+func (f *Fetcher) Do(ctx context.Context, in FetchInput) (*Response, error) {
+	target, err := url.Parse(strings.TrimSpace(in.URL))
+	if err != nil {
+		return nil, invalid(in.URL, fmt.Sprintf("invalid url: %v", err))
+	}
+	if err := f.Policy.CheckURL(target); err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(in.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	timeout := f.Timeout
+	if d := time.Duration(in.TimeoutSeconds) * time.Second; d > 0 && (timeout <= 0 || d < timeout) {
+		timeout = d
+	}
+	maxBytes := f.MaxBytes
+	if in.MaxBytes > 0 && (maxBytes <= 0 || in.MaxBytes < maxBytes) {
+		maxBytes = in.MaxBytes
+	}
+	redirects := f.MaxRedirects
+	if in.MaxRedirects != nil && *in.MaxRedirects >= 0 && *in.MaxRedirects < redirects {
+		redirects = *in.MaxRedirects
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var body io.Reader
+	if in.Body != "" {
+		body = strings.NewReader(in.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, invalid(in.URL, fmt.Sprintf("build request: %v", err))
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json,text/plain;q=0.9,*/*;q=0.8")
+	for k, v := range in.Headers {
+		req.Header.Set(k, v)
+	}
+
+	// only plain GETs go through the cache, not ones with headers of their
+	// own, which may be conditions or credentials
+	useCache := f.Cache != nil && !in.NoCache && method == http.MethodGet && in.Body == "" && !ownHeaders(in.Headers)
+	var cached CacheEntry
+	if useCache {
+		if entry, ok := f.Cache.Get(target.String()); ok && entry.matches(req.Header) {
+			cached = entry
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	client := &http.Client{
+		Transport: f.Transport,
+		CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+			if len(via) > redirects {
+				return fmt.Errorf("stopped after %d redirects", redirects)
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		te := asToolError(err, target.String())
+		if te.Code == CodeTimeout {
+			te.Message = fmt.Sprintf("%s %s: no response within %s", method, target, timeout)
+		}
+		return nil, te
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached.URL != "" {
+		final, _ := url.Parse(cached.FinalURL)
+		cached.Fetched = time.Now()
+		f.Cache.Put(cached)
+		return &Response{URL: final, Status: http.StatusOK, Header: cached.Header, Body: cached.Body, Cached: true}, nil
+	}
+
+	data, truncated, err := readLimited(resp.Body, maxBytes)
+	if err != nil {
+		return nil, asToolError(fmt.Errorf("read body: %w", err), target.String())
+	}
+	if useCache && resp.StatusCode == http.StatusOK && !truncated && cacheable(resp.Header) {
+		f.Cache.Put(CacheEntry{
+			URL:          target.String(),
+			FinalURL:     resp.Request.URL.String(),
+			ContentType:  resp.Header.Get("Content-Type"),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Header:       resp.Header,
+			Body:         data,
+			Fetched:      time.Now(),
+			Vary:         varyValues(req, resp.Header),
+		})
+	}
+	return &Response{URL: resp.Request.URL, Status: resp.StatusCode, Header: resp.Header, Body: data, Truncated: truncated}, nil
+}
+
+// Fetch performs the request of in and renders the response body. Its
+// errors are ToolErrors.
+func (f *Fetcher) Fetch(ctx context.Context, in FetchInput) (FetchOutput, error) {
+	mode := strings.ToLower(in.Mode)
+	switch mode {
+	case "":
+		mode = ModeMarkdown
+	case ModeMarkdown, ModeText, ModeRaw:
+	default:
+		return FetchOutput{}, invalid(in.URL, fmt.Sprintf("unknown mode %q, use markdown, text or raw", in.Mode))
+	}
+	resp, err := f.Do(ctx, in)
+	if err != nil {
+		return FetchOutput{}, err
+	}
+
+	out := FetchOutput{
+		URL:         resp.URL.String(),
+		Status:      resp.Status,
+		ContentType: resp.Header.Get("Content-Type"),
+		Truncated:   resp.Truncated,
+		Cached:      resp.Cached,
+	}
+	if mode == ModeRaw {
+		out.Headers = flattenHeaders(resp.Header)
+	}
+	out.Content, out.Title, out.Links, err = render(resp.Body, out.ContentType, resp.URL, mode)
+	if err != nil {
+		return FetchOutput{}, &ToolError{Code: CodeFetchFailed, Message: err.Error(), URL: out.URL}
+	}
+	return out, nil
+}
+
+// readLimited reads at most limit bytes of r, truncated reports whether there was more.
+func readLimited(r io.Reader, limit int64) ([]byte, bool, error) {
+	if limit <= 0 {
+		data, err := io.ReadAll(r)
+		return data, false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > limit {
+		return data[:limit], true, nil
+	}
+	return data, false, nil
+}
+
+// render turns a body into the content returned to the client: HTML is
+// converted unless mode is raw, other text is passed on and binary bodies
+// are only described.
+func render(data []byte, contentType string, base *url.URL, mode string) (string, string, []Link, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	switch {
+	case !isText(mediaType):
+		return fmt.Sprintf("[%s body, %d bytes, not shown]", mediaType, len(data)), "", nil, nil
+	case mode != ModeRaw && (mediaType == "text/html" || mediaType == "application/xhtml+xml"):
+		page, err := ConvertHTML(bytes.NewReader(data), base, mode == ModeMarkdown)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("parse html: %w", err)
+		}
+		return page.Text, page.Title, page.Links, nil
+	}
+	return strings.ToValidUTF8(string(data), "�"), "", nil, nil
+}
+
+func isText(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/xhtml+xml",
+		"application/x-www-form-urlencoded", "application/yaml", "application/x-yaml", "image/svg+xml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+func flattenHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
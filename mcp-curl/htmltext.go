@@ -0,0 +1,410 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Link is a hyperlink found on a page, resolved against the page URL.
+type Link struct {
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url"`
+}
+
+// Page is the readable form of an HTML document.
+type Page struct {
+	Title string
+	Text  string
+	Links []Link
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// skipped elements never hold readable content
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Object: true, atom.Canvas: true,
+	atom.Head: true, atom.Select: true, atom.Button: true,
+}
+
+// blocks start on a line of their own
+var blocks = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Header: true, atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Form: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Address: true, atom.Details: true,
+	atom.Summary: true, atom.Fieldset: true,
+}
+
+// ConvertHTML reads an HTML document and renders its content as markdown,
+// or as plain text when markdown is false. Relative links are resolved
+// against base.
+func ConvertHTML(r io.Reader, base *url.URL, markdown bool) (Page, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Page{}, err
+	}
+	c := &converter{markdown: markdown, base: base, seen: map[string]bool{}}
+	c.title = findTitle(doc)
+	if body := find(doc, atom.Body); body != nil {
+		doc = body
+	}
+	w := &textWriter{}
+	c.children(w, doc)
+	return Page{Title: c.title, Text: w.String(), Links: c.links}, nil
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if found := find(ch, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func findTitle(doc *html.Node) string {
+	if t := find(doc, atom.Title); t != nil {
+		return strings.TrimSpace(spaceRun.ReplaceAllString(textOf(t), " "))
+	}
+	if h := find(doc, atom.H1); h != nil {
+		return strings.TrimSpace(spaceRun.ReplaceAllString(textOf(h), " "))
+	}
+	return ""
+}
+
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(textOf(ch))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+type converter struct {
+	markdown bool
+	base     *url.URL
+	title    string
+	links    []Link
+	seen     map[string]bool
+	lists    int // depth of the list being rendered
+}
+
+// textWriter collects rendered text, collapsing whitespace in inline text
+// and keeping at most one blank line between blocks.
+type textWriter struct {
+	sb    strings.Builder
+	space bool // a space is due before the next word
+}
+
+func (w *textWriter) String() string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(w.sb.String(), "\n\n"))
+}
+
+func (w *textWriter) atLineStart() bool {
+	s := w.sb.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+// inline writes text the way a browser shows it, runs of whitespace become one space.
+func (w *textWriter) inline(s string) {
+	if s == "" {
+		return
+	}
+	lead := strings.IndexAny(s[:1], " \t\r\n\f") == 0
+	trail := strings.IndexAny(s[len(s)-1:], " \t\r\n\f") == 0
+	words := strings.TrimSpace(spaceRun.ReplaceAllString(s, " "))
+	if words == "" {
+		w.space = w.space || lead
+		return
+	}
+	if (w.space || lead) && !w.atLineStart() {
+		w.sb.WriteByte(' ')
+	}
+	w.sb.WriteString(words)
+	w.space = trail
+}
+
+// raw writes s unchanged, for markup and preformatted text.
+func (w *textWriter) raw(s string) {
+	if w.space && !w.atLineStart() {
+		w.sb.WriteByte(' ')
+	}
+	w.space = false
+	w.sb.WriteString(s)
+}
+
+// lines ends the current line and adds blank lines up to n newlines.
+func (w *textWriter) lines(n int) {
+	w.space = false
+	s := w.sb.String()
+	if s == "" {
+		return
+	}
+	trimmed := strings.TrimRight(s, " ")
+	have := len(trimmed) - len(strings.TrimRight(trimmed, "\n"))
+	if have >= n {
+		return
+	}
+	if len(trimmed) != len(s) {
+		w.sb.Reset()
+		w.sb.WriteString(trimmed)
+	}
+	w.sb.WriteString(strings.Repeat("\n", n-have))
+}
+
+func (c *converter) children(w *textWriter, n *html.Node) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.node(w, ch)
+	}
+}
+
+// sub renders the children of n on their own, for content that is indented or prefixed.
+func (c *converter) sub(n *html.Node) string {
+	w := &textWriter{}
+	c.children(w, n)
+	return w.String()
+}
+
+func (c *converter) node(w *textWriter, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.inline(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if skipped[n.DataAtom] || attr(n, "hidden") != "" || attr(n, "aria-hidden") == "true" {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.lines(2)
+		if c.markdown {
+			w.raw(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		}
+		c.children(w, n)
+		w.lines(2)
+	case atom.Br:
+		w.lines(1)
+	case atom.Hr:
+		w.lines(2)
+		if c.markdown {
+			w.raw("---")
+		}
+		w.lines(2)
+	case atom.A:
+		c.link(w, n)
+	case atom.Img:
+		alt := strings.TrimSpace(attr(n, "alt"))
+		if alt == "" {
+			return
+		}
+		if c.markdown {
+			w.raw("![" + alt + "](" + c.resolve(attr(n, "src")) + ")")
+		} else {
+			w.inline(" " + alt + " ")
+		}
+	case atom.Strong, atom.B:
+		c.wrap(w, n, "**")
+	case atom.Em, atom.I:
+		c.wrap(w, n, "_")
+	case atom.Code, atom.Kbd, atom.Samp:
+		if c.markdown {
+			w.raw("`" + strings.TrimSpace(textOf(n)) + "`")
+		} else {
+			w.inline(textOf(n))
+		}
+	case atom.Pre:
+		w.lines(2)
+		text := strings.Trim(textOf(n), "\n")
+		if c.markdown {
+			text = "```\n" + text + "\n```"
+		}
+		w.raw(text)
+		w.lines(2)
+	case atom.Blockquote:
+		w.lines(2)
+		text := c.sub(n)
+		if c.markdown {
+			lines := strings.Split(text, "\n")
+			for i, l := range lines {
+				lines[i] = strings.TrimRight("> "+l, " ")
+			}
+			text = strings.Join(lines, "\n")
+		}
+		w.raw(text)
+		w.lines(2)
+	case atom.Ul, atom.Ol:
+		// a nested list stays tight inside its item
+		gap := 2
+		if c.lists > 0 {
+			gap = 1
+		}
+		w.lines(gap)
+		c.lists++
+		c.list(w, n)
+		c.lists--
+		w.lines(gap)
+	case atom.Li:
+		// an item outside a list
+		w.lines(1)
+		w.raw("- " + c.sub(n))
+		w.lines(1)
+	case atom.P:
+		w.lines(2)
+		c.children(w, n)
+		w.lines(2)
+	case atom.Table:
+		w.lines(2)
+		c.table(w, n)
+		w.lines(2)
+	default:
+		if blocks[n.DataAtom] {
+			w.lines(1)
+			c.children(w, n)
+			w.lines(1)
+			return
+		}
+		c.children(w, n)
+	}
+}
+
+func (c *converter) wrap(w *textWriter, n *html.Node, mark string) {
+	if !c.markdown {
+		c.children(w, n)
+		return
+	}
+	text := strings.TrimSpace(spaceRun.ReplaceAllString(textOf(n), " "))
+	if text != "" {
+		w.raw(mark + text + mark)
+	}
+}
+
+// table renders one line per row, in markdown as a table with the first
+// row as its header.
+func (c *converter) table(w *textWriter, n *html.Node) {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			switch {
+			case ch.Type != html.ElementNode, skipped[ch.DataAtom]:
+			case ch.DataAtom == atom.Tr:
+				var row []string
+				for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						text := strings.Join(strings.Fields(c.sub(cell)), " ")
+						row = append(row, strings.ReplaceAll(text, "|", "\\|"))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			case ch.DataAtom != atom.Table:
+				collect(ch)
+			}
+		}
+	}
+	collect(n)
+
+	for i, row := range rows {
+		w.lines(1)
+		if !c.markdown {
+			w.raw(strings.Join(row, "\t"))
+			continue
+		}
+		w.raw("| " + strings.Join(row, " | ") + " |")
+		if i == 0 {
+			w.lines(1)
+			w.raw(strings.TrimSuffix(strings.Repeat("| --- ", len(row)), " ") + " |")
+		}
+	}
+}
+
+func (c *converter) list(w *textWriter, n *html.Node) {
+	i := 0
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type != html.ElementNode || ch.DataAtom != atom.Li {
+			continue
+		}
+		i++
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(i) + ". "
+		}
+		item := c.sub(ch)
+		indent := strings.Repeat(" ", len(marker))
+		w.lines(1)
+		w.raw(marker + strings.ReplaceAll(item, "\n", "\n"+indent))
+		w.lines(1)
+	}
+}
+
+func (c *converter) link(w *textWriter, n *html.Node) {
+	href := strings.TrimSpace(attr(n, "href"))
+	text := strings.TrimSpace(spaceRun.ReplaceAllString(textOf(n), " "))
+	target := c.resolve(href)
+	if target == "" {
+		c.children(w, n)
+		return
+	}
+	if !c.seen[target] {
+		c.seen[target] = true
+		c.links = append(c.links, Link{Text: text, URL: target})
+	}
+	switch {
+	case text == "":
+		// image links and the like
+		c.children(w, n)
+	case c.markdown:
+		w.raw("[" + text + "](" + target + ")")
+	default:
+		w.inline(text)
+	}
+}
+
+// resolve returns href as an absolute http(s) URL, "" for anchors on the
+// same page and links that are not web pages.
+func (c *converter) resolve(href string) string {
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
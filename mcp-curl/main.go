@@ -2,50 +2,132 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log"
 	"log/slog"
-	"os/exec"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type Input struct {
-	URL string `json:"url" description:"url of the webpage to fetch"`
+	URL string `json:"url" jsonschema:"url of the webpage to fetch"`
 }
 
 type Output struct {
-	Content string `json:"content" description:"fetched webpage content"`
+	Title   string     `json:"title,omitempty" jsonschema:"title of the page"`
+	Content string     `json:"content" jsonschema:"fetched webpage content as markdown"`
+	Error   *ToolError `json:"error,omitempty" jsonschema:"why the fetch failed"`
 }
 
 func main() {
+	configPath := flag.String("config", os.Getenv("MCP_CURL_CONFIG"), "JSON file with the URL policy and fetch limits")
+	httpAddr := flag.String("http", os.Getenv("MCP_CURL_HTTP_ADDR"), "serve MCP over HTTP on this address, e.g. :8080, instead of stdio")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	s := mcp.NewServer(
 		&mcp.Implementation{Name: "mcp-curl", Version: "1.0.0"},
 		&mcp.ServerOptions{Logger: slog.Default()},
 	)
 
-	toolOptions := mcp.Tool{
-		Description: "fetch a webpage using curl",
-		Name:        "Curl tool",
+	f := cfg.Fetcher()
+	if f.Cache != nil {
+		publishCache(s, f.Cache)
+	}
+	addTools(s, f)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *httpAddr == "" {
+		if err := s.Run(ctx, &mcp.StdioTransport{}); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	mcp.AddTool(s, &toolOptions, curlContent)
-	if err := s.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+	if err := serveHTTP(ctx, s, *httpAddr); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func curlContent(ctx context.Context, _ *mcp.CallToolRequest, input Input) (
-	*mcp.CallToolResult,
-	Output,
-	error,
-) {
-	url := input.URL
+func addTools(s *mcp.Server, f *Fetcher) {
+	mcp.AddTool(s, &mcp.Tool{
+		Name: "fetch",
+		Description: "fetch a URL over HTTP with a chosen method, headers and body. HTML pages are returned " +
+			"as readable markdown or text with their title and links, other text as is.",
+	}, handler(f.Fetch, func(in FetchInput) string { return in.URL }, func(te *ToolError) FetchOutput { return FetchOutput{Error: te} }))
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "head",
+		Description: "send a HEAD request and return the status and response headers without the body",
+	}, handler(f.Head, func(in HeadInput) string { return in.URL }, func(te *ToolError) HeadOutput { return HeadOutput{Error: te} }))
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "robots",
+		Description: "read a site's robots.txt: its sitemaps, the crawl rules and, for a path, whether it may be fetched",
+	}, handler(f.Robots, func(in RobotsInput) string { return in.URL }, func(te *ToolError) RobotsOutput { return RobotsOutput{Error: te} }))
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "sitemap",
+		Description: "list the pages of a sitemap, or the child sitemaps of a sitemap index",
+	}, handler(f.Sitemap, func(in SitemapInput) string { return in.URL }, func(te *ToolError) SitemapOutput { return SitemapOutput{Error: te} }))
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "json_api",
+		Description: "call a JSON API with query parameters and a JSON body and return the parsed response",
+	}, handler(f.JSON, func(in JSONInput) string { return in.URL }, func(te *ToolError) JSONOutput { return JSONOutput{Error: te} }))
+	// the original tool, kept for clients configured with it
+	mcp.AddTool(s, &mcp.Tool{
+		Description: "fetch a webpage and return it as markdown",
+		Name:        "Curl tool",
+	}, handler(f.curl, func(in Input) string { return in.URL }, func(te *ToolError) Output { return Output{Error: te} }))
+}
+
+// handler adapts a fetcher method to a tool. A failure is a tool error: its
+// message is the text content and the ToolError, put in place by failed,
+// the structured output.
+func handler[In, Out any](run func(context.Context, In) (Out, error), target func(In) string, failed func(*ToolError) Out) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, _ *mcp.CallToolRequest, in In) (*mcp.CallToolResult, Out, error) {
+		out, err := run(ctx, in)
+		if err != nil {
+			te := asToolError(err, target(in))
+			return errorResult(te), failed(te), nil
+		}
+		return nil, out, nil
+	}
+}
 
-	cmd := exec.Command("curl", "-s", url)
-	output, err := cmd.Output()
+func (f *Fetcher) curl(ctx context.Context, input Input) (Output, error) {
+	out, err := f.Fetch(ctx, FetchInput{URL: input.URL})
 	if err != nil {
-		return &mcp.CallToolResult{}, Output{}, err
+		return Output{}, err
 	}
+	return Output{Title: out.Title, Content: out.Content}, nil
+}
 
-	content := string(output)
-	return nil, Output{Content: content}, nil
+// serveHTTP serves s to many clients: the streamable HTTP transport on
+// /mcp and the older HTTP+SSE one on /sse.
+func serveHTTP(ctx context.Context, s *mcp.Server, addr string) error {
+	getServer := func(*http.Request) *mcp.Server { return s }
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(getServer, nil))
+	mux.Handle("/sse", mcp.NewSSEHandler(getServer, nil))
+
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("serving MCP over HTTP", "addr", addr, "streamable", "/mcp", "sse", "/sse")
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
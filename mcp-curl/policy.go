@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// blockedRanges are the addresses a public fetch has no business reaching:
+// this host, private networks, link-local ones with the cloud metadata
+// endpoints, carrier-grade NAT and multicast.
+var blockedRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// Policy decides which URLs may be fetched. Host patterns are exact names
+// like "example.com", wildcards like "*.example.com" for the subdomains,
+// or CIDR ranges like "10.1.0.0/16" matched against the resolved addresses.
+type Policy struct {
+	// Schemes are the allowed URL schemes.
+	Schemes []string
+	// AllowHosts, when not empty, is the only hosts that may be fetched.
+	// Its CIDR ranges also open up private addresses.
+	AllowHosts []string
+	// DenyHosts are never fetched, even when allowed.
+	DenyHosts []string
+	// AllowPrivate lets requests reach loopback, private and link-local addresses.
+	AllowPrivate bool
+}
+
+// DefaultPolicy allows http and https to public addresses.
+func DefaultPolicy() Policy {
+	return Policy{Schemes: []string{"http", "https"}}
+}
+
+// CheckURL applies the scheme and host rules to u, the addresses it
+// resolves to are checked when the connection is made.
+func (p Policy) CheckURL(u *url.URL) error {
+	if !slices.Contains(p.Schemes, strings.ToLower(u.Scheme)) {
+		return denied(u.String(), "scheme %q is not allowed, allowed: %s", u.Scheme, strings.Join(p.Schemes, ", "))
+	}
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return &ToolError{Code: CodeInvalidRequest, Message: "url has no host", URL: u.String()}
+	}
+	if pattern, ok := matchHost(p.DenyHosts, host); ok {
+		return denied(u.String(), "host %s is denied by %q", host, pattern)
+	}
+	if len(p.AllowHosts) > 0 {
+		if _, ok := matchHost(p.AllowHosts, host); !ok {
+			// an IP literal may still fall into an allowed range
+			if addr, err := netip.ParseAddr(host); err != nil || !p.allowedRange(addr) {
+				return denied(u.String(), "host %s is not in the allow list", host)
+			}
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.CheckAddr(u.String(), host, addr)
+	}
+	return nil
+}
+
+// CheckAddr checks an address host resolved to.
+func (p Policy) CheckAddr(target, host string, addr netip.Addr) error {
+	addr = addr.Unmap()
+	what := "address " + addr.String()
+	if a, err := netip.ParseAddr(host); err != nil || a.Unmap() != addr {
+		what += " of " + host
+	}
+	for _, pattern := range p.DenyHosts {
+		if prefix, err := netip.ParsePrefix(pattern); err == nil && prefix.Contains(addr) {
+			return denied(target, "%s is denied by %q", what, pattern)
+		}
+	}
+	if p.AllowPrivate || p.allowedRange(addr) {
+		return nil
+	}
+	for _, prefix := range blockedRanges {
+		if prefix.Contains(addr) {
+			return denied(target, "%s is private or reserved", what)
+		}
+	}
+	return nil
+}
+
+func (p Policy) allowedRange(addr netip.Addr) bool {
+	for _, pattern := range p.AllowHosts {
+		if prefix, err := netip.ParsePrefix(pattern); err == nil && prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// matchHost returns the first name pattern matching host.
+func matchHost(patterns []string, host string) (string, bool) {
+	for _, pattern := range patterns {
+		p := normalizeHost(strings.TrimSpace(pattern))
+		if strings.Contains(p, "/") {
+			continue // a CIDR range, checked against addresses
+		}
+		if p == host {
+			return pattern, true
+		}
+		if strings.ContainsAny(p, "*?[") {
+			if ok, _ := path.Match(p, host); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Transport returns a transport that enforces p on every connection: host
+// names are resolved here and every address is checked before dialing, so
+// a redirect or a DNS answer changing between check and use cannot reach
+// a blocked address. Requests sent through a proxy from the environment
+// are checked by resolving their host up front, the proxy itself may be
+// a private address. Only the proxy's host and port are trusted, and only
+// for proxied requests.
+func (p Policy) Transport() http.RoundTripper {
+	proxies := httpproxy.FromEnvironment()
+	proxyFunc := proxies.ProxyFunc()
+	trusted := map[string]bool{}
+	for _, raw := range []string{proxies.HTTPProxy, proxies.HTTPSProxy} {
+		if u, err := url.Parse(raw); err == nil && u.Host != "" {
+			trusted[hostPort(u)] = true
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) }
+	base.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if trusted[net.JoinHostPort(normalizeHost(host), port)] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		addrs, err := p.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, a := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(a.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+	return &guardedTransport{policy: p, base: base, proxy: proxyFunc, trusted: trusted}
+}
+
+// hostPort is the address of u, with the default port of its scheme.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(normalizeHost(u.Hostname()), port)
+}
+
+// resolve looks host up and fails when any of its addresses is blocked.
+func (p Policy) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if a, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{a}
+	} else {
+		ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		addrs = ips
+	}
+	for _, a := range addrs {
+		if err := p.CheckAddr("", host, a); err != nil {
+			return nil, err
+		}
+	}
+	return addrs, nil
+}
+
+type guardedTransport struct {
+	policy Policy
+	base   http.RoundTripper
+	proxy  func(*url.URL) (*url.URL, error)
+	// trusted are the proxy addresses, dialed without checks
+	trusted map[string]bool
+}
+
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+	// a proxied request is checked here, its dial goes to the proxy. So is
+	// a direct one to the proxy's address, whose dial skips the checks
+	proxy, _ := t.proxy(req.URL)
+	if proxy != nil || t.trusted[hostPort(req.URL)] {
+		if _, err := t.policy.resolve(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+func denied(target, format string, args ...any) *ToolError {
+	return &ToolError{Code: CodeDenied, Message: fmt.Sprintf(format, args...), URL: target}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// publishCache lists every cached page as a resource of s under its URL,
+// clients can read it again without another request. HTML is served as
+// markdown.
+func publishCache(s *mcp.Server, c *Cache) {
+	c.OnStore = func(e CacheEntry) {
+		mediaType := resourceType(e)
+		r := &mcp.Resource{
+			URI:         e.URL,
+			Name:        e.URL,
+			MIMEType:    mediaType,
+			Description: fmt.Sprintf("page fetched %s", e.Fetched.UTC().Format(time.RFC3339)),
+			Size:        int64(len(e.Body)),
+		}
+		if mediaType == "text/markdown" {
+			base, _ := url.Parse(e.FinalURL)
+			if _, title, _, err := render(e.Body, e.ContentType, base, ModeMarkdown); err == nil {
+				r.Title = title
+			}
+		}
+		s.AddResource(r, readCached(c))
+	}
+	c.OnEvict = func(url string) { s.RemoveResources(url) }
+}
+
+func resourceType(e CacheEntry) string {
+	mediaType, _, _ := mime.ParseMediaType(e.ContentType)
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return "text/markdown"
+	}
+	return mediaType
+}
+
+func readCached(c *Cache) mcp.ResourceHandler {
+	return func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		e, ok := c.Get(req.Params.URI)
+		if !ok {
+			return nil, mcp.ResourceNotFoundError(req.Params.URI)
+		}
+		contents := &mcp.ResourceContents{URI: e.URL, MIMEType: resourceType(e)}
+		mediaType, _, _ := mime.ParseMediaType(e.ContentType)
+		if isText(mediaType) {
+			base, _ := url.Parse(e.FinalURL)
+			text, _, _, err := render(e.Body, e.ContentType, base, ModeMarkdown)
+			if err != nil {
+				return nil, err
+			}
+			contents.Text = text
+		} else {
+			contents.Blob = e.Body
+		}
+		return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSitemapLimit = 200
+	// maxSitemapBytes bounds a gzipped sitemap once unpacked
+	maxSitemapBytes = 50 << 20
+)
+
+type HeadInput struct {
+	URL     string            `json:"url" jsonschema:"http or https URL to inspect"`
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"request headers"`
+	// MaxRedirects only lowers the server limit.
+	MaxRedirects *int `json:"max_redirects,omitempty" jsonschema:"follow at most this many redirects, 0 shows the redirect itself"`
+}
+
+type HeadOutput struct {
+	// URL is where the response came from, after redirects.
+	URL           string            `json:"url"`
+	Status        int               `json:"status"`
+	ContentType   string            `json:"content_type,omitempty"`
+	ContentLength int64             `json:"content_length,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Error         *ToolError        `json:"error,omitempty"`
+}
+
+// Head sends a HEAD request and returns the status and headers.
+func (f *Fetcher) Head(ctx context.Context, in HeadInput) (HeadOutput, error) {
+	resp, err := f.Do(ctx, FetchInput{URL: in.URL, Method: http.MethodHead, Headers: in.Headers, MaxRedirects: in.MaxRedirects})
+	if err != nil {
+		return HeadOutput{}, err
+	}
+	length, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	return HeadOutput{
+		URL:           resp.URL.String(),
+		Status:        resp.Status,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: length,
+		Headers:       flattenHeaders(resp.Header),
+	}, nil
+}
+
+type RobotsInput struct {
+	URL string `json:"url" jsonschema:"any URL of the site, robots.txt is read from its root"`
+	// Path is checked against the rules for UserAgent.
+	Path      string `json:"path,omitempty" jsonschema:"path to check, e.g. /docs/page.html"`
+	UserAgent string `json:"user_agent,omitempty" jsonschema:"crawler name the path is checked for, default *"`
+}
+
+// RobotsGroup is one block of rules for a set of crawlers.
+type RobotsGroup struct {
+	UserAgents []string `json:"user_agents"`
+	Allow      []string `json:"allow,omitempty"`
+	Disallow   []string `json:"disallow,omitempty"`
+	CrawlDelay string   `json:"crawl_delay,omitempty"`
+}
+
+type RobotsOutput struct {
+	URL string `json:"url"`
+	// Found is false when the site has no robots.txt, everything is allowed then.
+	Found    bool          `json:"found"`
+	Sitemaps []string      `json:"sitemaps,omitempty"`
+	Groups   []RobotsGroup `json:"groups,omitempty"`
+	// Allowed answers whether Path may be crawled, when a path was given.
+	Allowed *bool      `json:"allowed,omitempty"`
+	Error   *ToolError `json:"error,omitempty"`
+}
+
+// Robots reads and parses the robots.txt of the site of in.URL.
+func (f *Fetcher) Robots(ctx context.Context, in RobotsInput) (RobotsOutput, error) {
+	root, err := siteRoot(in.URL)
+	if err != nil {
+		return RobotsOutput{}, err
+	}
+	out := RobotsOutput{URL: root.JoinPath("robots.txt").String()}
+	resp, err := f.Do(ctx, FetchInput{URL: out.URL})
+	if err != nil {
+		return RobotsOutput{}, err
+	}
+	if resp.Status == http.StatusOK {
+		out.Found = true
+		out.Sitemaps, out.Groups = parseRobots(resp.Body)
+	}
+	if in.Path != "" {
+		allowed := robotsAllowed(out.Groups, in.UserAgent, in.Path)
+		out.Allowed = &allowed
+	}
+	return out, nil
+}
+
+func siteRoot(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return nil, invalid(raw, "url must be absolute, e.g. https://example.com/")
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, nil
+}
+
+func parseRobots(data []byte) ([]string, []RobotsGroup) {
+	var sitemaps []string
+	var groups []RobotsGroup
+	var cur *RobotsGroup
+	inAgents := false // consecutive user-agent lines share a group
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents || cur == nil {
+				groups = append(groups, RobotsGroup{})
+				cur = &groups[len(groups)-1]
+			}
+			cur.UserAgents = append(cur.UserAgents, value)
+			inAgents = true
+			continue
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		case "allow":
+			if cur != nil && value != "" {
+				cur.Allow = append(cur.Allow, value)
+			}
+		case "disallow":
+			if cur != nil && value != "" {
+				cur.Disallow = append(cur.Disallow, value)
+			}
+		case "crawl-delay":
+			if cur != nil {
+				cur.CrawlDelay = value
+			}
+		}
+		inAgents = false
+	}
+	return sitemaps, groups
+}
+
+// robotsAllowed applies the group for agent, or the * group, to path: the
+// longest matching rule wins and allow wins a tie.
+func robotsAllowed(groups []RobotsGroup, agent, path string) bool {
+	if agent == "" {
+		agent = "*"
+	}
+	var group *RobotsGroup
+	for i, g := range groups {
+		for _, ua := range g.UserAgents {
+			if strings.EqualFold(ua, agent) {
+				group = &groups[i]
+			} else if ua == "*" && group == nil {
+				group = &groups[i]
+			}
+		}
+	}
+	if group == nil {
+		return true
+	}
+	best, allowed := -1, true
+	for _, rule := range group.Disallow {
+		if robotsMatch(rule, path) && len(rule) > best {
+			best, allowed = len(rule), false
+		}
+	}
+	for _, rule := range group.Allow {
+		if robotsMatch(rule, path) && len(rule) >= best {
+			best, allowed = len(rule), true
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a robots.txt path pattern, where * is any run of
+// characters and a trailing $ anchors the end.
+func robotsMatch(pattern, path string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(path)
+}
+
+type SitemapInput struct {
+	URL   string `json:"url" jsonschema:"sitemap URL, or the site root to use the sitemaps listed in robots.txt or /sitemap.xml"`
+	Limit int    `json:"limit,omitempty" jsonschema:"return at most this many URLs, default 200"`
+}
+
+// SitemapURL is a page listed in a sitemap.
+type SitemapURL struct {
+	Loc        string `json:"loc" xml:"loc"`
+	LastMod    string `json:"lastmod,omitempty" xml:"lastmod"`
+	ChangeFreq string `json:"changefreq,omitempty" xml:"changefreq"`
+	Priority   string `json:"priority,omitempty" xml:"priority"`
+}
+
+type SitemapOutput struct {
+	URL string `json:"url"`
+	// URLs are the pages of a urlset sitemap.
+	URLs []SitemapURL `json:"urls,omitempty"`
+	// Sitemaps are the child sitemaps of a sitemap index, fetch them in turn.
+	Sitemaps []string `json:"sitemaps,omitempty"`
+	// Total is the number of entries before the limit.
+	Total     int        `json:"total"`
+	Truncated bool       `json:"truncated,omitempty"`
+	Error     *ToolError `json:"error,omitempty"`
+}
+
+// Sitemap reads a sitemap or sitemap index, plain or gzipped.
+func (f *Fetcher) Sitemap(ctx context.Context, in SitemapInput) (SitemapOutput, error) {
+	target := strings.TrimSpace(in.URL)
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return SitemapOutput{}, invalid(in.URL, "url must be absolute, e.g. https://example.com/sitemap.xml")
+	}
+	if u.Path == "" || u.Path == "/" {
+		// a site root: prefer the sitemap robots.txt names
+		target = u.JoinPath("sitemap.xml").String()
+		if robots, err := f.Robots(ctx, RobotsInput{URL: in.URL}); err == nil && len(robots.Sitemaps) > 0 {
+			target = robots.Sitemaps[0]
+		}
+	}
+
+	resp, err := f.Do(ctx, FetchInput{URL: target})
+	if err != nil {
+		return SitemapOutput{}, err
+	}
+	out := SitemapOutput{URL: resp.URL.String()}
+	if resp.Status != http.StatusOK {
+		return out, &ToolError{Code: CodeFetchFailed, Message: fmt.Sprintf("sitemap answered %d", resp.Status), URL: out.URL}
+	}
+	data := resp.Body
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return out, &ToolError{Code: CodeFetchFailed, Message: "unpack sitemap: " + err.Error(), URL: out.URL}
+		}
+		if data, err = io.ReadAll(io.LimitReader(zr, maxSitemapBytes)); err != nil && len(data) == 0 {
+			return out, &ToolError{Code: CodeFetchFailed, Message: "unpack sitemap: " + err.Error(), URL: out.URL}
+		}
+	}
+
+	var doc struct {
+		XMLName  xml.Name
+		URLs     []SitemapURL `xml:"url"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil && len(doc.URLs) == 0 && len(doc.Sitemaps) == 0 {
+		return out, &ToolError{Code: CodeFetchFailed, Message: "parse sitemap: " + err.Error(), URL: out.URL}
+	}
+
+	limit := in.Limit
+	if limit <= 0 {
+		limit = defaultSitemapLimit
+	}
+	out.Total = len(doc.URLs) + len(doc.Sitemaps)
+	for _, s := range doc.Sitemaps {
+		if len(out.Sitemaps) == limit {
+			break
+		}
+		out.Sitemaps = append(out.Sitemaps, strings.TrimSpace(s.Loc))
+	}
+	for _, u := range doc.URLs {
+		if len(out.Sitemaps)+len(out.URLs) == limit {
+			break
+		}
+		u.Loc = strings.TrimSpace(u.Loc)
+		out.URLs = append(out.URLs, u)
+	}
+	out.Truncated = resp.Truncated || out.Total > limit
+	return out, nil
+}
+
+type JSONInput struct {
+	URL     string            `json:"url" jsonschema:"http or https URL of the API"`
+	Method  string            `json:"method,omitempty" jsonschema:"HTTP method, default GET, or POST when a body is given"`
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"request headers, e.g. Authorization"`
+	Query   map[string]string `json:"query,omitempty" jsonschema:"query parameters added to the URL"`
+	Body    any               `json:"body,omitempty" jsonschema:"JSON value sent as the request body"`
+	// TimeoutSeconds and MaxBytes only lower the server limits.
+	TimeoutSeconds int   `json:"timeout_seconds,omitempty" jsonschema:"give up after this many seconds"`
+	MaxBytes       int64 `json:"max_bytes,omitempty" jsonschema:"read at most this many bytes of the response"`
+}
+
+type JSONOutput struct {
+	URL     string            `json:"url"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Data is the parsed response, Text the body when it is not JSON.
+	Data  any        `json:"data,omitempty"`
+	Text  string     `json:"text,omitempty"`
+	Error *ToolError `json:"error,omitempty"`
+}
+
+// JSON calls a JSON API and returns the parsed response.
+func (f *Fetcher) JSON(ctx context.Context, in JSONInput) (JSONOutput, error) {
+	target, err := url.Parse(strings.TrimSpace(in.URL))
+	if err != nil {
+		return JSONOutput{}, invalid(in.URL, fmt.Sprintf("invalid url: %v", err))
+	}
+	if len(in.Query) > 0 {
+		q := target.Query()
+		for k, v := range in.Query {
+			q.Set(k, v)
+		}
+		target.RawQuery = q.Encode()
+	}
+
+	// API calls are not cached: keys often go in custom headers or in the
+	// query, and cached pages are published with their URL
+	req := FetchInput{URL: target.String(), Method: in.Method, TimeoutSeconds: in.TimeoutSeconds, MaxBytes: in.MaxBytes,
+		Headers: map[string]string{"Accept": "application/json"}, NoCache: true}
+	if in.Body != nil {
+		body, err := json.Marshal(in.Body)
+		if err != nil {
+			return JSONOutput{}, invalid(in.URL, fmt.Sprintf("encode body: %v", err))
+		}
+		req.Body = string(body)
+		req.Headers["Content-Type"] = "application/json"
+		if req.Method == "" {
+			req.Method = http.MethodPost
+		}
+	}
+	for k, v := range in.Headers {
+		req.Headers[k] = v
+	}
+
+	resp, err := f.Do(ctx, req)
+	if err != nil {
+		return JSONOutput{}, err
+	}
+	out := JSONOutput{URL: resp.URL.String(), Status: resp.Status, Headers: flattenHeaders(resp.Header)}
+	if len(bytes.TrimSpace(resp.Body)) == 0 {
+		return out, nil
+	}
+	if resp.Truncated {
+		out.Text = string(resp.Body)
+		return out, &ToolError{Code: CodeFetchFailed, Message: "response is larger than max_bytes, its JSON cannot be parsed", URL: out.URL}
+	}
+	if err := json.Unmarshal(resp.Body, &out.Data); err != nil {
+		// error pages are often HTML or text, return them as such
+		out.Text, _, _, _ = render(resp.Body, resp.Header.Get("Content-Type"), resp.URL, ModeText)
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/manager"
+	"github.com/dkr290/go-advanced-projects/orchestrator/store"
+	"github.com/spf13/cobra"
+)
+
+var managerCmd = &cobra.Command{
+	Use:   "manager",
+	Short: "Start the manager",
+	Long:  `Start the manager API and the scheduling loop. Workers register themselves with their heartbeats.`,
+	RunE:  runManager,
+}
+
+func init() {
+	rootCmd.AddCommand(managerCmd)
+	managerCmd.Flags().String("addr", "localhost:5555", "address the manager API listens on")
+	managerCmd.Flags().StringSlice("workers", nil, "worker API addresses known in advance")
+	managerCmd.Flags().String("store", string(store.Persistent), "task store, memory or persistent")
+	managerCmd.Flags().String("data-dir", ".", "directory of the persistent store")
+	managerCmd.Flags().Duration("interval", 5*time.Second, "how often pending work is sent and tasks are refreshed")
+	managerCmd.Flags().Duration("heartbeat-interval", 5*time.Second, "expected interval between worker heartbeats")
+	managerCmd.Flags().Int("missed-heartbeats", 3, "missed heartbeats before a worker is marked dead")
+}
+
+func runManager(cmd *cobra.Command, args []string) error {
+	addr, _ := cmd.Flags().GetString("addr")
+	workers, _ := cmd.Flags().GetStringSlice("workers")
+	dbType, _ := cmd.Flags().GetString("store")
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	heartbeat, _ := cmd.Flags().GetDuration("heartbeat-interval")
+	missed, _ := cmd.Flags().GetInt("missed-heartbeats")
+
+	m, err := manager.New(workers, store.Type(dbType), dataDir)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	m.HeartbeatInterval = heartbeat
+	m.MissedHeartbeats = missed
+
+	recovered, err := m.Recover()
+	if err != nil {
+		return err
+	}
+	if recovered > 0 {
+		log.Printf("manager: recovered %d pending tasks", recovered)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	api := manager.Api{Address: addr, Manager: m}
+	errCh := make(chan error, 1)
+	go func() { errCh <- api.Start() }()
+	go m.Run(ctx, interval)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var nodesCmd = &cobra.Command{
+	Use:   "nodes",
+	Short: "List the workers known to the manager",
+	Args:  cobra.NoArgs,
+	RunE:  runNodes,
+}
+
+func init() {
+	rootCmd.AddCommand(nodesCmd)
+}
+
+func runNodes(cmd *cobra.Command, args []string) error {
+	nodes, err := newClient().Nodes(cmd.Context())
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tAPI\tSTATUS\tCORES\tCPU\tLOAD\tMEMORY (MiB)\tDISK (GiB)\tTASKS\tLAST HEARTBEAT")
+	for _, n := range nodes {
+		status := "dead"
+		if n.Alive {
+			status = "alive"
+		} else if n.LastHeartbeat.IsZero() {
+			status = "unknown"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f%%\t%.2f %.2f %.2f\t%d/%d\t%d/%d\t%d\t%s\n",
+			n.Name, n.Api, status, n.Cores, n.CPUUsage, n.Load1, n.Load5, n.Load15,
+			n.MemoryAllocated, n.Memory, n.DiskAllocated, n.Disk, n.TaskCount, formatTime(n.LastHeartbeat))
+	}
+	return tw.Flush()
+}
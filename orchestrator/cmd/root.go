@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/manager"
+	"github.com/spf13/cobra"
+)
+
+var managerURL string
+
+// rootCmd represents the base command
+var rootCmd = &cobra.Command{
+	Use:   "orchestrator",
+	Short: "A small container orchestrator",
+	Long: `Run a manager and workers that schedule docker containers,
+then submit, inspect and stop tasks from the same binary.`,
+	SilenceUsage: true,
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+func Execute() error {
+	return rootCmd.Execute()
+}
+
+func init() {
+	defaultManager := os.Getenv("ORCHESTRATOR_MANAGER")
+	if defaultManager == "" {
+		defaultManager = "http://localhost:5555"
+	}
+	rootCmd.PersistentFlags().StringVar(&managerURL, "manager", defaultManager, "manager API address (env ORCHESTRATOR_MANAGER)")
+}
+
+// newClient returns a client for the manager selected with --manager
+func newClient() *manager.Client {
+	return manager.NewClient(managerURL)
+}
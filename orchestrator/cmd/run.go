@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/task"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run -f task.yaml",
+	Short: "Submit a task to the manager",
+	Long:  `Load a task spec from a YAML or JSON file and submit it to the manager.`,
+	Args:  cobra.NoArgs,
+	RunE:  runTask,
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringP("file", "f", "", "task spec file (.yaml, .yml or .json)")
+	_ = runCmd.MarkFlagRequired("file")
+}
+
+func runTask(cmd *cobra.Command, args []string) error {
+	file, _ := cmd.Flags().GetString("file")
+	spec, err := task.LoadSpec(file)
+	if err != nil {
+		return err
+	}
+
+	t, err := newClient().Run(cmd.Context(), spec.Task())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "task %s (%s) submitted\n", t.ID, t.Name)
+	return nil
+}
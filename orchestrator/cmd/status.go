@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [id]",
+	Short: "Show tasks known to the manager",
+	Long:  `List all tasks, or show one task with its worker and event history.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	c := newClient()
+	out := cmd.OutOrStdout()
+
+	if len(args) == 1 {
+		id, err := resolveTaskID(cmd.Context(), c, args[0])
+		if err != nil {
+			return err
+		}
+		status, err := c.Task(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		t := status.Task
+		fmt.Fprintf(out, "ID:        %s\nName:      %s\nImage:     %s\nState:     %s\nWorker:    %s\nContainer: %s\nRestarts:  %d\nStarted:   %s\nFinished:  %s\n",
+			t.ID, t.Name, t.Image, t.State, status.Worker, shortID(t.ContainerID), t.RestartCount, formatTime(t.StartTime), formatTime(t.FinishTime))
+		fmt.Fprintln(out, "\nEvents:")
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, e := range status.History {
+			fmt.Fprintf(tw, "  %s\t%s\n", formatTime(e.TimeStamp), e.State)
+		}
+		return tw.Flush()
+	}
+
+	tasks, err := c.Tasks(cmd.Context())
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tIMAGE\tSTATE\tRESTARTS\tSTARTED")
+	for _, t := range tasks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", t.ID, t.Name, t.Image, t.State, t.RestartCount, formatTime(t.StartTime))
+	}
+	return tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	if id == "" {
+		return "-"
+	}
+	return id
+}
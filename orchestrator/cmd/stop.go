@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/manager"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop <id>",
+	Short: "Stop a task",
+	Long:  `Ask the manager to stop a task. A unique prefix of the task ID is enough.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStop,
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+}
+
+func runStop(cmd *cobra.Command, args []string) error {
+	c := newClient()
+	id, err := resolveTaskID(cmd.Context(), c, args[0])
+	if err != nil {
+		return err
+	}
+	if err := c.Stop(cmd.Context(), id); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "task %s stopping\n", id)
+	return nil
+}
+
+// resolveTaskID accepts a full task ID or a unique prefix of one
+func resolveTaskID(ctx context.Context, c *manager.Client, arg string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(arg); err == nil {
+		return id, nil
+	}
+	tasks, err := c.Tasks(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var matches []uuid.UUID
+	for _, t := range tasks {
+		if strings.HasPrefix(t.ID.String(), arg) {
+			matches = append(matches, t.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return uuid.Nil, fmt.Errorf("no task matches %q", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return uuid.Nil, fmt.Errorf("%q matches %d tasks", arg, len(matches))
+	}
+}
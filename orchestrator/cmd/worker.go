@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/store"
+	"github.com/dkr290/go-advanced-projects/orchestrator/task"
+	"github.com/dkr290/go-advanced-projects/orchestrator/worker"
+	"github.com/spf13/cobra"
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Start a worker",
+	Long:  `Start a worker that runs tasks with docker and sends heartbeats to the manager.`,
+	RunE:  runWorker,
+}
+
+func init() {
+	rootCmd.AddCommand(workerCmd)
+	hostname, _ := os.Hostname()
+	workerCmd.Flags().String("name", hostname, "worker name")
+	workerCmd.Flags().String("addr", "localhost:5556", "address the worker API listens on")
+	workerCmd.Flags().String("advertise", "", "address the manager uses to reach the worker (default --addr)")
+	workerCmd.Flags().String("store", string(store.Persistent), "task store, memory or persistent")
+	workerCmd.Flags().String("data-dir", ".", "directory of the persistent store")
+	workerCmd.Flags().Duration("interval", 2*time.Second, "how often queued tasks and containers are checked")
+	workerCmd.Flags().Duration("heartbeat-interval", 5*time.Second, "interval between heartbeats to the manager")
+}
+
+func runWorker(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("name")
+	addr, _ := cmd.Flags().GetString("addr")
+	advertise, _ := cmd.Flags().GetString("advertise")
+	dbType, _ := cmd.Flags().GetString("store")
+	dataDir, _ := cmd.Flags().GetString("data-dir")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	heartbeat, _ := cmd.Flags().GetDuration("heartbeat-interval")
+	if advertise == "" {
+		advertise = addr
+	}
+
+	rt, err := task.NewDocker()
+	if err != nil {
+		return err
+	}
+	w, err := worker.New(name, rt, store.Type(dbType), dataDir)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	running, err := w.Recover()
+	if err != nil {
+		return err
+	}
+	if len(running) > 0 {
+		log.Printf("worker %s: recovered %d running tasks", name, len(running))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	api := worker.Api{Address: addr, Worker: w}
+	errCh := make(chan error, 1)
+	go func() { errCh <- api.Start() }()
+	go w.Run(ctx, interval)
+	go w.Heartbeat(ctx, newClient().BaseURL, advertise, heartbeat)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
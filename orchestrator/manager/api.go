@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/node"
+	"github.com/dkr290/go-advanced-projects/orchestrator/store"
+	"github.com/dkr290/go-advanced-projects/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+// Api exposes the manager to users and workers over HTTP
+type Api struct {
+	Address string
+	Manager *Manager
+}
+
+// TaskStatus is a task together with the worker running it and its event history
+type TaskStatus struct {
+	Task    *task.Task
+	Worker  string
+	History []*task.TaskEvent
+}
+
+func (a *Api) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", a.StartTaskHandler)
+	mux.HandleFunc("GET /tasks", a.GetTasksHandler)
+	mux.HandleFunc("GET /tasks/{id}", a.GetTaskHandler)
+	mux.HandleFunc("DELETE /tasks/{id}", a.StopTaskHandler)
+	mux.HandleFunc("POST /nodes/heartbeat", a.HeartbeatHandler)
+	mux.HandleFunc("GET /nodes", a.GetNodesHandler)
+	return mux
+}
+
+func (a *Api) Start() error {
+	log.Printf("manager listening on %s", a.Address)
+	return http.ListenAndServe(a.Address, a.Routes())
+}
+
+func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var te task.TaskEvent
+	if err := json.NewDecoder(r.Body).Decode(&te); err != nil {
+		writeError(w, http.StatusBadRequest, "unable to decode task event: "+err.Error())
+		return
+	}
+	if te.Task.Image == "" {
+		writeError(w, http.StatusBadRequest, "task image is required")
+		return
+	}
+	if te.ID == uuid.Nil {
+		te.ID = uuid.New()
+	}
+	if te.Task.ID == uuid.Nil {
+		te.Task.ID = uuid.New()
+	}
+	if te.TimeStamp.IsZero() {
+		te.TimeStamp = time.Now().UTC()
+	}
+	te.State = task.Scheduled
+	te.Task.State = task.Pending
+
+	if err := a.Manager.AddTask(te); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, te.Task)
+}
+
+func (a *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, err := a.Manager.TaskDb.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (a *Api) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+	t, err := a.Manager.TaskDb.Get(id.String())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	history, err := a.Manager.TaskHistory(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.Manager.mu.Lock()
+	worker := a.Manager.TaskWorkerMap[id]
+	a.Manager.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, TaskStatus{Task: t, Worker: worker, History: history})
+}
+
+func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+	if err := a.Manager.StopTask(id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	var n node.Node
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		writeError(w, http.StatusBadRequest, "unable to decode heartbeat: "+err.Error())
+		return
+	}
+	if err := a.Manager.Heartbeat(n); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.Manager.ListNodes())
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("unable to encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/node"
+	"github.com/dkr290/go-advanced-projects/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+// Client talks to the manager Api, it is used by the CLI
+type Client struct {
+	BaseURL string
+	http    *http.Client
+}
+
+func NewClient(baseURL string) *Client {
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run submits a task to the manager and returns it as accepted
+func (c *Client) Run(ctx context.Context, t task.Task) (task.Task, error) {
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		TimeStamp: time.Now().UTC(),
+		Task:      t,
+	}
+	var accepted task.Task
+	err := c.do(ctx, http.MethodPost, "/tasks", te, &accepted)
+	return accepted, err
+}
+
+func (c *Client) Tasks(ctx context.Context) ([]*task.Task, error) {
+	var tasks []*task.Task
+	err := c.do(ctx, http.MethodGet, "/tasks", nil, &tasks)
+	return tasks, err
+}
+
+func (c *Client) Task(ctx context.Context, id uuid.UUID) (TaskStatus, error) {
+	var status TaskStatus
+	err := c.do(ctx, http.MethodGet, "/tasks/"+id.String(), nil, &status)
+	return status, err
+}
+
+func (c *Client) Stop(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, "/tasks/"+id.String(), nil, nil)
+}
+
+func (c *Client) Nodes(ctx context.Context) ([]node.Node, error) {
+	var nodes []node.Node
+	err := c.do(ctx, http.MethodGet, "/nodes", nil, &nodes)
+	return nodes, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr map[string]string
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr["error"] != "" {
+			return fmt.Errorf("manager: %s", apiErr["error"])
+		}
+		return fmt.Errorf("manager returned %s", resp.Status)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
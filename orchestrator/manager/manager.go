@@ -1,20 +1,307 @@
 package manager
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/dkr290/go-advanced-projects/orchestrator/node"
+	"github.com/dkr290/go-advanced-projects/orchestrator/store"
 	"github.com/dkr290/go-advanced-projects/orchestrator/task"
 	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 )
 
+// ErrNoWorker is returned by SelectWorker when no live worker can take the task
+var ErrNoWorker = errors.New("no available worker")
+
 type Manager struct {
 	Pending        queue.Queue
-	TaskDb         map[string][]*task.Task
-	EventDb        map[string][]*task.TaskEvent
+	TaskDb         store.Store[*task.Task]
+	EventDb        store.Store[*task.TaskEvent]
 	Workers        []string
 	WorkersTaskMap map[string][]uuid.UUID //the jobs that are assigned to each worker
 	TaskWorkerMap  map[uuid.UUID]string   //TaskWorkerMap, which is a map of task UUIDs to strings,where the string is the name of the worker
+	Nodes          map[string]*node.Node  //last heartbeat of each worker, keyed like Workers by the worker API address
+
+	HeartbeatInterval time.Duration
+	MissedHeartbeats  int //a worker is dead after this many missed heartbeats
+
+	mu     sync.Mutex
+	client *http.Client
+}
+
+// New creates a manager for the given workers. With store.Persistent the
+// tasks and events are kept in dataDir so the manager can recover after a restart
+func New(workers []string, dbType store.Type, dataDir string) (*Manager, error) {
+	taskDb, err := store.New[*task.Task](dbType, filepath.Join(dataDir, "manager_tasks.db"), "tasks")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create task store: %w", err)
+	}
+	eventDb, err := store.New[*task.TaskEvent](dbType, filepath.Join(dataDir, "manager_events.db"), "events")
+	if err != nil {
+		taskDb.Close()
+		return nil, fmt.Errorf("unable to create event store: %w", err)
+	}
+
+	workersTaskMap := make(map[string][]uuid.UUID)
+	for _, w := range workers {
+		workersTaskMap[w] = []uuid.UUID{}
+	}
+
+	return &Manager{
+		Pending:           *queue.New(),
+		TaskDb:            taskDb,
+		EventDb:           eventDb,
+		Workers:           workers,
+		WorkersTaskMap:    workersTaskMap,
+		TaskWorkerMap:     make(map[uuid.UUID]string),
+		Nodes:             make(map[string]*node.Node),
+		HeartbeatInterval: 5 * time.Second,
+		MissedHeartbeats:  3,
+		client:            &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// AddTask records the event and the task it carries and queues it for scheduling
+func (m *Manager) AddTask(te task.TaskEvent) error {
+	if err := m.EventDb.Put(te.ID.String(), &te); err != nil {
+		return fmt.Errorf("unable to store event %s: %w", te.ID, err)
+	}
+	t := te.Task
+	if err := m.TaskDb.Put(t.ID.String(), &t); err != nil {
+		return fmt.Errorf("unable to store task %s: %w", t.ID, err)
+	}
+	m.mu.Lock()
+	m.Pending.Enqueue(te)
+	m.mu.Unlock()
+	return nil
+}
+
+// StopTask queues a request to stop a task on the worker running it
+func (m *Manager) StopTask(id uuid.UUID) error {
+	t, err := m.TaskDb.Get(id.String())
+	if err != nil {
+		return err
+	}
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Completed,
+		TimeStamp: time.Now().UTC(),
+		Task:      *t,
+	}
+	if err := m.EventDb.Put(te.ID.String(), &te); err != nil {
+		return fmt.Errorf("unable to store event %s: %w", te.ID, err)
+	}
+	m.mu.Lock()
+	m.Pending.Enqueue(te)
+	m.mu.Unlock()
+	return nil
+}
+
+// TaskHistory returns all events recorded for a task, oldest first
+func (m *Manager) TaskHistory(id uuid.UUID) ([]*task.TaskEvent, error) {
+	events, err := m.EventDb.List()
+	if err != nil {
+		return nil, err
+	}
+	var history []*task.TaskEvent
+	for _, e := range events {
+		if e.Task.ID == id {
+			history = append(history, e)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].TimeStamp.Before(history[j].TimeStamp)
+	})
+	return history, nil
+}
+
+// Recover rebuilds the pending queue from the task store after a restart.
+// Tasks that were still pending are queued again, the latest event of each task is reused.
+// The worker assignments of running tasks come back with the first UpdateTasks
+func (m *Manager) Recover() (int, error) {
+	tasks, err := m.TaskDb.List()
+	if err != nil {
+		return 0, fmt.Errorf("unable to list tasks: %w", err)
+	}
+
+	recovered := 0
+	for _, t := range tasks {
+		if t.State != task.Pending {
+			continue
+		}
+		history, err := m.TaskHistory(t.ID)
+		if err != nil {
+			return recovered, err
+		}
+		if len(history) == 0 {
+			continue
+		}
+		te := *history[len(history)-1]
+		te.Task = *t
+		m.mu.Lock()
+		m.Pending.Enqueue(te)
+		m.mu.Unlock()
+		recovered++
+	}
+	return recovered, nil
+}
+
+// Close releases the underlying stores
+func (m *Manager) Close() error {
+	return errors.Join(m.TaskDb.Close(), m.EventDb.Close())
+}
+
+// Heartbeat records the stats a worker reported, unknown workers are registered
+func (m *Manager) Heartbeat(n node.Node) error {
+	if n.Api == "" {
+		return fmt.Errorf("heartbeat of %s without api address", n.Name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.Contains(m.Workers, n.Api) {
+		m.Workers = append(m.Workers, n.Api)
+		log.Printf("manager: registered worker %s (%s)", n.Name, n.Api)
+	}
+	if _, ok := m.WorkersTaskMap[n.Api]; !ok {
+		m.WorkersTaskMap[n.Api] = []uuid.UUID{}
+	}
+	if prev, ok := m.Nodes[n.Api]; ok && !prev.Alive {
+		log.Printf("manager: worker %s (%s) is back", n.Name, n.Api)
+	}
+	n.LastHeartbeat = time.Now().UTC()
+	n.Alive = true
+	m.Nodes[n.Api] = &n
+	return nil
+}
+
+// ListNodes returns the last known state of every worker
+func (m *Manager) ListNodes() []node.Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nodes := make([]node.Node, 0, len(m.Workers))
+	for _, w := range m.Workers {
+		if n, ok := m.Nodes[w]; ok {
+			nodes = append(nodes, *n)
+			continue
+		}
+		nodes = append(nodes, node.Node{Api: w})
+	}
+	return nodes
+}
+
+// CheckWorkers marks workers that missed their heartbeats as dead and
+// reschedules their tasks elsewhere
+func (m *Manager) CheckWorkers() {
+	timeout := m.HeartbeatInterval * time.Duration(m.MissedHeartbeats)
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	var dead []string
+	for api, n := range m.Nodes {
+		if n.Alive && now.Sub(n.LastHeartbeat) > timeout {
+			n.Alive = false
+			dead = append(dead, api)
+			log.Printf("manager: worker %s (%s) missed %d heartbeats, marking dead", n.Name, api, m.MissedHeartbeats)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, api := range dead {
+		m.rescheduleTasks(api)
+	}
+}
+
+// rescheduleTasks moves the tasks of a dead worker according to their RestartPolicy
+func (m *Manager) rescheduleTasks(worker string) {
+	m.mu.Lock()
+	ids := m.WorkersTaskMap[worker]
+	m.WorkersTaskMap[worker] = []uuid.UUID{}
+	for _, id := range ids {
+		delete(m.TaskWorkerMap, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			log.Printf("manager: task %s: %v", id, err)
+			continue
+		}
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+
+		if !t.ShouldRestart(true) {
+			t.State = task.Failed
+			t.FinishTime = time.Now().UTC()
+			m.recordTask(t)
+			log.Printf("manager: task %s lost with worker %s", id, worker)
+			continue
+		}
+
+		t.RestartCount++
+		t.State = task.Pending
+		t.ContainerID = ""
+		te := task.TaskEvent{
+			ID:        uuid.New(),
+			State:     task.Scheduled,
+			TimeStamp: time.Now().UTC(),
+			Task:      *t,
+		}
+		if err := m.AddTask(te); err != nil {
+			log.Printf("manager: task %s: %v", id, err)
+			continue
+		}
+		log.Printf("manager: rescheduling task %s from worker %s", id, worker)
+	}
+}
+
+// recordTask stores the task and an event with its new state
+func (m *Manager) recordTask(t *task.Task) {
+	if err := m.TaskDb.Put(t.ID.String(), t); err != nil {
+		log.Printf("manager: task %s: %v", t.ID, err)
+		return
+	}
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     t.State,
+		TimeStamp: time.Now().UTC(),
+		Task:      *t,
+	}
+	if err := m.EventDb.Put(te.ID.String(), &te); err != nil {
+		log.Printf("manager: event %s: %v", te.ID, err)
+	}
+}
+
+// Run is the control loop of the manager, on every tick it sends the pending
+// work, refreshes the task states from the workers and checks their heartbeats
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.CheckWorkers()
+		for m.SendWork(ctx) {
+		}
+		m.UpdateTasks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 //SelectWorker() - This method will be responsible for looking at the requirements
@@ -22,16 +309,188 @@ type Manager struct {
 //which worker is best suited to run the task.
 //the Manager must keep track of tasks, their states, and the machine on which they run
 
-func (m *Manager) SelectWorker() {
-	fmt.Println("I will select the appropriate worker")
+func (m *Manager) SelectWorker(t task.Task) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	selected := ""
+	for _, w := range m.Workers {
+		n, ok := m.Nodes[w]
+		if !ok || !n.Alive {
+			continue
+		}
+		if n.Memory > 0 && n.Memory-n.MemoryAllocated < t.Memory {
+			continue
+		}
+		if n.Disk > 0 && n.Disk-n.DiskAllocated < t.Disk {
+			continue
+		}
+		if selected == "" || len(m.WorkersTaskMap[w]) < len(m.WorkersTaskMap[selected]) {
+			selected = w
+		}
+	}
+	if selected == "" {
+		return "", ErrNoWorker
+	}
+	return selected, nil
+}
+
+// UpdateTasks() - this method asks every live worker for its tasks and brings the
+// task store up to date, it also rebuilds the worker assignments after a manager restart
+
+func (m *Manager) UpdateTasks(ctx context.Context) {
+	m.mu.Lock()
+	var workers []string
+	for _, w := range m.Workers {
+		if n, ok := m.Nodes[w]; ok && n.Alive {
+			workers = append(workers, w)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, w := range workers {
+		var tasks []*task.Task
+		if err := m.doJSON(ctx, http.MethodGet, "http://"+w+"/tasks", nil, &tasks); err != nil {
+			log.Printf("manager: unable to get tasks of worker %s: %v", w, err)
+			continue
+		}
+		for _, reported := range tasks {
+			m.updateTask(ctx, w, reported)
+		}
+	}
+}
+
+func (m *Manager) updateTask(ctx context.Context, worker string, reported *task.Task) {
+	persisted, err := m.TaskDb.Get(reported.ID.String())
+	if err != nil {
+		log.Printf("manager: worker %s reported unknown task %s", worker, reported.ID)
+		return
+	}
+
+	m.mu.Lock()
+	owner, ok := m.TaskWorkerMap[reported.ID]
+	if !ok && persisted.State != task.Pending {
+		owner = worker
+		m.TaskWorkerMap[reported.ID] = worker
+		m.WorkersTaskMap[worker] = append(m.WorkersTaskMap[worker], reported.ID)
+	}
+	m.mu.Unlock()
+
+	if owner != worker {
+		// the task was rescheduled while this worker was dead
+		if reported.State == task.Running {
+			log.Printf("manager: stopping stale copy of task %s on worker %s", reported.ID, worker)
+			if err := m.doJSON(ctx, http.MethodDelete, "http://"+worker+"/tasks/"+reported.ID.String(), nil, nil); err != nil {
+				log.Printf("manager: %v", err)
+			}
+		}
+		return
+	}
+
+	changed := persisted.State != reported.State || persisted.RestartCount != reported.RestartCount
+	persisted.State = reported.State
+	persisted.ContainerID = reported.ContainerID
+	persisted.StartTime = reported.StartTime
+	persisted.FinishTime = reported.FinishTime
+	persisted.RestartCount = reported.RestartCount
+	if changed {
+		m.recordTask(persisted)
+		return
+	}
+	if err := m.TaskDb.Put(persisted.ID.String(), persisted); err != nil {
+		log.Printf("manager: task %s: %v", persisted.ID, err)
+	}
+}
+
+// SendWork takes one event from the pending queue and sends it to a worker,
+// it reports false when there was nothing that could be sent
+
+func (m *Manager) SendWork(ctx context.Context) bool {
+	m.mu.Lock()
+	if m.Pending.Len() == 0 {
+		m.mu.Unlock()
+		return false
+	}
+	te := m.Pending.Dequeue().(task.TaskEvent)
+	m.mu.Unlock()
+
+	if te.State == task.Completed {
+		m.sendStop(ctx, te)
+		return true
+	}
+
+	w, err := m.SelectWorker(te.Task)
+	if err != nil {
+		// keep the task queued until a worker is available
+		m.mu.Lock()
+		m.Pending.Enqueue(te)
+		m.mu.Unlock()
+		return false
+	}
+
+	if err := m.doJSON(ctx, http.MethodPost, "http://"+w+"/tasks", te, nil); err != nil {
+		log.Printf("manager: unable to send task %s to worker %s: %v", te.Task.ID, w, err)
+		m.mu.Lock()
+		m.Pending.Enqueue(te)
+		m.mu.Unlock()
+		return false
+	}
+
+	m.mu.Lock()
+	m.TaskWorkerMap[te.Task.ID] = w
+	m.WorkersTaskMap[w] = append(m.WorkersTaskMap[w], te.Task.ID)
+	m.mu.Unlock()
+
+	t := te.Task
+	t.State = task.Scheduled
+	m.recordTask(&t)
+	log.Printf("manager: sent task %s to worker %s", t.ID, w)
+	return true
 }
 
-// UpdateTasks() - this method triggewr call to workers CollectStats()
+func (m *Manager) sendStop(ctx context.Context, te task.TaskEvent) {
+	m.mu.Lock()
+	w, ok := m.TaskWorkerMap[te.Task.ID]
+	m.mu.Unlock()
 
-func (m *Manager) UpdateTasks() {
-	fmt.Println("I will update tasks")
+	if !ok {
+		// never reached a worker, nothing is running
+		t := te.Task
+		t.State = task.Completed
+		t.FinishTime = time.Now().UTC()
+		m.recordTask(&t)
+		return
+	}
+	if err := m.doJSON(ctx, http.MethodDelete, "http://"+w+"/tasks/"+te.Task.ID.String(), nil, nil); err != nil {
+		log.Printf("manager: unable to stop task %s on worker %s: %v", te.Task.ID, w, err)
+		return
+	}
+	log.Printf("manager: asked worker %s to stop task %s", w, te.Task.ID)
 }
 
-func (*Manager) SendWorkd() {
-	fmt.Println("I will send work to workers")
+// doJSON sends in as the JSON body and decodes the response into out when set
+func (m *Manager) doJSON(ctx context.Context, method, url string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s returned %s", method, url, resp.Status)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
 }
@@ -1,13 +1,22 @@
 package node
 
+import "time"
+
 type Node struct {
 	Name            string
 	Ip              string
+	Api             string //address of the worker API, host:port
 	Cores           int
-	Memory          int
-	MemoryAllocated int
-	Disk            int
-	DiskAllocated   int
+	CPUUsage        float64 //percent across all cores since the previous sample
+	Load1           float64
+	Load5           float64
+	Load15          float64
+	Memory          int //MiB
+	MemoryAllocated int //MiB
+	Disk            int //GiB
+	DiskAllocated   int //GiB
 	Role            string
 	TaskCount       int
+	LastHeartbeat   time.Time
+	Alive           bool
 }
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore persists JSON encoded values in a single bucket of an embedded bolt database
+type BoltStore[T any] struct {
+	Db     *bolt.DB
+	DbFile string
+	Bucket string
+}
+
+func NewBoltStore[T any](file string, mode os.FileMode, bucket string) (*BoltStore[T], error) {
+	db, err := bolt.Open(file, mode, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", file, err)
+	}
+
+	s := &BoltStore[T]{
+		Db:     db,
+		DbFile: file,
+		Bucket: bucket,
+	}
+	if err := s.CreateBucket(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *BoltStore[T]) CreateBucket() error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(s.Bucket)); err != nil {
+			return fmt.Errorf("create bucket %s: %w", s.Bucket, err)
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore[T]) Put(key string, value T) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(s.Bucket)).Put([]byte(key), buf)
+	})
+}
+
+func (s *BoltStore[T]) Get(key string) (T, error) {
+	var value T
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket([]byte(s.Bucket)).Get([]byte(key))
+		if buf == nil {
+			return fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return json.Unmarshal(buf, &value)
+	})
+	return value, err
+}
+
+// List returns the values ordered by key, bolt keeps keys sorted
+func (s *BoltStore[T]) List() ([]T, error) {
+	var values []T
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(s.Bucket)).ForEach(func(k, v []byte) error {
+			var value T
+			if err := json.Unmarshal(v, &value); err != nil {
+				return fmt.Errorf("decode %s: %w", k, err)
+			}
+			values = append(values, value)
+			return nil
+		})
+	})
+	return values, err
+}
+
+func (s *BoltStore[T]) Count() (int, error) {
+	count := 0
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket([]byte(s.Bucket)).Stats().KeyN
+		return nil
+	})
+	return count, err
+}
+
+func (s *BoltStore[T]) Close() error {
+	return s.Db.Close()
+}
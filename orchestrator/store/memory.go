@@ -0,0 +1,82 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// InMemoryStore keeps values in a map, it is lost on restart.
+// Values are stored JSON encoded like in the bolt store, so callers get
+// their own copy and can change it without racing other readers
+type InMemoryStore[T any] struct {
+	mu sync.RWMutex
+	db map[string][]byte
+}
+
+func NewInMemoryStore[T any]() *InMemoryStore[T] {
+	return &InMemoryStore[T]{
+		db: make(map[string][]byte),
+	}
+}
+
+func (s *InMemoryStore[T]) Put(key string, value T) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db[key] = buf
+	return nil
+}
+
+func (s *InMemoryStore[T]) Get(key string) (T, error) {
+	s.mu.RLock()
+	buf, ok := s.db[key]
+	s.mu.RUnlock()
+	var value T
+	if !ok {
+		return value, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err := json.Unmarshal(buf, &value); err != nil {
+		return value, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// List returns the values ordered by key so the output is stable
+func (s *InMemoryStore[T]) List() ([]T, error) {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.db))
+	for k := range s.db {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	bufs := make([][]byte, len(keys))
+	for i, k := range keys {
+		bufs[i] = s.db[k]
+	}
+	s.mu.RUnlock()
+
+	values := make([]T, 0, len(keys))
+	for i, buf := range bufs {
+		var value T
+		if err := json.Unmarshal(buf, &value); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", keys[i], err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (s *InMemoryStore[T]) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.db), nil
+}
+
+func (s *InMemoryStore[T]) Close() error {
+	return nil
+}
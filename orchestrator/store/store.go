@@ -0,0 +1,41 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by Get when there is no value stored under the key
+var ErrNotFound = errors.New("key not found")
+
+// Store is the persistence layer shared by the manager and the worker.
+// The manager keeps task.Task and task.TaskEvent history in it and the worker
+// keeps the tasks it is running, so both can rebuild their state after a restart
+type Store[T any] interface {
+	Put(key string, value T) error
+	Get(key string) (T, error)
+	List() ([]T, error)
+	Count() (int, error)
+	Close() error
+}
+
+// Type selects which Store implementation is used
+type Type string
+
+const (
+	Memory     Type = "memory"
+	Persistent Type = "persistent"
+)
+
+// New creates a store of the requested type. For the persistent store
+// path is the bolt database file and bucket is the bucket inside it
+func New[T any](t Type, path, bucket string) (Store[T], error) {
+	switch t {
+	case Memory, "":
+		return NewInMemoryStore[T](), nil
+	case Persistent:
+		return NewBoltStore[T](path, 0600, bucket)
+	default:
+		return nil, fmt.Errorf("unsupported store type %q", t)
+	}
+}
@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+// Runtime starts and stops the containers backing tasks
+type Runtime interface {
+	Run(ctx context.Context, t Task) (string, error)
+	Stop(ctx context.Context, containerID string) error
+	Inspect(ctx context.Context, containerID string) (ContainerStatus, error)
+	Exec(ctx context.Context, containerID string, cmd []string) (int, error)
+}
+
+// ContainerStatus is the part of the container state the worker cares about
+type ContainerStatus struct {
+	Running  bool
+	ExitCode int
+}
+
+// Docker is the Runtime backed by the local docker daemon
+type Docker struct {
+	Client *client.Client
+}
+
+func NewDocker() (*Docker, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create docker client: %w", err)
+	}
+	return &Docker{Client: cli}, nil
+}
+
+func (d *Docker) Run(ctx context.Context, t Task) (string, error) {
+	reader, err := d.Client.ImagePull(ctx, t.Image, image.PullOptions{})
+	if err != nil {
+		return "", fmt.Errorf("pull image %s: %w", t.Image, err)
+	}
+	_, _ = io.Copy(io.Discard, reader)
+	reader.Close()
+
+	bindings := nat.PortMap{}
+	for containerPort, hostPort := range t.PortBindings {
+		bindings[nat.Port(containerPort)] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}
+	}
+
+	cc := container.Config{
+		Image:        t.Image,
+		ExposedPorts: t.ExposedPorts,
+	}
+	// restarts are handled by the worker according to the task RestartPolicy
+	hc := container.HostConfig{
+		PortBindings:    bindings,
+		PublishAllPorts: len(bindings) == 0,
+		Resources: container.Resources{
+			Memory:   int64(t.Memory) * 1024 * 1024,
+			NanoCPUs: int64(t.CPU * 1e9),
+		},
+	}
+
+	resp, err := d.Client.ContainerCreate(ctx, &cc, &hc, nil, nil, t.Name+"-"+t.ID.String()[:8])
+	if err != nil {
+		return "", fmt.Errorf("create container for %s: %w", t.Image, err)
+	}
+	if err := d.Client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return resp.ID, fmt.Errorf("start container %s: %w", resp.ID, err)
+	}
+	return resp.ID, nil
+}
+
+func (d *Docker) Stop(ctx context.Context, containerID string) error {
+	if err := d.Client.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
+		return fmt.Errorf("stop container %s: %w", containerID, err)
+	}
+	if err := d.Client.ContainerRemove(ctx, containerID, container.RemoveOptions{RemoveVolumes: true}); err != nil {
+		return fmt.Errorf("remove container %s: %w", containerID, err)
+	}
+	return nil
+}
+
+func (d *Docker) Inspect(ctx context.Context, containerID string) (ContainerStatus, error) {
+	resp, err := d.Client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return ContainerStatus{}, fmt.Errorf("inspect container %s: %w", containerID, err)
+	}
+	return ContainerStatus{
+		Running:  resp.State.Running,
+		ExitCode: resp.State.ExitCode,
+	}, nil
+}
+
+// Exec runs cmd inside the container and returns its exit code
+func (d *Docker) Exec(ctx context.Context, containerID string, cmd []string) (int, error) {
+	exec, err := d.Client.ContainerExecCreate(ctx, containerID, container.ExecOptions{Cmd: cmd})
+	if err != nil {
+		return -1, fmt.Errorf("exec create in %s: %w", containerID, err)
+	}
+	if err := d.Client.ContainerExecStart(ctx, exec.ID, container.ExecStartOptions{}); err != nil {
+		return -1, fmt.Errorf("exec start in %s: %w", containerID, err)
+	}
+
+	for {
+		resp, err := d.Client.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return -1, fmt.Errorf("exec inspect in %s: %w", containerID, err)
+		}
+		if !resp.Running {
+			return resp.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
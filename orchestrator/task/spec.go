@@ -0,0 +1,122 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the user facing description of a task as written in a YAML or JSON file
+//
+//	name: web
+//	image: strm/helloworld-http
+//	cpu: 0.5
+//	memory: 64
+//	exposedPorts: ["80/tcp"]
+//	portBindings:
+//	  80/tcp: "7777"
+//	restartPolicy: on-failure
+//	healthCheck:
+//	  path: /
+//	  interval: 10
+type Spec struct {
+	Name          string            `json:"name" yaml:"name"`
+	Image         string            `json:"image" yaml:"image"`
+	CPU           float64           `json:"cpu,omitempty" yaml:"cpu,omitempty"`
+	Memory        int               `json:"memory,omitempty" yaml:"memory,omitempty"`
+	Disk          int               `json:"disk,omitempty" yaml:"disk,omitempty"`
+	ExposedPorts  []string          `json:"exposedPorts,omitempty" yaml:"exposedPorts,omitempty"`
+	PortBindings  map[string]string `json:"portBindings,omitempty" yaml:"portBindings,omitempty"`
+	RestartPolicy string            `json:"restartPolicy,omitempty" yaml:"restartPolicy,omitempty"`
+	HealthCheck   *HealthCheck      `json:"healthCheck,omitempty" yaml:"healthCheck,omitempty"`
+}
+
+// LoadSpec reads a task spec, .json files are decoded as JSON and everything else as YAML
+func LoadSpec(path string) (Spec, error) {
+	var s Spec
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(buf, &s)
+	} else {
+		err = yaml.Unmarshal(buf, &s)
+	}
+	if err != nil {
+		return s, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return s, s.Validate()
+}
+
+func (s Spec) Validate() error {
+	if s.Image == "" {
+		return fmt.Errorf("image is required")
+	}
+	switch s.RestartPolicy {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart policy %q", s.RestartPolicy)
+	}
+	for _, p := range s.ExposedPorts {
+		if _, _, err := nat.ParsePortSpecs([]string{p}); err != nil {
+			return fmt.Errorf("invalid exposed port %q: %w", p, err)
+		}
+	}
+	for containerPort := range s.PortBindings {
+		if _, _, err := nat.ParsePortSpecs([]string{containerPort}); err != nil {
+			return fmt.Errorf("invalid port binding %q: %w", containerPort, err)
+		}
+	}
+	if hc := s.HealthCheck; hc != nil && hc.Path == "" && len(hc.Command) == 0 {
+		return fmt.Errorf("health check needs a path or a command")
+	}
+	return nil
+}
+
+// Task converts the spec into a new pending task, every bound port is also exposed
+func (s Spec) Task() Task {
+	exposed := nat.PortSet{}
+	for _, p := range s.ExposedPorts {
+		exposed[nat.Port(normalizePort(p))] = struct{}{}
+	}
+	bindings := make(map[string]string, len(s.PortBindings))
+	for containerPort, hostPort := range s.PortBindings {
+		p := normalizePort(containerPort)
+		exposed[nat.Port(p)] = struct{}{}
+		bindings[p] = hostPort
+	}
+
+	name := s.Name
+	if name == "" {
+		name = strings.NewReplacer("/", "-", ":", "-").Replace(s.Image)
+	}
+	return Task{
+		ID:            uuid.New(),
+		Name:          name,
+		State:         Pending,
+		Image:         s.Image,
+		CPU:           s.CPU,
+		Memory:        s.Memory,
+		Disk:          s.Disk,
+		ExposedPorts:  exposed,
+		PortBindings:  bindings,
+		RestartPolicy: s.RestartPolicy,
+		HealthCheck:   s.HealthCheck,
+	}
+}
+
+// normalizePort adds the default tcp protocol, 80 becomes 80/tcp
+func normalizePort(p string) string {
+	if strings.Contains(p, "/") {
+		return p
+	}
+	return p + "/tcp"
+}
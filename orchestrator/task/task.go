@@ -17,24 +17,89 @@ const (
 	Failed
 )
 
+func (s State) String() string {
+	switch s {
+	case Pending:
+		return "Pending"
+	case Scheduled:
+		return "Scheduled"
+	case Running:
+		return "Running"
+	case Completed:
+		return "Completed"
+	case Failed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
+
+// restart policies understood by the worker and the manager
+const (
+	RestartNever     = "no"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// MaxRestarts caps how many times a task is restarted or rescheduled
+const MaxRestarts = 3
+
 type Task struct {
 	ID            uuid.UUID
+	ContainerID   string
 	Name          string
 	State         State
 	Image         string
 	CPU           float64
-	Memory        int
-	Disk          int
+	Memory        int //MiB
+	Disk          int //GiB
 	ExposedPorts  nat.PortSet
-	PortBindings  map[string]string
+	PortBindings  map[string]string //container port (80/tcp) -> host port
 	RestartPolicy string
+	RestartCount  int
+	HealthCheck   *HealthCheck
 	StartTime     time.Time
 	FinishTime    time.Time
 }
 
+// HealthCheck probes a running task either with an HTTP GET on Path against
+// the first bound host port, or by running Command inside the container
+type HealthCheck struct {
+	Path     string   `json:"path,omitempty" yaml:"path,omitempty"`
+	Command  []string `json:"command,omitempty" yaml:"command,omitempty"`
+	Interval int      `json:"interval,omitempty" yaml:"interval,omitempty"` //seconds between probes
+	Retries  int      `json:"retries,omitempty" yaml:"retries,omitempty"`   //consecutive failures before the task is restarted
+}
+
 type TaskEvent struct {
 	ID        uuid.UUID
 	State     State
 	TimeStamp time.Time
 	Task      Task
 }
+
+// ShouldRestart reports whether the task is started again after it stopped,
+// failed tells if it exited with an error, failed a health check or lost its worker
+func (t *Task) ShouldRestart(failed bool) bool {
+	if t.RestartCount >= MaxRestarts {
+		return false
+	}
+	switch t.RestartPolicy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+// HostPort returns the first host port the task is bound to
+func (t *Task) HostPort() (string, bool) {
+	for _, p := range t.PortBindings {
+		if p != "" {
+			return p, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dkr290/go-advanced-projects/orchestrator/store"
+	"github.com/dkr290/go-advanced-projects/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+// Api exposes the worker to the manager over HTTP
+type Api struct {
+	Address string
+	Worker  *Worker
+}
+
+func (a *Api) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tasks", a.StartTaskHandler)
+	mux.HandleFunc("GET /tasks", a.GetTasksHandler)
+	mux.HandleFunc("DELETE /tasks/{id}", a.StopTaskHandler)
+	mux.HandleFunc("GET /stats", a.GetStatsHandler)
+	return mux
+}
+
+func (a *Api) Start() error {
+	log.Printf("worker %s listening on %s", a.Worker.Name, a.Address)
+	return http.ListenAndServe(a.Address, a.Routes())
+}
+
+func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
+	var te task.TaskEvent
+	if err := json.NewDecoder(r.Body).Decode(&te); err != nil {
+		writeError(w, http.StatusBadRequest, "unable to decode task event: "+err.Error())
+		return
+	}
+	t := te.Task
+	t.State = task.Scheduled
+	if err := a.Worker.AddTask(t); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (a *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, err := a.Worker.Tasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+	if err := a.Worker.RequestStop(id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.Worker.CollectStats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("unable to encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Heartbeat collects the node stats and posts them to the manager every interval.
+// api is the address the manager uses to reach this worker
+func (w *Worker) Heartbeat(ctx context.Context, managerURL, api string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.sendHeartbeat(ctx, managerURL, api); err != nil {
+			log.Printf("worker %s: heartbeat failed: %v", w.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) sendHeartbeat(ctx context.Context, managerURL, api string) error {
+	stats, err := w.CollectStats()
+	if err != nil {
+		return err
+	}
+	stats.Api = api
+	stats.LastHeartbeat = time.Now().UTC()
+
+	body, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, managerURL+"/nodes/heartbeat", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("manager returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// procRoot and diskPath are variables so the stats can be read from another mount
+var (
+	procRoot = "/proc"
+	diskPath = "/"
+)
+
+// cpuSample holds the aggregated jiffies from the first line of /proc/stat
+type cpuSample struct {
+	idle  uint64
+	total uint64
+}
+
+// readCPU returns the aggregated cpu counters and the number of cores
+func readCPU() (cpuSample, int, error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return cpuSample{}, 0, err
+	}
+	defer f.Close()
+
+	var sample cpuSample
+	cores := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if fields[0] != "cpu" {
+			cores++
+			continue
+		}
+		//cpu user nice system idle iowait irq softirq steal guest guest_nice
+		for i, v := range fields[1:] {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return cpuSample{}, 0, fmt.Errorf("parse /proc/stat: %w", err)
+			}
+			// guest time is already accounted in user and nice
+			if i >= 8 {
+				break
+			}
+			sample.total += n
+			if i == 3 || i == 4 {
+				sample.idle += n
+			}
+		}
+	}
+	return sample, cores, scanner.Err()
+}
+
+// cpuUsage returns the busy percentage between two samples
+func cpuUsage(prev, cur cpuSample) float64 {
+	total := cur.total - prev.total
+	if cur.total <= prev.total || total == 0 {
+		return 0
+	}
+	idle := cur.idle - prev.idle
+	return float64(total-idle) / float64(total) * 100
+}
+
+// readMemory returns total and used memory in MiB from /proc/meminfo
+func readMemory() (int, int, error) {
+	f, err := os.Open(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSuffix(fields[0], ":")] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok {
+		return 0, 0, fmt.Errorf("MemTotal missing in /proc/meminfo")
+	}
+	available, ok := values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	return int(total / 1024), int((total - available) / 1024), nil
+}
+
+// readLoad returns the 1, 5 and 15 minute load averages from /proc/loadavg
+func readLoad() (float64, float64, float64, error) {
+	buf, err := os.ReadFile(filepath.Join(procRoot, "loadavg"))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	fields := strings.Fields(string(buf))
+	if len(fields) < 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected /proc/loadavg format %q", buf)
+	}
+	var load [3]float64
+	for i := range load {
+		load[i], err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("parse /proc/loadavg: %w", err)
+		}
+	}
+	return load[0], load[1], load[2], nil
+}
+
+// readDisk returns total and used space in GiB of the filesystem holding diskPath
+func readDisk() (int, int, error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(diskPath, &fs); err != nil {
+		return 0, 0, err
+	}
+	const gib = 1 << 30
+	total := fs.Blocks * uint64(fs.Bsize)
+	free := fs.Bfree * uint64(fs.Bsize)
+	return int(total / gib), int((total - free) / gib), nil
+}
@@ -1,33 +1,373 @@
 package worker
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/dkr290/go-advanced-projects/orchestrator/node"
+	"github.com/dkr290/go-advanced-projects/orchestrator/store"
 	"github.com/dkr290/go-advanced-projects/orchestrator/task"
 	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 )
 
+// defaultHealthRetries is used when a health check does not set Retries
+const defaultHealthRetries = 3
+
 type Worker struct {
 	Name      string
 	Queue     queue.Queue
-	Db        map[uuid.UUID]*task.Task
+	Db        store.Store[*task.Task]
 	TaskCount int
+	Runtime   task.Runtime
+	Stats     node.Node
+
+	mu             sync.Mutex
+	prevCPU        cpuSample
+	healthFailures map[uuid.UUID]int
+	lastCheck      map[uuid.UUID]time.Time
+	httpClient     *http.Client
+}
+
+// New creates a worker running tasks with rt. With store.Persistent the tasks
+// are kept in dataDir so the worker knows what it was running after a restart
+func New(name string, rt task.Runtime, dbType store.Type, dataDir string) (*Worker, error) {
+	db, err := store.New[*task.Task](dbType, filepath.Join(dataDir, name+"_tasks.db"), "tasks")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create task store: %w", err)
+	}
+	return &Worker{
+		Name:           name,
+		Queue:          *queue.New(),
+		Db:             db,
+		Runtime:        rt,
+		Stats:          node.Node{Name: name, Role: "worker"},
+		healthFailures: make(map[uuid.UUID]int),
+		lastCheck:      make(map[uuid.UUID]time.Time),
+		httpClient:     &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+// AddTask queues a task for the worker and records it in the store
+func (w *Worker) AddTask(t task.Task) error {
+	if err := w.Db.Put(t.ID.String(), &t); err != nil {
+		return fmt.Errorf("unable to store task %s: %w", t.ID, err)
+	}
+	w.mu.Lock()
+	w.Queue.Enqueue(t)
+	w.mu.Unlock()
+	return nil
+}
+
+// RequestStop queues the stop of a known task. It is queued behind a pending
+// start of the task, StopTask looks up the container when it runs
+func (w *Worker) RequestStop(id uuid.UUID) error {
+	t, err := w.Db.Get(id.String())
+	if err != nil {
+		return err
+	}
+	stop := *t
+	stop.State = task.Completed
+	w.mu.Lock()
+	w.Queue.Enqueue(stop)
+	w.mu.Unlock()
+	return nil
+}
+
+// Tasks returns every task the worker knows about
+func (w *Worker) Tasks() ([]*task.Task, error) {
+	return w.Db.List()
+}
+
+// Recover reloads the task store after a restart. Tasks that were accepted
+// but never started are queued again, the running ones are returned to the caller
+func (w *Worker) Recover() ([]*task.Task, error) {
+	tasks, err := w.Db.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tasks: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var running []*task.Task
+	for _, t := range tasks {
+		switch t.State {
+		case task.Pending, task.Scheduled:
+			w.Queue.Enqueue(*t)
+		case task.Running:
+			running = append(running, t)
+		}
+	}
+	w.TaskCount = len(running)
+	return running, nil
+}
+
+// Close releases the task store
+func (w *Worker) Close() error {
+	return w.Db.Close()
+}
+
+// CollectStats reads cpu, memory, disk and load of the machine from /proc
+func (w *Worker) CollectStats() (node.Node, error) {
+	cpu, cores, err := readCPU()
+	if err != nil {
+		return node.Node{}, fmt.Errorf("unable to read cpu stats: %w", err)
+	}
+	memTotal, memUsed, err := readMemory()
+	if err != nil {
+		return node.Node{}, fmt.Errorf("unable to read memory stats: %w", err)
+	}
+	load1, load5, load15, err := readLoad()
+	if err != nil {
+		return node.Node{}, fmt.Errorf("unable to read load average: %w", err)
+	}
+	diskTotal, diskUsed, err := readDisk()
+	if err != nil {
+		return node.Node{}, fmt.Errorf("unable to read disk stats: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Stats.Cores = cores
+	w.Stats.CPUUsage = cpuUsage(w.prevCPU, cpu)
+	w.Stats.Load1, w.Stats.Load5, w.Stats.Load15 = load1, load5, load15
+	w.Stats.Memory, w.Stats.MemoryAllocated = memTotal, memUsed
+	w.Stats.Disk, w.Stats.DiskAllocated = diskTotal, diskUsed
+	w.Stats.TaskCount = w.TaskCount
+	w.prevCPU = cpu
+	return w.Stats, nil
+}
+
+// Run is the control loop of the worker, on every tick it drains the queue,
+// checks the containers of running tasks and runs the due health checks
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for w.RunTask(ctx) {
+		}
+		w.UpdateTasks(ctx)
+		w.DoHealthChecks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunTask takes one task from the queue and starts or stops it,
+// it reports false when the queue was empty
+func (w *Worker) RunTask(ctx context.Context) bool {
+	w.mu.Lock()
+	if w.Queue.Len() == 0 {
+		w.mu.Unlock()
+		return false
+	}
+	t := w.Queue.Dequeue().(task.Task)
+	w.mu.Unlock()
+
+	var err error
+	switch t.State {
+	case task.Pending, task.Scheduled:
+		err = w.StartTask(ctx, t)
+	case task.Completed:
+		err = w.StopTask(ctx, t)
+	default:
+		err = fmt.Errorf("unexpected state %s", t.State)
+	}
+	if err != nil {
+		log.Printf("worker %s: task %s: %v", w.Name, t.ID, err)
+	}
+	return true
 }
 
-func (w *Worker) CollectStats() {
-	fmt.Println("I will collect stats")
+func (w *Worker) StartTask(ctx context.Context, t task.Task) error {
+	t.StartTime = time.Now().UTC()
+	t.FinishTime = time.Time{}
+	id, err := w.Runtime.Run(ctx, t)
+	t.ContainerID = id
+	if err != nil {
+		t.State = task.Failed
+		t.FinishTime = time.Now().UTC()
+		if perr := w.Db.Put(t.ID.String(), &t); perr != nil {
+			return perr
+		}
+		return err
+	}
 
+	t.State = task.Running
+	w.resetHealth(t.ID)
+	log.Printf("worker %s: started task %s in container %s", w.Name, t.ID, id)
+	return w.Db.Put(t.ID.String(), &t)
 }
 
-func (w *Worker) RunTask() {
-	fmt.Println("I will start or stop a task")
+func (w *Worker) StopTask(ctx context.Context, t task.Task) error {
+	// the stop may have been queued before the task started, the container
+	// and the rest of the current state are only known from the store
+	if persisted, err := w.Db.Get(t.ID.String()); err == nil {
+		t = *persisted
+	}
+	if t.ContainerID != "" {
+		if err := w.Runtime.Stop(ctx, t.ContainerID); err != nil {
+			return err
+		}
+	}
+	t.State = task.Completed
+	t.FinishTime = time.Now().UTC()
+	w.resetHealth(t.ID)
+	log.Printf("worker %s: stopped task %s", w.Name, t.ID)
+	return w.Db.Put(t.ID.String(), &t)
 }
 
-func (w *Worker) StartTask() {
-	fmt.Println("I will start the task")
+// UpdateTasks inspects the containers of running tasks and records the ones
+// that exited, restarting them when the RestartPolicy asks for it
+func (w *Worker) UpdateTasks(ctx context.Context) {
+	tasks, err := w.Db.List()
+	if err != nil {
+		log.Printf("worker %s: unable to list tasks: %v", w.Name, err)
+		return
+	}
+
+	running := 0
+	for _, t := range tasks {
+		if t.State != task.Running {
+			continue
+		}
+		status, err := w.Runtime.Inspect(ctx, t.ContainerID)
+		if err != nil {
+			log.Printf("worker %s: task %s: %v", w.Name, t.ID, err)
+			w.finishTask(ctx, *t, true)
+			continue
+		}
+		if !status.Running {
+			w.finishTask(ctx, *t, status.ExitCode != 0)
+			continue
+		}
+		running++
+	}
+
+	w.mu.Lock()
+	w.TaskCount = running
+	w.mu.Unlock()
+}
+
+// finishTask records a task whose container exited or failed its health
+// checks and restarts it if needed
+func (w *Worker) finishTask(ctx context.Context, t task.Task, failed bool) {
+	if t.ContainerID != "" {
+		// stops the container if it is still running, after failed health
+		// checks, and removes it
+		_ = w.Runtime.Stop(ctx, t.ContainerID)
+	}
+	t.FinishTime = time.Now().UTC()
+	t.State = task.Completed
+	if failed {
+		t.State = task.Failed
+	}
+
+	if t.ShouldRestart(failed) {
+		t.RestartCount++
+		log.Printf("worker %s: restarting task %s (%d/%d)", w.Name, t.ID, t.RestartCount, task.MaxRestarts)
+		if err := w.StartTask(ctx, t); err != nil {
+			log.Printf("worker %s: task %s: %v", w.Name, t.ID, err)
+		}
+		return
+	}
+
+	w.resetHealth(t.ID)
+	if err := w.Db.Put(t.ID.String(), &t); err != nil {
+		log.Printf("worker %s: task %s: %v", w.Name, t.ID, err)
+	}
+}
+
+// DoHealthChecks probes the running tasks whose interval elapsed. After
+// Retries consecutive failures the task is treated as failed
+func (w *Worker) DoHealthChecks(ctx context.Context) {
+	tasks, err := w.Db.List()
+	if err != nil {
+		log.Printf("worker %s: unable to list tasks: %v", w.Name, err)
+		return
+	}
+
+	now := time.Now()
+	for _, t := range tasks {
+		if t.State != task.Running || t.HealthCheck == nil {
+			continue
+		}
+		hc := t.HealthCheck
+		w.mu.Lock()
+		last := w.lastCheck[t.ID]
+		w.mu.Unlock()
+		if now.Sub(last) < time.Duration(hc.Interval)*time.Second {
+			continue
+		}
+
+		err := w.healthCheck(ctx, *t)
+		w.mu.Lock()
+		w.lastCheck[t.ID] = now
+		if err == nil {
+			w.healthFailures[t.ID] = 0
+			w.mu.Unlock()
+			continue
+		}
+		w.healthFailures[t.ID]++
+		failures := w.healthFailures[t.ID]
+		w.mu.Unlock()
+
+		retries := hc.Retries
+		if retries <= 0 {
+			retries = defaultHealthRetries
+		}
+		log.Printf("worker %s: health check of task %s failed (%d/%d): %v", w.Name, t.ID, failures, retries, err)
+		if failures >= retries {
+			w.finishTask(ctx, *t, true)
+		}
+	}
+}
+
+func (w *Worker) healthCheck(ctx context.Context, t task.Task) error {
+	hc := t.HealthCheck
+	if len(hc.Command) > 0 {
+		code, err := w.Runtime.Exec(ctx, t.ContainerID, hc.Command)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return fmt.Errorf("command %v exited with %d", hc.Command, code)
+		}
+		return nil
+	}
+
+	port, ok := t.HostPort()
+	if !ok {
+		return fmt.Errorf("no host port bound for http health check")
+	}
+	url := fmt.Sprintf("http://localhost:%s%s", port, hc.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return nil
 }
 
-func (w *Worker) StopTask() {
-	fmt.Println("I will stop the task")
+func (w *Worker) resetHealth(id uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.healthFailures, id)
+	delete(w.lastCheck, id)
 }
@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/dkr290/peridot-app/grpc-docker-registry/config"
 	"github.com/dkr290/peridot-app/grpc-docker-registry/internal/storage"
+	"github.com/dkr290/peridot-app/grpc-docker-registry/internal/upstream"
 	pb "github.com/dkr290/peridot-app/grpc-docker-registry/proto/gen"
 	"github.com/dkr290/peridot-app/grpc-docker-registry/utils"
 	"google.golang.org/grpc/codes"
@@ -238,4 +241,114 @@ func (s *ImageService) DeleteTag(
 	return &pb.DeleteTagResponse{Message: "Tag deleted successfully"}, nil
 }
 
+// ==================== Import Operations ====================
 
+func (s *ImageService) ImportImage(
+	ctx context.Context,
+	req *pb.ImportImageRequest,
+) (*pb.ImportImageResponse, error) {
+	imageRef := req.GetImageRef()
+	if imageRef == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Image reference is required")
+	}
+	osName, arch := req.GetOs(), req.GetArchitecture()
+	if osName == "" {
+		osName = "linux"
+	}
+	if arch == "" {
+		arch = "amd64"
+	}
+
+	reg, repo, reference, err := upstream.NewFromRef(imageRef, http.DefaultClient)
+	if err != nil {
+		return nil, status.Errorf(
+			codes.InvalidArgument,
+			"Invalid image reference %s: %v",
+			imageRef,
+			err,
+		)
+	}
+
+	manifest, _, mediaType, err := reg.GetManifest(repo, reference)
+	if err != nil {
+		s.Log.Error(fmt.Sprintf("Failed to get manifest of %s: %v", imageRef, err))
+		return nil, status.Errorf(
+			codes.Unavailable,
+			"Failed to get manifest of %s: %v",
+			imageRef,
+			err,
+		)
+	}
+	// If manifest list, resolve to the requested platform manifest
+	if mediaType == "application/vnd.docker.distribution.manifest.list.v2+json" ||
+		mediaType == "application/vnd.oci.image.index.v1+json" {
+		digest, err := upstream.ResolvePlatformDigest(manifest, osName, arch)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound, "%s: %v", imageRef, err)
+		}
+		if manifest, _, _, err = reg.GetManifest(repo, digest); err != nil {
+			return nil, status.Errorf(
+				codes.Unavailable,
+				"Failed to get %s/%s manifest of %s: %v",
+				osName,
+				arch,
+				imageRef,
+				err,
+			)
+		}
+	}
+
+	var m struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to parse manifest of %s: %v", imageRef, err)
+	}
+
+	digests := []string{m.Config.Digest}
+	for _, layer := range m.Layers {
+		digests = append(digests, layer.Digest)
+	}
+	for _, digest := range digests {
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+		if s.BlobStore.Exists(digest) {
+			continue
+		}
+		data, err := reg.GetBlob(repo, digest)
+		if err != nil {
+			s.Log.Error(fmt.Sprintf("Failed to download blob %s: %v", digest, err))
+			return nil, status.Errorf(codes.Unavailable, "Failed to download blob %s: %v", digest, err)
+		}
+		if err := s.BlobStore.StoreBlob(digest, data); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to store blob: %v", err)
+		}
+	}
+
+	manifestDigest := storage.ComputeDigest(manifest)
+	if err := s.ManifestStore.StoreManifest(repo, reference, manifestDigest, manifest); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to store manifest: %v", err)
+	}
+
+	s.Log.Info(
+		fmt.Sprintf(
+			"Image imported: %s as %s:%s (manifest: %s, layers: %d)",
+			imageRef,
+			repo,
+			reference,
+			manifestDigest,
+			len(m.Layers),
+		),
+	)
+	return &pb.ImportImageResponse{
+		Repository:     repo,
+		Tag:            reference,
+		ManifestDigest: manifestDigest,
+	}, nil
+}
@@ -1075,6 +1075,126 @@ func (x *DeleteTagResponse) GetMessage() string {
 	return ""
 }
 
+type ImportImageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ImageRef      string                 `protobuf:"bytes,1,opt,name=image_ref,json=imageRef,proto3" json:"image_ref,omitempty"` // e.g. "alpine:3.18" or "quay.io/prometheus/prometheus"
+	Os            string                 `protobuf:"bytes,2,opt,name=os,proto3" json:"os,omitempty"`                             // optional, platform picked from a multi-platform index, default linux
+	Architecture  string                 `protobuf:"bytes,3,opt,name=architecture,proto3" json:"architecture,omitempty"`         // optional, default amd64
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportImageRequest) Reset() {
+	*x = ImportImageRequest{}
+	mi := &file_registry_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportImageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportImageRequest) ProtoMessage() {}
+
+func (x *ImportImageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_registry_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportImageRequest.ProtoReflect.Descriptor instead.
+func (*ImportImageRequest) Descriptor() ([]byte, []int) {
+	return file_registry_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ImportImageRequest) GetImageRef() string {
+	if x != nil {
+		return x.ImageRef
+	}
+	return ""
+}
+
+func (x *ImportImageRequest) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *ImportImageRequest) GetArchitecture() string {
+	if x != nil {
+		return x.Architecture
+	}
+	return ""
+}
+
+type ImportImageResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Repository     string                 `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Tag            string                 `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	ManifestDigest string                 `protobuf:"bytes,3,opt,name=manifest_digest,json=manifestDigest,proto3" json:"manifest_digest,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ImportImageResponse) Reset() {
+	*x = ImportImageResponse{}
+	mi := &file_registry_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportImageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportImageResponse) ProtoMessage() {}
+
+func (x *ImportImageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_registry_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportImageResponse.ProtoReflect.Descriptor instead.
+func (*ImportImageResponse) Descriptor() ([]byte, []int) {
+	return file_registry_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ImportImageResponse) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *ImportImageResponse) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *ImportImageResponse) GetManifestDigest() string {
+	if x != nil {
+		return x.ManifestDigest
+	}
+	return ""
+}
+
 var File_registry_proto protoreflect.FileDescriptor
 
 const file_registry_proto_rawDesc = "" +
@@ -1155,7 +1275,17 @@ const file_registry_proto_rawDesc = "" +
 	"repository\x12\x10\n" +
 	"\x03tag\x18\x02 \x01(\tR\x03tag\"-\n" +
 	"\x11DeleteTagResponse\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage2\xd5\x05\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"e\n" +
+	"\x12ImportImageRequest\x12\x1b\n" +
+	"\timage_ref\x18\x01 \x01(\tR\bimageRef\x12\x0e\n" +
+	"\x02os\x18\x02 \x01(\tR\x02os\x12\"\n" +
+	"\farchitecture\x18\x03 \x01(\tR\farchitecture\"p\n" +
+	"\x13ImportImageResponse\x12\x1e\n" +
+	"\n" +
+	"repository\x18\x01 \x01(\tR\n" +
+	"repository\x12\x10\n" +
+	"\x03tag\x18\x02 \x01(\tR\x03tag\x12'\n" +
+	"\x0fmanifest_digest\x18\x03 \x01(\tR\x0emanifestDigest2\xa1\x06\n" +
 	"\x14ImageRegistryService\x12A\n" +
 	"\bPushBlob\x12\x19.registry.PushBlobRequest\x1a\x1a.registry.PushBlobResponse\x12A\n" +
 	"\bPullBlob\x12\x19.registry.PullBlobRequest\x1a\x1a.registry.PullBlobResponse\x12G\n" +
@@ -1168,7 +1298,8 @@ const file_registry_proto_rawDesc = "" +
 	"ListImages\x12\x1b.registry.ListImagesRequest\x1a\x1c.registry.ListImagesResponse\x12J\n" +
 	"\vDeleteImage\x12\x1c.registry.DeleteImageRequest\x1a\x1d.registry.DeleteImageResponse\x12A\n" +
 	"\bListTags\x12\x19.registry.ListTagsRequest\x1a\x1a.registry.ListTagsResponse\x12D\n" +
-	"\tDeleteTag\x12\x1a.registry.DeleteTagRequest\x1a\x1b.registry.DeleteTagResponseB>Z<github.com/dkr290/peridot-app/grpc-docker-registry/proto/genb\x06proto3"
+	"\tDeleteTag\x12\x1a.registry.DeleteTagRequest\x1a\x1b.registry.DeleteTagResponse\x12J\n" +
+	"\vImportImage\x12\x1c.registry.ImportImageRequest\x1a\x1d.registry.ImportImageResponseB>Z<github.com/dkr290/peridot-app/grpc-docker-registry/proto/genb\x06proto3"
 
 var (
 	file_registry_proto_rawDescOnce sync.Once
@@ -1182,7 +1313,7 @@ func file_registry_proto_rawDescGZIP() []byte {
 	return file_registry_proto_rawDescData
 }
 
-var file_registry_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_registry_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
 var file_registry_proto_goTypes = []any{
 	(*PushBlobRequest)(nil),     // 0: registry.PushBlobRequest
 	(*PushBlobResponse)(nil),    // 1: registry.PushBlobResponse
@@ -1205,6 +1336,8 @@ var file_registry_proto_goTypes = []any{
 	(*ListTagsResponse)(nil),    // 18: registry.ListTagsResponse
 	(*DeleteTagRequest)(nil),    // 19: registry.DeleteTagRequest
 	(*DeleteTagResponse)(nil),   // 20: registry.DeleteTagResponse
+	(*ImportImageRequest)(nil),  // 21: registry.ImportImageRequest
+	(*ImportImageResponse)(nil), // 22: registry.ImportImageResponse
 }
 var file_registry_proto_depIdxs = []int32{
 	13, // 0: registry.ListImagesResponse.images:type_name -> registry.ImageInfo
@@ -1218,18 +1351,20 @@ var file_registry_proto_depIdxs = []int32{
 	15, // 8: registry.ImageRegistryService.DeleteImage:input_type -> registry.DeleteImageRequest
 	17, // 9: registry.ImageRegistryService.ListTags:input_type -> registry.ListTagsRequest
 	19, // 10: registry.ImageRegistryService.DeleteTag:input_type -> registry.DeleteTagRequest
-	1,  // 11: registry.ImageRegistryService.PushBlob:output_type -> registry.PushBlobResponse
-	3,  // 12: registry.ImageRegistryService.PullBlob:output_type -> registry.PullBlobResponse
-	5,  // 13: registry.ImageRegistryService.DeleteBlob:output_type -> registry.DeleteBlobResponse
-	7,  // 14: registry.ImageRegistryService.ListBlobs:output_type -> registry.ListBlobsResponse
-	9,  // 15: registry.ImageRegistryService.PushImage:output_type -> registry.PushImageResponse
-	11, // 16: registry.ImageRegistryService.PullImage:output_type -> registry.PullImageResponse
-	14, // 17: registry.ImageRegistryService.ListImages:output_type -> registry.ListImagesResponse
-	16, // 18: registry.ImageRegistryService.DeleteImage:output_type -> registry.DeleteImageResponse
-	18, // 19: registry.ImageRegistryService.ListTags:output_type -> registry.ListTagsResponse
-	20, // 20: registry.ImageRegistryService.DeleteTag:output_type -> registry.DeleteTagResponse
-	11, // [11:21] is the sub-list for method output_type
-	1,  // [1:11] is the sub-list for method input_type
+	21, // 11: registry.ImageRegistryService.ImportImage:input_type -> registry.ImportImageRequest
+	1,  // 12: registry.ImageRegistryService.PushBlob:output_type -> registry.PushBlobResponse
+	3,  // 13: registry.ImageRegistryService.PullBlob:output_type -> registry.PullBlobResponse
+	5,  // 14: registry.ImageRegistryService.DeleteBlob:output_type -> registry.DeleteBlobResponse
+	7,  // 15: registry.ImageRegistryService.ListBlobs:output_type -> registry.ListBlobsResponse
+	9,  // 16: registry.ImageRegistryService.PushImage:output_type -> registry.PushImageResponse
+	11, // 17: registry.ImageRegistryService.PullImage:output_type -> registry.PullImageResponse
+	14, // 18: registry.ImageRegistryService.ListImages:output_type -> registry.ListImagesResponse
+	16, // 19: registry.ImageRegistryService.DeleteImage:output_type -> registry.DeleteImageResponse
+	18, // 20: registry.ImageRegistryService.ListTags:output_type -> registry.ListTagsResponse
+	20, // 21: registry.ImageRegistryService.DeleteTag:output_type -> registry.DeleteTagResponse
+	22, // 22: registry.ImageRegistryService.ImportImage:output_type -> registry.ImportImageResponse
+	12, // [12:23] is the sub-list for method output_type
+	1,  // [1:12] is the sub-list for method input_type
 	1,  // [1:1] is the sub-list for extension type_name
 	1,  // [1:1] is the sub-list for extension extendee
 	0,  // [0:1] is the sub-list for field type_name
@@ -1246,7 +1381,7 @@ func file_registry_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_registry_proto_rawDesc), len(file_registry_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   21,
+			NumMessages:   23,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -31,6 +31,7 @@ const (
 	ImageRegistryService_DeleteImage_FullMethodName = "/registry.ImageRegistryService/DeleteImage"
 	ImageRegistryService_ListTags_FullMethodName    = "/registry.ImageRegistryService/ListTags"
 	ImageRegistryService_DeleteTag_FullMethodName   = "/registry.ImageRegistryService/DeleteTag"
+	ImageRegistryService_ImportImage_FullMethodName = "/registry.ImageRegistryService/ImportImage"
 )
 
 // ImageRegistryServiceClient is the client API for ImageRegistryService service.
@@ -49,6 +50,8 @@ type ImageRegistryServiceClient interface {
 	DeleteImage(ctx context.Context, in *DeleteImageRequest, opts ...grpc.CallOption) (*DeleteImageResponse, error)
 	ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResponse, error)
 	DeleteTag(ctx context.Context, in *DeleteTagRequest, opts ...grpc.CallOption) (*DeleteTagResponse, error)
+	// Copies an image from its upstream registry (Docker Hub, quay.io, ...)
+	ImportImage(ctx context.Context, in *ImportImageRequest, opts ...grpc.CallOption) (*ImportImageResponse, error)
 }
 
 type imageRegistryServiceClient struct {
@@ -159,6 +162,16 @@ func (c *imageRegistryServiceClient) DeleteTag(ctx context.Context, in *DeleteTa
 	return out, nil
 }
 
+func (c *imageRegistryServiceClient) ImportImage(ctx context.Context, in *ImportImageRequest, opts ...grpc.CallOption) (*ImportImageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportImageResponse)
+	err := c.cc.Invoke(ctx, ImageRegistryService_ImportImage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ImageRegistryServiceServer is the server API for ImageRegistryService service.
 // All implementations must embed UnimplementedImageRegistryServiceServer
 // for forward compatibility.
@@ -175,6 +188,8 @@ type ImageRegistryServiceServer interface {
 	DeleteImage(context.Context, *DeleteImageRequest) (*DeleteImageResponse, error)
 	ListTags(context.Context, *ListTagsRequest) (*ListTagsResponse, error)
 	DeleteTag(context.Context, *DeleteTagRequest) (*DeleteTagResponse, error)
+	// Copies an image from its upstream registry (Docker Hub, quay.io, ...)
+	ImportImage(context.Context, *ImportImageRequest) (*ImportImageResponse, error)
 	mustEmbedUnimplementedImageRegistryServiceServer()
 }
 
@@ -215,6 +230,9 @@ func (UnimplementedImageRegistryServiceServer) ListTags(context.Context, *ListTa
 func (UnimplementedImageRegistryServiceServer) DeleteTag(context.Context, *DeleteTagRequest) (*DeleteTagResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method DeleteTag not implemented")
 }
+func (UnimplementedImageRegistryServiceServer) ImportImage(context.Context, *ImportImageRequest) (*ImportImageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ImportImage not implemented")
+}
 func (UnimplementedImageRegistryServiceServer) mustEmbedUnimplementedImageRegistryServiceServer() {}
 func (UnimplementedImageRegistryServiceServer) testEmbeddedByValue()                              {}
 
@@ -416,6 +434,24 @@ func _ImageRegistryService_DeleteTag_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ImageRegistryService_ImportImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageRegistryServiceServer).ImportImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImageRegistryService_ImportImage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageRegistryServiceServer).ImportImage(ctx, req.(*ImportImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ImageRegistryService_ServiceDesc is the grpc.ServiceDesc for ImageRegistryService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -463,6 +499,10 @@ var ImageRegistryService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteTag",
 			Handler:    _ImageRegistryService_DeleteTag_Handler,
 		},
+		{
+			MethodName: "ImportImage",
+			Handler:    _ImageRegistryService_ImportImage_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "registry.proto",
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Config struct {
+	// ListenAddr is where the HTTP API listens.
+	ListenAddr string
+	// RegistryAddr is the gRPC image registry.
+	RegistryAddr string
+	// TrivyPath is the trivy binary, looked up in PATH without a directory.
+	TrivyPath string
+	// TrivyCacheDir keeps the vulnerability database between scans, empty
+	// leaves it to trivy.
+	TrivyCacheDir string
+	ScanTimeout   time.Duration
+	// BuildBackend builds the layers of patched images: docker, or fake
+	// to try the API without docker.
+	BuildBackend string
+	DockerPath   string
+	// WorkDir holds images exported for scanning and patching.
+	WorkDir string
+}
+
+// LoadConfig reads environment variables with sensible defaults.
+// Designed for Docker/K8s — no .env file required.
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		ListenAddr:   ":8080",
+		RegistryAddr: "localhost:50051", // default port of grpc-docker-registry
+		TrivyPath:    "trivy",
+		ScanTimeout:  5 * time.Minute,
+		BuildBackend: "docker",
+		DockerPath:   "docker",
+		WorkDir:      filepath.Join(os.TempDir(), "peridot"),
+	}
+
+	if v := os.Getenv("LISTEN_ADDR"); v != "" {
+		cfg.ListenAddr = v
+	}
+	if v := os.Getenv("REGISTRY_ADDR"); v != "" {
+		cfg.RegistryAddr = v
+	}
+	if v := os.Getenv("TRIVY_PATH"); v != "" {
+		cfg.TrivyPath = v
+	}
+	if v := os.Getenv("TRIVY_CACHE_DIR"); v != "" {
+		cfg.TrivyCacheDir = v
+	}
+	if v := os.Getenv("SCAN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SCAN_TIMEOUT %q, use a duration like 5m", v)
+		}
+		cfg.ScanTimeout = d
+	}
+	if v := os.Getenv("BUILD_BACKEND"); v != "" {
+		if v != "docker" && v != "fake" {
+			return nil, fmt.Errorf("invalid BUILD_BACKEND %q, use docker or fake", v)
+		}
+		cfg.BuildBackend = v
+	}
+	if v := os.Getenv("DOCKER_PATH"); v != "" {
+		cfg.DockerPath = v
+	}
+	if v := os.Getenv("WORK_DIR"); v != "" {
+		cfg.WorkDir = v
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,19 @@
+module github.com/dkr290/peridot-app/peridot-backend
+
+go 1.26.2
+
+require (
+	github.com/danielgtaylor/huma/v2 v2.37.2
+	github.com/dkr290/peridot-app/grpc-docker-registry v0.0.0
+	google.golang.org/grpc v1.81.1
+)
+
+require (
+	golang.org/x/net v0.51.0 // indirect
+	golang.org/x/sys v0.42.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260226221140-a57be14db171 // indirect
+	google.golang.org/protobuf v1.36.11 // indirect
+)
+
+replace github.com/dkr290/peridot-app/grpc-docker-registry => ../grpc-docker-registry
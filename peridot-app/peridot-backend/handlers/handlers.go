@@ -2,41 +2,56 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log"
+	"os"
 
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/dkr290/peridot-app/peridot-backend/config"
 	"github.com/dkr290/peridot-app/peridot-backend/patcher"
 	"github.com/dkr290/peridot-app/peridot-backend/registry"
 	"github.com/dkr290/peridot-app/peridot-backend/scanner"
-	"github.com/humaio/huma/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ImageHandler struct {
 	scanner  *scanner.Scanner
 	patcher  *patcher.Patcher
 	registry *registry.Client
+	workDir  string
 }
 
-func NewImageHandler() *ImageHandler {
-	return &ImageHandler{
-		scanner:  scanner.NewScanner(),
-		patcher:  patcher.NewPatcher(),
-		registry: registry.NewClient("localhost:50051"),
+func NewImageHandler(cfg *config.Config) (*ImageHandler, error) {
+	reg, err := registry.NewClient(cfg.RegistryAddr)
+	if err != nil {
+		return nil, err
+	}
+	var backend patcher.BuildBackend = patcher.NewDockerBackend(cfg.DockerPath, cfg.WorkDir)
+	if cfg.BuildBackend == "fake" {
+		backend = &patcher.FakeBackend{}
 	}
+	return &ImageHandler{
+		scanner:  scanner.NewScanner(cfg),
+		patcher:  patcher.NewPatcher(reg, backend),
+		registry: reg,
+		workDir:  cfg.WorkDir,
+	}, nil
 }
 
 // AddImageHandler registers the image-related endpoints
 func (h *ImageHandler) Register(api huma.API) {
 	// POST /images - Add image from Docker Hub with scanning and patching
-	api.Post("/images", h.AddImage)
+	huma.Post(api, "/images", h.AddImage)
 
 	// GET /images - List all images
-	api.Get("/images", h.ListImages)
+	huma.Get(api, "/images", h.ListImages)
 
 	// GET /images/{repo}/{tag}/scan - Scan specific image
-	api.Get("/images/{repo}/{tag}/scan", h.ScanImage)
+	huma.Get(api, "/images/{repo}/{tag}/scan", h.ScanImage)
 
 	// POST /images/{repo}/{tag}/patch - Patch specific image
-	api.Post("/images/{repo}/{tag}/patch", h.PatchImage)
+	huma.Post(api, "/images/{repo}/{tag}/patch", h.PatchImage)
 }
 
 func (h *ImageHandler) AddImage(
@@ -45,35 +60,37 @@ func (h *ImageHandler) AddImage(
 ) (*AddImageOutput, error) {
 	log.Printf("Adding image: %s", input.Body.ImageRef)
 
-	// Step 1: Pull image from Docker Hub
+	// Step 1: Pull image from Docker Hub into the gRPC registry
 	log.Println("Pulling image from Docker Hub...")
-	// TODO: Implement pull logic using existing client
+	img, err := h.registry.Import(ctx, input.Body.ImageRef)
+	if err != nil {
+		return nil, huma.Error502BadGateway("Pull failed: " + err.Error())
+	}
 
 	// Step 2: Scan for vulnerabilities
 	log.Println("Scanning for vulnerabilities...")
-	scanResults, err := h.scanner.ScanImage(ctx, input.Body.ImageRef)
+	scanResults, err := h.scanStored(ctx, img)
 	if err != nil {
 		return nil, huma.Error500InternalServerError("Scan failed: " + err.Error())
 	}
 
-	// Step 3: Patch vulnerabilities if found
+	// Step 3: Patch vulnerabilities if found, the patched image is pushed as <tag>-patched
 	log.Println("Patching vulnerabilities...")
-	patchedImage, err := h.patcher.PatchImage(ctx, input.Body.ImageRef, scanResults)
-	if err != nil {
-		return nil, huma.Error500InternalServerError("Patching failed: " + err.Error())
+	out := &AddImageOutput{}
+	out.Body.Image = img.Ref()
+	out.Body.Vulnerabilities = toVulnerabilities(scanResults.Vulnerabilities)
+	result, err := h.patcher.PatchImage(ctx, img.Ref(), scanResults)
+	switch {
+	case errors.Is(err, patcher.ErrNothingToPatch):
+		out.Body.Message = "Image added and scanned, nothing to patch"
+	case err != nil:
+		return nil, patchError(err)
+	default:
+		out.Body.Message = "Image added, scanned, patched, and stored successfully"
+		out.Body.PatchedImage = result.Image.Ref()
+		out.Body.Updates = result.Updates
 	}
-
-	// Step 4: Push patched image to gRPC registry
-	log.Println("Pushing patched image to registry...")
-	err = h.registry.PushImage(ctx, patchedImage)
-	if err != nil {
-		return nil, huma.Error500InternalServerError("Registry push failed: " + err.Error())
-	}
-
-	return &AddImageOutput{
-		Message:         "Image added, scanned, patched, and stored successfully",
-		Vulnerabilities: scanResults.Vulnerabilities,
-	}, nil
+	return out, nil
 }
 
 func (h *ImageHandler) ListImages(
@@ -84,43 +101,116 @@ func (h *ImageHandler) ListImages(
 	if err != nil {
 		return nil, huma.Error500InternalServerError("Failed to list images: " + err.Error())
 	}
-	return &ListImagesOutput{Images: images}, nil
+	out := &ListImagesOutput{}
+	out.Body.Images = make([]ImageInfo, 0, len(images))
+	for _, img := range images {
+		out.Body.Images = append(out.Body.Images, ImageInfo{Repository: img.Repository, Tag: img.Tag, Digest: img.Digest})
+	}
+	return out, nil
 }
 
 func (h *ImageHandler) ScanImage(
 	ctx context.Context,
 	input *ScanImageInput,
 ) (*ScanImageOutput, error) {
-	results, err := h.scanner.ScanImage(ctx, input.Repo+":"+input.Tag)
+	img, err := h.pullImage(ctx, input.Repo, input.Tag)
+	if err != nil {
+		return nil, err
+	}
+	results, err := h.scanStored(ctx, img)
 	if err != nil {
 		return nil, huma.Error500InternalServerError("Scan failed: " + err.Error())
 	}
-	return &ScanImageOutput{Results: results}, nil
+	out := &ScanImageOutput{}
+	out.Body.Vulnerabilities = toVulnerabilities(results.Vulnerabilities)
+	out.Body.Score = results.Score()
+	return out, nil
 }
 
 func (h *ImageHandler) PatchImage(
 	ctx context.Context,
 	input *PatchImageInput,
 ) (*PatchImageOutput, error) {
+	img, err := h.pullImage(ctx, input.Repo, input.Tag)
+	if err != nil {
+		return nil, err
+	}
+
 	// Scan first
-	scanResults, err := h.scanner.ScanImage(ctx, input.Repo+":"+input.Tag)
+	scanResults, err := h.scanStored(ctx, img)
 	if err != nil {
 		return nil, huma.Error500InternalServerError("Scan failed: " + err.Error())
 	}
 
-	// Patch
-	patchedImage, err := h.patcher.PatchImage(ctx, input.Repo+":"+input.Tag, scanResults)
+	// Patch and push to registry
+	out := &PatchImageOutput{}
+	result, err := h.patcher.PatchImage(ctx, img.Ref(), scanResults)
+	switch {
+	case errors.Is(err, patcher.ErrNothingToPatch):
+		out.Body.Message = "Nothing to patch, no OS package vulnerability has a fix"
+	case err != nil:
+		return nil, patchError(err)
+	default:
+		out.Body.Message = "Image patched and stored successfully"
+		out.Body.PatchedImage = result.Image.Ref()
+		out.Body.Updates = result.Updates
+	}
+	return out, nil
+}
+
+// pullImage returns a stored image, repo is taken like a Docker Hub name:
+// alpine is library/alpine.
+func (h *ImageHandler) pullImage(ctx context.Context, repo, tag string) (*registry.Image, error) {
+	repo, tag = registry.ParseRef(repo + ":" + tag)
+	img, err := h.registry.PullImage(ctx, repo, tag)
+	if status.Code(err) == codes.NotFound {
+		return nil, huma.Error404NotFound("Image not found: " + repo + ":" + tag)
+	}
 	if err != nil {
-		return nil, huma.Error500InternalServerError("Patching failed: " + err.Error())
+		return nil, huma.Error500InternalServerError("Pull failed: " + err.Error())
 	}
+	return img, nil
+}
 
-	// Push to registry
-	err = h.registry.PushImage(ctx, patchedImage)
+// scanStored scans an image of the registry, exported to a file for trivy.
+func (h *ImageHandler) scanStored(ctx context.Context, img *registry.Image) (*scanner.Report, error) {
+	if err := os.MkdirAll(h.workDir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(h.workDir, "scan-*.tar")
 	if err != nil {
-		return nil, huma.Error500InternalServerError("Registry push failed: " + err.Error())
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	err = h.registry.Export(ctx, img, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
+	if err != nil {
+		return nil, err
+	}
+	return h.scanner.ScanArchive(ctx, f.Name(), img.Ref())
+}
 
-	return &PatchImageOutput{
-		Message: "Image patched and stored successfully",
-	}, nil
+func patchError(err error) error {
+	if errors.Is(err, patcher.ErrUnsupportedOS) {
+		return huma.Error422UnprocessableEntity("Patching failed: " + err.Error())
+	}
+	return huma.Error500InternalServerError("Patching failed: " + err.Error())
+}
+
+func toVulnerabilities(vulns []scanner.Vulnerability) []Vulnerability {
+	out := make([]Vulnerability, 0, len(vulns))
+	for _, v := range vulns {
+		out = append(out, Vulnerability{
+			ID:               v.ID,
+			Title:            v.Title,
+			Severity:         v.Severity,
+			Description:      v.Description,
+			Package:          v.PkgName,
+			InstalledVersion: v.InstalledVersion,
+			FixedVersion:     v.FixedVersion,
+		})
+	}
+	return out
 }
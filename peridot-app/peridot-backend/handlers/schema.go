@@ -1,8 +1,6 @@
 package handlers
 
-import (
-	"github.com/humaio/huma/v4"
-)
+import "github.com/dkr290/peridot-app/peridot-backend/patcher"
 
 // AddImageInput - Input for adding a new image
 type AddImageInput struct {
@@ -13,8 +11,11 @@ type AddImageInput struct {
 
 type AddImageOutput struct {
 	Body struct {
-		Message         string   `json:"message"`
-		Vulnerabilities []string `json:"vulnerabilities"`
+		Message         string                  `json:"message"`
+		Image           string                  `json:"image" doc:"Repository and tag the image is stored as"`
+		PatchedImage    string                  `json:"patched_image,omitempty" doc:"The patched image, when there was something to patch"`
+		Vulnerabilities []Vulnerability         `json:"vulnerabilities"`
+		Updates         []patcher.PackageUpdate `json:"updates,omitempty" doc:"Packages upgraded in the patched image"`
 	}
 }
 
@@ -35,32 +36,36 @@ type ImageInfo struct {
 }
 
 type ScanImageInput struct {
-	Repo string `param:"repo" example:"ubuntu"`
-	Tag  string `param:"tag" example:"22.04"`
+	Repo string `path:"repo" example:"ubuntu"`
+	Tag  string `path:"tag" example:"22.04"`
 }
 
 type ScanImageOutput struct {
 	Body struct {
 		Vulnerabilities []Vulnerability `json:"vulnerabilities"`
-		Score           int             `json:"score"`
+		Score           int             `json:"score" doc:"Severity weighted risk, 10 per critical down to 1 per low"`
 	}
 }
 
 type Vulnerability struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Severity    string `json:"severity"`
-	Description string `json:"description"`
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	Severity         string `json:"severity"`
+	Description      string `json:"description"`
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installed_version"`
+	FixedVersion     string `json:"fixed_version,omitempty" doc:"Empty while no fix is released"`
 }
 
 type PatchImageInput struct {
-	Repo string `param:"repo" example:"ubuntu"`
-	Tag  string `param:"tag" example:"22.04"`
+	Repo string `path:"repo" example:"ubuntu"`
+	Tag  string `path:"tag" example:"22.04"`
 }
 
 type PatchImageOutput struct {
 	Body struct {
-		Message string `json:"message"`
+		Message      string                  `json:"message"`
+		PatchedImage string                  `json:"patched_image,omitempty"`
+		Updates      []patcher.PackageUpdate `json:"updates,omitempty"`
 	}
 }
-
@@ -1,31 +1,33 @@
 package main
+
 import (
 	"log"
 	"net/http"
 
-	"github.com/humaio/huma/v4"
-	"github.com/humaio/huma/v4/adapters/humago"
-	"github.com/dkr290/peridot-app/peridot-backend/api"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humago"
+	"github.com/dkr290/peridot-app/peridot-backend/config"
+	"github.com/dkr290/peridot-app/peridot-backend/handlers"
 )
 
 func main() {
-	// Create HUMA API with default configuration
-	config := huma.DefaultConfig("Peridot API", "1.0.0")
-	
-	// Register custom operation processor if needed
-	config.OperationProcessors = append(config.OperationProcessors, func(operation *huma.Operation) error {
-		return nil
-	})
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
 
-	// Create router and adapter
+	// Create router and HUMA API with default configuration
 	router := http.NewServeMux()
-	api := humago.New(router, config)
+	api := humago.New(router, huma.DefaultConfig("Peridot API", "1.0.0"))
 
 	// Register routes
-	api.Register(api.NewImageHandler())
+	images, err := handlers.NewImageHandler(cfg)
+	if err != nil {
+		log.Fatalf("failed to create image handler: %v", err)
+	}
+	images.Register(api)
 
 	// Start server
-	log.Println("🚀 Starting Peridot API on :8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	log.Printf("🚀 Starting Peridot API on %s (registry %s)", cfg.ListenAddr, cfg.RegistryAddr)
+	log.Fatal(http.ListenAndServe(cfg.ListenAddr, router))
 }
-
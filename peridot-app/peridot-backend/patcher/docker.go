@@ -0,0 +1,162 @@
+package patcher
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DockerBackend builds layers with the docker CLI: it loads the image,
+// runs the upgrade in a container as root, commits the container and takes
+// the layer the commit added.
+type DockerBackend struct {
+	// Docker is the docker binary.
+	Docker string
+	// WorkDir holds the image archives while a build runs.
+	WorkDir string
+}
+
+func NewDockerBackend(docker, workDir string) *DockerBackend {
+	return &DockerBackend{Docker: docker, WorkDir: workDir}
+}
+
+func (b *DockerBackend) BuildLayer(ctx context.Context, req BuildRequest) (*Layer, error) {
+	if err := os.MkdirAll(b.WorkDir, 0o755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(b.WorkDir, "build-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	id := make([]byte, 6)
+	rand.Read(id)
+	name := "peridot-build-" + hex.EncodeToString(id)
+	committed := "peridot-build:" + hex.EncodeToString(id)
+	// the image is loaded under a tag of its own, removing it afterwards
+	// leaves the tags the daemon already had alone
+	source := committed + "-source"
+
+	input := filepath.Join(dir, "image.tar")
+	if err := writeFile(input, func(w io.Writer) error {
+		return req.Archive(w, source)
+	}); err != nil {
+		return nil, fmt.Errorf("export image: %w", err)
+	}
+	if _, err := b.run(ctx, "load", "-q", "-i", input); err != nil {
+		return nil, err
+	}
+	defer b.run(context.WithoutCancel(ctx), "image", "rm", source)
+
+	if _, err := b.run(ctx, "run", "--name", name, "--user", "0:0", "--entrypoint", "/bin/sh",
+		source, "-c", req.Plan.Script()); err != nil {
+		b.run(context.WithoutCancel(ctx), "rm", "-f", name)
+		return nil, fmt.Errorf("upgrade %s packages: %w", req.Plan.Manager, err)
+	}
+	defer b.run(context.WithoutCancel(ctx), "rm", "-f", name)
+	if _, err := b.run(ctx, "commit", name, committed); err != nil {
+		return nil, err
+	}
+	defer b.run(context.WithoutCancel(ctx), "image", "rm", "-f", committed)
+
+	output := filepath.Join(dir, "patched.tar")
+	if _, err := b.run(ctx, "save", "-o", output, committed); err != nil {
+		return nil, err
+	}
+	data, err := lastLayer(output)
+	if err != nil {
+		return nil, fmt.Errorf("read committed layer: %w", err)
+	}
+	// docker save writes uncompressed layers, older versions may not
+	if zr, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("read committed layer: %w", err)
+		}
+	}
+	return NewLayer(data)
+}
+
+func (b *DockerBackend) run(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, b.Docker, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("docker not found at %q, install it or set DOCKER_PATH", b.Docker)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+			msg = msg[i+1:]
+		}
+		return nil, fmt.Errorf("docker %s: %v: %s", args[0], err, msg)
+	}
+	return out, nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lastLayer returns the top layer of the docker archive at path.
+func lastLayer(path string) ([]byte, error) {
+	var manifest []struct {
+		Layers []string
+	}
+	if err := readTarEntry(path, "manifest.json", func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&manifest)
+	}); err != nil {
+		return nil, err
+	}
+	if len(manifest) != 1 || len(manifest[0].Layers) == 0 {
+		return nil, errors.New("archive holds no single image with layers")
+	}
+	var data []byte
+	err := readTarEntry(path, manifest[0].Layers[len(manifest[0].Layers)-1], func(r io.Reader) error {
+		var err error
+		data, err = io.ReadAll(r)
+		return err
+	})
+	return data, err
+}
+
+func readTarEntry(path, name string, read func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s not in archive", name)
+		}
+		if err != nil {
+			return err
+		}
+		if filepath.Clean(hdr.Name) == filepath.Clean(name) {
+			return read(tr)
+		}
+	}
+}
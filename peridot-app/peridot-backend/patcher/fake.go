@@ -0,0 +1,60 @@
+package patcher
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeBackend builds layers without running anything: the layer holds one
+// file, /var/lib/peridot/upgrades, listing the upgraded packages. It is
+// meant for tests and for trying the API without docker.
+type FakeBackend struct {
+	// Err, when set, fails every build.
+	Err error
+
+	mu       sync.Mutex
+	requests []BuildRequest
+}
+
+func (b *FakeBackend) BuildLayer(ctx context.Context, req BuildRequest) (*Layer, error) {
+	b.mu.Lock()
+	b.requests = append(b.requests, req)
+	b.mu.Unlock()
+	if b.Err != nil {
+		return nil, b.Err
+	}
+
+	var list bytes.Buffer
+	for _, u := range req.Plan.Updates {
+		fmt.Fprintf(&list, "%s %s -> %s\n", u.Name, u.InstalledVersion, u.FixedVersion)
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "var/lib/peridot/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "var/lib/peridot/upgrades", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(list.Len())},
+	} {
+		hdr.ModTime = time.Unix(0, 0)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tw.Write(list.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return NewLayer(buf.Bytes())
+}
+
+// Requests returns the builds asked for so far.
+func (b *FakeBackend) Requests() []BuildRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]BuildRequest(nil), b.requests...)
+}
@@ -0,0 +1,181 @@
+package patcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dkr290/peridot-app/peridot-backend/registry"
+	"github.com/dkr290/peridot-app/peridot-backend/scanner"
+)
+
+// TagSuffix is appended to the tag of a patched image.
+const TagSuffix = "-patched"
+
+// Patcher upgrades the vulnerable OS packages of images in the registry.
+// Like Copa it does not rebuild the image: the upgrade goes into one new
+// layer on top of the unchanged ones.
+type Patcher struct {
+	registry *registry.Client
+	backend  BuildBackend
+}
+
+func NewPatcher(reg *registry.Client, backend BuildBackend) *Patcher {
+	return &Patcher{registry: reg, backend: backend}
+}
+
+// PatchImage upgrades the fixable packages of report in the stored image
+// ref and pushes the result as <tag>-patched. It returns ErrNothingToPatch
+// when the report has nothing an upgrade fixes.
+func (p *Patcher) PatchImage(
+	ctx context.Context,
+	ref string,
+	report *scanner.Report,
+) (*Result, error) {
+	plan, err := NewPlan(report)
+	if err != nil {
+		return nil, err
+	}
+	repo, tag := registry.ParseRef(ref)
+	img, err := p.registry.PullImage(ctx, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	layer, err := p.backend.BuildLayer(ctx, BuildRequest{
+		Image: img,
+		Plan:  plan,
+		Archive: func(w io.Writer, repoTag string) error {
+			return p.registry.ExportAs(ctx, img, repoTag, w)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build layer for %s: %w", img.Ref(), err)
+	}
+	layerDigest, err := p.registry.PushBlob(ctx, layer.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := withLayer(img, layer, layerDigest, plan)
+	if err != nil {
+		return nil, fmt.Errorf("patch %s: %w", img.Ref(), err)
+	}
+	patched.Tag = strings.TrimSuffix(tag, TagSuffix) + TagSuffix
+	if err := p.registry.PushImage(ctx, patched); err != nil {
+		return nil, err
+	}
+	return &Result{Source: img.Ref(), Image: patched, Updates: plan.Updates, LayerDigest: layerDigest}, nil
+}
+
+// withLayer returns img with layer added on top: the config lists its diff
+// ID and a history entry, the manifest points at the new config and the
+// layer. Fields the patcher does not know are kept as they are.
+func withLayer(img *registry.Image, layer *Layer, layerDigest string, plan *Plan) (*registry.Image, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(img.Config, &config); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	var rootfs struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	}
+	if err := json.Unmarshal(config["rootfs"], &rootfs); err != nil {
+		return nil, fmt.Errorf("parse config rootfs: %w", err)
+	}
+	rootfs.DiffIDs = append(rootfs.DiffIDs, layer.DiffID)
+	var history []json.RawMessage
+	if raw, ok := config["history"]; ok {
+		if err := json.Unmarshal(raw, &history); err != nil {
+			return nil, fmt.Errorf("parse config history: %w", err)
+		}
+	}
+	entry, err := json.Marshal(map[string]string{
+		"created":    now,
+		"created_by": "/bin/sh -c " + plan.Script(),
+		"comment":    fmt.Sprintf("peridot: upgrade %d %s packages", len(plan.Updates), plan.Manager),
+	})
+	if err != nil {
+		return nil, err
+	}
+	history = append(history, entry)
+	if err := setJSON(config, "rootfs", rootfs); err != nil {
+		return nil, err
+	}
+	if err := setJSON(config, "history", history); err != nil {
+		return nil, err
+	}
+	if err := setJSON(config, "created", now); err != nil {
+		return nil, err
+	}
+	configData, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest map[string]json.RawMessage
+	if err := json.Unmarshal(img.Manifest, &manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	var m registry.Manifest
+	if err := json.Unmarshal(img.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	layerType := registry.MediaTypeOCILayer
+	if m.MediaType == registry.MediaTypeDockerManifest {
+		layerType = registry.MediaTypeDockerLayer
+	}
+	m.Config.Digest = registry.Digest(configData)
+	m.Config.Size = int64(len(configData))
+	m.Layers = append(m.Layers, registry.Descriptor{MediaType: layerType, Digest: layerDigest, Size: int64(len(layer.Data))})
+	if err := setJSON(manifest, "config", m.Config); err != nil {
+		return nil, err
+	}
+	if err := setJSON(manifest, "layers", m.Layers); err != nil {
+		return nil, err
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &registry.Image{
+		Repository:   img.Repository,
+		Tag:          img.Tag,
+		Manifest:     manifestData,
+		Config:       configData,
+		LayerDigests: append(append([]string(nil), img.LayerDigests...), layerDigest),
+	}, nil
+}
+
+func setJSON(m map[string]json.RawMessage, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m[key] = data
+	return nil
+}
+
+// NewLayer compresses the tar of a layer, for build backends.
+func NewLayer(tarData []byte) (*Layer, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(tarData); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(tarData)
+	return &Layer{Data: buf.Bytes(), DiffID: "sha256:" + hex.EncodeToString(sum[:])}, nil
+}
@@ -0,0 +1,257 @@
+package patcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	pb "github.com/dkr290/peridot-app/grpc-docker-registry/proto/gen"
+	"github.com/dkr290/peridot-app/peridot-backend/registry"
+	"github.com/dkr290/peridot-app/peridot-backend/scanner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memRegistry is an in-memory ImageRegistryService that, like the real
+// one, only returns the manifest on pull.
+type memRegistry struct {
+	pb.UnimplementedImageRegistryServiceServer
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+}
+
+func (r *memRegistry) PushBlob(_ context.Context, req *pb.PushBlobRequest) (*pb.PushBlobResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blobs[req.GetDigest()] = req.GetData()
+	return &pb.PushBlobResponse{Digest: req.GetDigest(), Size: int64(len(req.GetData()))}, nil
+}
+
+func (r *memRegistry) PullBlob(_ context.Context, req *pb.PullBlobRequest) (*pb.PullBlobResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.blobs[req.GetDigest()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Blob not found: %s", req.GetDigest())
+	}
+	return &pb.PullBlobResponse{Data: data}, nil
+}
+
+func (r *memRegistry) PushImage(_ context.Context, req *pb.PushImageRequest) (*pb.PushImageResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range req.GetLayerDigests() {
+		if _, ok := r.blobs[d]; !ok {
+			return nil, status.Errorf(codes.NotFound, "Layer not found: %s", d)
+		}
+	}
+	r.blobs[registry.Digest(req.GetConfig())] = req.GetConfig()
+	r.manifests[req.GetRepository()+":"+req.GetTag()] = req.GetManifest()
+	return &pb.PushImageResponse{ManifestDigest: registry.Digest(req.GetManifest())}, nil
+}
+
+func (r *memRegistry) PullImage(_ context.Context, req *pb.PullImageRequest) (*pb.PullImageResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.manifests[req.GetRepository()+":"+req.GetTag()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Image not found")
+	}
+	return &pb.PullImageResponse{Manifest: m}, nil
+}
+
+func newTestRegistry(t *testing.T) (*registry.Client, *memRegistry) {
+	t.Helper()
+	mem := &memRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterImageRegistryServiceServer(srv, mem)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return registry.NewClientFromConn(conn), mem
+}
+
+func alpineReport() *scanner.Report {
+	os := func(id, pkg, fixed, severity string) scanner.Vulnerability {
+		return scanner.Vulnerability{ID: id, Severity: severity, Class: scanner.ClassOSPackages, Type: "alpine",
+			PkgName: pkg, InstalledVersion: "3.1.0-r4", FixedVersion: fixed}
+	}
+	return &scanner.Report{
+		Image: "alpine:3.18",
+		OS:    scanner.OS{Family: "alpine", Name: "3.18.0"},
+		Vulnerabilities: []scanner.Vulnerability{
+			os("CVE-2023-2650", "libssl3", "3.1.1-r0", scanner.SeverityHigh),
+			os("CVE-2023-2650", "libcrypto3", "3.1.1-r0", scanner.SeverityHigh),
+			os("CVE-2023-5678", "libssl3", "3.1.4-r1", scanner.SeverityMedium),
+			os("CVE-2024-0001", "busybox", "", scanner.SeverityLow),
+			{ID: "CVE-2024-0002", Class: scanner.ClassLangPackages, Type: "gomod", PkgName: "golang.org/x/net", FixedVersion: "0.23.0"},
+		},
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	plan, err := NewPlan(alpineReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Manager != APK || !slices.Equal(plan.Packages(), []string{"libcrypto3", "libssl3"}) {
+		t.Fatalf("plan %+v", plan)
+	}
+	ssl := plan.Updates[1]
+	if ssl.FixedVersion != "3.1.4-r1" || !slices.Equal(ssl.VulnerabilityIDs, []string{"CVE-2023-2650", "CVE-2023-5678"}) {
+		t.Errorf("libssl3 update %+v", ssl)
+	}
+	if got := plan.Script(); got != "apk add --no-cache --upgrade libcrypto3 libssl3" {
+		t.Errorf("script %q", got)
+	}
+
+	tests := []struct {
+		name   string
+		report *scanner.Report
+		want   error
+	}{
+		{"no fix released", &scanner.Report{Vulnerabilities: alpineReport().Vulnerabilities[3:4]}, ErrNothingToPatch},
+		{"only language packages", &scanner.Report{Vulnerabilities: alpineReport().Vulnerabilities[4:]}, ErrNothingToPatch},
+		{"unknown distribution", &scanner.Report{OS: scanner.OS{Family: "photon"}, Vulnerabilities: alpineReport().Vulnerabilities[:1]}, ErrUnsupportedOS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPlan(tt.report); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"3.1.4-r1", "3.1.1-r0", 1},
+		{"3.1.10-r0", "3.1.9-r3", 1},
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.03", "1.2.3", 0},
+		{"1:1.0-1", "2.0-1", 1},
+		{"1.0~rc1-1", "1.0-1", -1},
+		{"2.36.1-8+deb11u2", "2.36.1-8+deb11u1", 1},
+		{"1.1.1k-7.el8_6", "1.1.1k-12.el8_9", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0.1", "1.0a", 1},
+	} {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestPatchImage(t *testing.T) {
+	ctx := context.Background()
+	reg, mem := newTestRegistry(t)
+
+	base, err := NewLayer([]byte("base layer tar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseDigest, err := reg.PushBlob(ctx, base.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := []byte(`{"architecture":"amd64","os":"linux","config":{"Cmd":["/bin/sh"]},` +
+		`"rootfs":{"type":"layers","diff_ids":["` + base.DiffID + `"]},"history":[{"created_by":"ADD rootfs"}]}`)
+	manifest, _ := json.Marshal(registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		Config:        registry.Descriptor{MediaType: registry.MediaTypeOCIConfig, Digest: registry.Digest(config), Size: int64(len(config))},
+		Layers:        []registry.Descriptor{{MediaType: registry.MediaTypeOCILayer, Digest: baseDigest, Size: int64(len(base.Data))}},
+	})
+	if err := reg.PushImage(ctx, &registry.Image{Repository: "library/alpine", Tag: "3.18", Manifest: manifest, Config: config, LayerDigests: []string{baseDigest}}); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := &FakeBackend{}
+	res, err := NewPatcher(reg, backend).PatchImage(ctx, "alpine:3.18", alpineReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Source != "library/alpine:3.18" || res.Image.Ref() != "library/alpine:3.18-patched" || len(res.Updates) != 2 {
+		t.Fatalf("result %+v", res)
+	}
+	if reqs := backend.Requests(); len(reqs) != 1 || reqs[0].Plan.Manager != APK || reqs[0].Image.Tag != "3.18" {
+		t.Fatalf("build requests %+v", reqs)
+	}
+
+	// the stored patched image has the base layer and the new one on top
+	patched, err := reg.PullImage(ctx, "library/alpine", "3.18-patched")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(patched.LayerDigests, []string{baseDigest, res.LayerDigest}) {
+		t.Errorf("layers %q", patched.LayerDigests)
+	}
+	var m registry.Manifest
+	json.Unmarshal(patched.Manifest, &m)
+	if top := m.Layers[1]; top.MediaType != registry.MediaTypeOCILayer || top.Size != int64(len(mem.blobs[res.LayerDigest])) {
+		t.Errorf("top layer %+v", top)
+	}
+	if m.Config.Digest != registry.Digest(patched.Config) || m.Config.MediaType != registry.MediaTypeOCIConfig {
+		t.Errorf("config descriptor %+v", m.Config)
+	}
+
+	var cfg struct {
+		Architecture string
+		Config       struct{ Cmd []string }
+		RootFS       struct {
+			DiffIDs []string `json:"diff_ids"`
+		} `json:"rootfs"`
+		History []struct {
+			CreatedBy string `json:"created_by"`
+		}
+	}
+	if err := json.Unmarshal(patched.Config, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Architecture != "amd64" || !slices.Equal(cfg.Config.Cmd, []string{"/bin/sh"}) {
+		t.Errorf("config fields lost: %+v", cfg)
+	}
+	if len(cfg.RootFS.DiffIDs) != 2 || cfg.RootFS.DiffIDs[0] != base.DiffID || !strings.HasPrefix(cfg.RootFS.DiffIDs[1], "sha256:") {
+		t.Errorf("diff ids %q", cfg.RootFS.DiffIDs)
+	}
+	if len(cfg.History) != 2 || !strings.Contains(cfg.History[1].CreatedBy, "apk add") {
+		t.Errorf("history %+v", cfg.History)
+	}
+
+	// patching the patched image keeps a single suffix
+	res, err = NewPatcher(reg, backend).PatchImage(ctx, "alpine:3.18-patched", alpineReport())
+	if err != nil || res.Image.Tag != "3.18-patched" {
+		t.Errorf("repatch: %v, %+v", err, res)
+	}
+
+	backend.Err = errors.New("no shell in image")
+	if _, err := NewPatcher(reg, backend).PatchImage(ctx, "alpine:3.18", alpineReport()); err == nil {
+		t.Error("expected the build error")
+	}
+	if _, err := NewPatcher(reg, backend).PatchImage(ctx, "alpine:missing", alpineReport()); status.Code(err) != codes.NotFound {
+		t.Errorf("missing image: got %v", err)
+	}
+}
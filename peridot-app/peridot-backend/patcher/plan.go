@@ -0,0 +1,187 @@
+package patcher
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dkr290/peridot-app/peridot-backend/scanner"
+)
+
+// managers maps the OS families trivy reports to their package managers.
+var managers = map[string]PackageManager{
+	"alpine":     APK,
+	"wolfi":      APK,
+	"chainguard": APK,
+	"debian":     APT,
+	"ubuntu":     APT,
+	"redhat":     RPM,
+	"centos":     RPM,
+	"rocky":      RPM,
+	"alma":       RPM,
+	"oracle":     RPM,
+	"amazon":     RPM,
+	"fedora":     RPM,
+}
+
+// packageName matches the package names the upgrade commands accept, the
+// names go into a shell command.
+var packageName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_:~@-]*$`)
+
+// NewPlan collects the OS packages of report that have a fixed version.
+func NewPlan(report *scanner.Report) (*Plan, error) {
+	plan := &Plan{OSFamily: report.OS.Family}
+	byName := map[string]int{}
+	for _, v := range report.Vulnerabilities {
+		if v.Class != scanner.ClassOSPackages || !v.Fixable() {
+			continue
+		}
+		if plan.OSFamily == "" {
+			plan.OSFamily = v.Type
+		}
+		if !packageName.MatchString(v.PkgName) {
+			return nil, fmt.Errorf("unexpected package name %q in the report", v.PkgName)
+		}
+		i, ok := byName[v.PkgName]
+		if !ok {
+			i = len(plan.Updates)
+			byName[v.PkgName] = i
+			plan.Updates = append(plan.Updates, PackageUpdate{
+				Name:             v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+			})
+		}
+		u := &plan.Updates[i]
+		// the version that fixes all of them is the highest
+		if compareVersions(v.FixedVersion, u.FixedVersion) > 0 {
+			u.FixedVersion = v.FixedVersion
+		}
+		if !slices.Contains(u.VulnerabilityIDs, v.ID) {
+			u.VulnerabilityIDs = append(u.VulnerabilityIDs, v.ID)
+		}
+	}
+	if len(plan.Updates) == 0 {
+		return nil, ErrNothingToPatch
+	}
+	manager, ok := managers[plan.OSFamily]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedOS, plan.OSFamily)
+	}
+	plan.Manager = manager
+	sort.Slice(plan.Updates, func(i, j int) bool { return plan.Updates[i].Name < plan.Updates[j].Name })
+	return plan, nil
+}
+
+// compareVersions orders package versions the way rpm, dpkg and apk
+// mostly agree on: an epoch before a colon first, then runs of digits
+// compared as numbers and runs of letters compared as text, a number
+// beating letters. A tilde sorts before everything, even the end, so
+// 1.0~rc1 is older than 1.0.
+func compareVersions(a, b string) int {
+	if c := cmp.Compare(epoch(&a), epoch(&b)); c != 0 {
+		return c
+	}
+	for {
+		a = strings.TrimLeftFunc(a, separator)
+		b = strings.TrimLeftFunc(b, separator)
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if a == "" || b == "" {
+			return cmp.Compare(len(a), len(b))
+		}
+		digits := isDigit(rune(a[0]))
+		if digits != isDigit(rune(b[0])) {
+			if digits {
+				return 1
+			}
+			return -1
+		}
+		var sa, sb string
+		sa, a = segment(a, digits)
+		sb, b = segment(b, digits)
+		if digits {
+			sa = strings.TrimLeft(sa, "0")
+			sb = strings.TrimLeft(sb, "0")
+			if c := cmp.Compare(len(sa), len(sb)); c != 0 {
+				return c
+			}
+		}
+		if c := strings.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+}
+
+// epoch cuts the epoch off *v and returns it, 0 when there is none.
+func epoch(v *string) int {
+	head, tail, ok := strings.Cut(*v, ":")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(head)
+	if err != nil {
+		return 0
+	}
+	*v = tail
+	return n
+}
+
+// segment splits the leading run of digits, or of letters, off v.
+func segment(v string, digits bool) (string, string) {
+	i := strings.IndexFunc(v, func(r rune) bool {
+		return isDigit(r) != digits || separator(r) || r == '~'
+	})
+	if i < 0 {
+		return v, ""
+	}
+	return v[:i], v[i:]
+}
+
+func separator(r rune) bool {
+	return r != '~' && !isDigit(r) && !unicode.IsLetter(r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// Packages returns the names of the packages to upgrade.
+func (p *Plan) Packages() []string {
+	names := make([]string, len(p.Updates))
+	for i, u := range p.Updates {
+		names[i] = u.Name
+	}
+	return names
+}
+
+// Script is the shell command that upgrades the packages and cleans up
+// the package manager caches afterwards, to keep the layer small.
+func (p *Plan) Script() string {
+	pkgs := strings.Join(p.Packages(), " ")
+	switch p.Manager {
+	case APK:
+		return "apk add --no-cache --upgrade " + pkgs
+	case APT:
+		return "apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends --only-upgrade " +
+			pkgs + " && apt-get clean && rm -rf /var/lib/apt/lists/*"
+	case RPM:
+		return "if command -v dnf >/dev/null; then dnf upgrade -y " + pkgs + " && dnf clean all; " +
+			"elif command -v microdnf >/dev/null; then microdnf upgrade -y " + pkgs + " && microdnf clean all; " +
+			"else yum update -y " + pkgs + " && yum clean all; fi"
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package patcher
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/dkr290/peridot-app/peridot-backend/registry"
+)
+
+var (
+	// ErrNothingToPatch is returned for a report without OS package
+	// vulnerabilities that an upgrade fixes.
+	ErrNothingToPatch = errors.New("no fixable OS package vulnerabilities")
+	// ErrUnsupportedOS is returned for images whose package manager the
+	// patcher cannot drive.
+	ErrUnsupportedOS = errors.New("unsupported OS")
+)
+
+// PackageManager installs the upgrades of a plan.
+type PackageManager string
+
+const (
+	APK PackageManager = "apk"
+	APT PackageManager = "apt"
+	RPM PackageManager = "rpm"
+)
+
+// PackageUpdate is a package to upgrade and the vulnerabilities it fixes.
+type PackageUpdate struct {
+	Name             string `json:"name"`
+	InstalledVersion string `json:"installed_version"`
+	// FixedVersion is the highest of the fixed versions, the one that fixes
+	// all of VulnerabilityIDs.
+	FixedVersion     string   `json:"fixed_version"`
+	VulnerabilityIDs []string `json:"vulnerability_ids"`
+}
+
+// Plan is what patching an image does.
+type Plan struct {
+	OSFamily string
+	Manager  PackageManager
+	// Updates are sorted by name. The upgrade installs the newest version
+	// the distribution has, which includes the fixed ones.
+	Updates []PackageUpdate
+}
+
+// Layer is a built image layer.
+type Layer struct {
+	// Data is the gzip compressed tar of the layer.
+	Data []byte
+	// DiffID is the digest of the uncompressed tar, which the image config lists.
+	DiffID string
+}
+
+// BuildRequest is the input of a build backend.
+type BuildRequest struct {
+	Image *registry.Image
+	Plan  *Plan
+	// Archive writes Image as a docker archive tagged repoTag, for backends
+	// that load it into a container runtime.
+	Archive func(w io.Writer, repoTag string) error
+}
+
+// BuildBackend produces the layer that upgrades the packages of a plan
+// when it is put on top of the image.
+type BuildBackend interface {
+	BuildLayer(ctx context.Context, req BuildRequest) (*Layer, error)
+}
+
+// Result is a patched image.
+type Result struct {
+	// Source is the reference of the image that was patched.
+	Source string
+	// Image is the patched image as pushed, tagged <tag>-patched.
+	Image   *registry.Image
+	Updates []PackageUpdate
+	// LayerDigest is the digest of the added layer.
+	LayerDigest string
+}
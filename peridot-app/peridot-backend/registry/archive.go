@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Export writes img as a docker archive, the format of docker save, which
+// docker load and trivy --input read. The layers are fetched from the
+// registry one at a time.
+func (c *Client) Export(ctx context.Context, img *Image, w io.Writer) error {
+	return c.ExportAs(ctx, img, img.Ref(), w)
+}
+
+// ExportAs is Export with the archive tagging the image as repoTag, e.g. a
+// throwaway tag that docker load cannot clash with.
+func (c *Client) ExportAs(ctx context.Context, img *Image, repoTag string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	configName := strings.TrimPrefix(Digest(img.Config), "sha256:") + ".json"
+	if err := writeTarFile(tw, configName, img.Config); err != nil {
+		return fmt.Errorf("export %s: %w", img.Ref(), err)
+	}
+
+	entry := struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}{Config: configName, RepoTags: []string{repoTag}}
+	for _, digest := range img.LayerDigests {
+		data, err := c.PullBlob(ctx, digest)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", img.Ref(), err)
+		}
+		// compressed layers are fine, readers detect gzip
+		name := strings.TrimPrefix(digest, "sha256:") + ".tar"
+		if err := writeTarFile(tw, name, data); err != nil {
+			return fmt.Errorf("export %s: %w", img.Ref(), err)
+		}
+		entry.Layers = append(entry.Layers, name)
+	}
+
+	manifest, err := json.Marshal([]any{entry})
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "manifest.json", manifest); err != nil {
+		return fmt.Errorf("export %s: %w", img.Ref(), err)
+	}
+	return tw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Unix(0, 0)}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,203 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	pb "github.com/dkr290/peridot-app/grpc-docker-registry/proto/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Media types of the manifests and blobs the registry stores.
+const (
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIConfig      = "application/vnd.oci.image.config.v1+json"
+	MediaTypeOCILayer       = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerLayer    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// Manifest is an image manifest, in the OCI or the Docker v2 format which
+// share these fields.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// Descriptor points at a blob.
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Image is an image as the registry stores it: its manifest, its config
+// and the blobs of its layers, which are pushed separately.
+type Image struct {
+	Repository string
+	Tag        string
+	Manifest   []byte
+	Config     []byte
+	// LayerDigests are in the order of the manifest, the base layer first.
+	LayerDigests []string
+	// ManifestDigest is set by the registry on pull and push.
+	ManifestDigest string
+}
+
+// Ref is repository:tag.
+func (i *Image) Ref() string {
+	return i.Repository + ":" + i.Tag
+}
+
+type ImageInfo struct {
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// Client talks to the ImageRegistryService of grpc-docker-registry.
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  pb.ImageRegistryServiceClient
+}
+
+// NewClient returns a client for the registry at addr. The connection is
+// made on the first call.
+func NewClient(addr string) (*Client, error) {
+	// layers are sent whole, the registry server accepts up to 2GB
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(math.MaxInt32),
+			grpc.MaxCallSendMsgSize(math.MaxInt32),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("registry client for %s: %w", addr, err)
+	}
+	return &Client{conn: conn, rpc: pb.NewImageRegistryServiceClient(conn)}, nil
+}
+
+// NewClientFromConn returns a client using cc, e.g. an in-process connection in tests.
+func NewClientFromConn(cc grpc.ClientConnInterface) *Client {
+	return &Client{rpc: pb.NewImageRegistryServiceClient(cc)}
+}
+
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Digest is the sha256 digest of data in the registry's notation.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// PushBlob stores data and returns its digest.
+func (c *Client) PushBlob(ctx context.Context, data []byte) (string, error) {
+	digest := Digest(data)
+	if _, err := c.rpc.PushBlob(ctx, &pb.PushBlobRequest{Data: data, Digest: digest}); err != nil {
+		return "", fmt.Errorf("push blob %s: %w", digest, err)
+	}
+	return digest, nil
+}
+
+func (c *Client) PullBlob(ctx context.Context, digest string) ([]byte, error) {
+	resp, err := c.rpc.PullBlob(ctx, &pb.PullBlobRequest{Digest: digest})
+	if err != nil {
+		return nil, fmt.Errorf("pull blob %s: %w", digest, err)
+	}
+	return resp.GetData(), nil
+}
+
+// PushImage stores the manifest and config of img under its repository and
+// tag and sets its ManifestDigest. Its layers have to be pushed before.
+func (c *Client) PushImage(ctx context.Context, img *Image) error {
+	resp, err := c.rpc.PushImage(ctx, &pb.PushImageRequest{
+		Repository:   img.Repository,
+		Tag:          img.Tag,
+		Manifest:     img.Manifest,
+		Config:       img.Config,
+		LayerDigests: img.LayerDigests,
+	})
+	if err != nil {
+		return fmt.Errorf("push image %s: %w", img.Ref(), err)
+	}
+	img.ManifestDigest = resp.GetManifestDigest()
+	return nil
+}
+
+// PullImage returns the image stored as repo:tag. The registry only keeps
+// the manifest with the tag, the config and layer digests are read from it.
+func (c *Client) PullImage(ctx context.Context, repo, tag string) (*Image, error) {
+	resp, err := c.rpc.PullImage(ctx, &pb.PullImageRequest{Repository: repo, Tag: tag})
+	if err != nil {
+		return nil, fmt.Errorf("pull image %s:%s: %w", repo, tag, err)
+	}
+	img := &Image{
+		Repository:     repo,
+		Tag:            tag,
+		Manifest:       resp.GetManifest(),
+		Config:         resp.GetConfig(),
+		LayerDigests:   resp.GetLayerDigests(),
+		ManifestDigest: Digest(resp.GetManifest()),
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(img.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("pull image %s:%s: parse manifest: %w", repo, tag, err)
+	}
+	if len(img.LayerDigests) == 0 {
+		for _, l := range m.Layers {
+			img.LayerDigests = append(img.LayerDigests, l.Digest)
+		}
+	}
+	if len(img.Config) == 0 && m.Config.Digest != "" {
+		if img.Config, err = c.PullBlob(ctx, m.Config.Digest); err != nil {
+			return nil, fmt.Errorf("pull image %s:%s: %w", repo, tag, err)
+		}
+	}
+	return img, nil
+}
+
+// ListImages returns the tagged images of repo.
+func (c *Client) ListImages(ctx context.Context, repo string) ([]ImageInfo, error) {
+	resp, err := c.rpc.ListImages(ctx, &pb.ListImagesRequest{Repository: repo})
+	if err != nil {
+		return nil, fmt.Errorf("list images of %s: %w", repo, err)
+	}
+	images := make([]ImageInfo, 0, len(resp.GetImages()))
+	for _, img := range resp.GetImages() {
+		images = append(images, ImageInfo{
+			Repository: img.GetRepository(),
+			Tag:        img.GetTag(),
+			Digest:     img.GetManifestDigest(),
+		})
+	}
+	return images, nil
+}
+
+func (c *Client) ListTags(ctx context.Context, repo string) ([]string, error) {
+	resp, err := c.rpc.ListTags(ctx, &pb.ListTagsRequest{Repository: repo})
+	if err != nil {
+		return nil, fmt.Errorf("list tags of %s: %w", repo, err)
+	}
+	return resp.GetTags(), nil
+}
+
+func (c *Client) DeleteImage(ctx context.Context, repo, tag string) error {
+	if _, err := c.rpc.DeleteImage(ctx, &pb.DeleteImageRequest{Repository: repo, Tag: tag}); err != nil {
+		return fmt.Errorf("delete image %s:%s: %w", repo, tag, err)
+	}
+	return nil
+}
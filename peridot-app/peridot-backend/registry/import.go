@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/dkr290/peridot-app/grpc-docker-registry/proto/gen"
+)
+
+// Platform of the image picked from a multi-platform index.
+const (
+	ImportOS   = "linux"
+	ImportArch = "amd64"
+)
+
+// ParseRef splits an image reference like "alpine:3.18" or
+// "quay.io/prometheus/prometheus" into the repository and tag it is stored
+// under, the same way the registry imports it: without the host, Docker Hub
+// images in library/ and latest when there is no tag.
+func ParseRef(ref string) (repo, tag string) {
+	tag = "latest"
+	if i := strings.LastIndex(ref, ":"); i != -1 && !strings.Contains(ref[i:], "/") {
+		ref, tag = ref[:i], ref[i+1:]
+	}
+	host := ""
+	if first, rest, ok := strings.Cut(ref, "/"); ok && strings.ContainsAny(first, ".:") {
+		host, ref = first, rest
+	}
+	switch host {
+	case "", "docker.io", "registry-1.docker.io":
+		if !strings.Contains(ref, "/") {
+			ref = "library/" + ref
+		}
+	}
+	return ref, tag
+}
+
+// Import has the registry copy the image ref from its upstream registry,
+// e.g. Docker Hub or quay.io, and returns it as stored.
+func (c *Client) Import(ctx context.Context, ref string) (*Image, error) {
+	resp, err := c.rpc.ImportImage(ctx, &pb.ImportImageRequest{
+		ImageRef:     ref,
+		Os:           ImportOS,
+		Architecture: ImportArch,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import %s: %w", ref, err)
+	}
+	return c.PullImage(ctx, resp.GetRepository(), resp.GetTag())
+}
@@ -0,0 +1,183 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dkr290/peridot-app/peridot-backend/config"
+)
+
+// Runner runs trivy with args and returns what it printed on stdout.
+// Tests replace it to hand the scanner canned reports.
+type Runner interface {
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// ExecRunner runs the trivy binary at Path.
+type ExecRunner struct {
+	Path string
+}
+
+func (r *ExecRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, r.Path, args...).Output()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return out, nil
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("trivy not found at %q, install it or set TRIVY_PATH", r.Path)
+	case errors.As(err, &exitErr):
+		return nil, fmt.Errorf("trivy failed: %v: %s", err, lastLine(exitErr.Stderr))
+	default:
+		return nil, fmt.Errorf("run trivy: %w", err)
+	}
+}
+
+// Scanner finds the vulnerabilities of images with trivy.
+type Scanner struct {
+	Runner Runner
+	// CacheDir keeps trivy's database between scans, empty uses trivy's default.
+	CacheDir string
+	// Timeout bounds one scan, 0 leaves it to the context.
+	Timeout time.Duration
+}
+
+func NewScanner(cfg *config.Config) *Scanner {
+	return &Scanner{
+		Runner:   &ExecRunner{Path: cfg.TrivyPath},
+		CacheDir: cfg.TrivyCacheDir,
+		Timeout:  cfg.ScanTimeout,
+	}
+}
+
+// ScanImage scans the image ref, which trivy pulls from its registry.
+func (s *Scanner) ScanImage(ctx context.Context, ref string) (*Report, error) {
+	return s.scan(ctx, ref)
+}
+
+// ScanArchive scans an image saved as a docker archive or an OCI layout at
+// path, name is what the report calls it.
+func (s *Scanner) ScanArchive(ctx context.Context, path, name string) (*Report, error) {
+	report, err := s.scan(ctx, path, "--input")
+	if err != nil {
+		return nil, err
+	}
+	report.Image = name
+	return report, nil
+}
+
+// scan runs trivy image for target, flags go before the target.
+func (s *Scanner) scan(ctx context.Context, target string, flags ...string) (*Report, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	args := []string{"image", "--format", "json", "--quiet", "--scanners", "vuln"}
+	if s.CacheDir != "" {
+		args = append(args, "--cache-dir", s.CacheDir)
+	}
+	args = append(args, flags...)
+	args = append(args, target)
+
+	out, err := s.Runner.Run(ctx, args...)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("scan %s: no result within %s", target, s.Timeout)
+		}
+		return nil, fmt.Errorf("scan %s: %w", target, err)
+	}
+	report, err := ParseReport(out)
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", target, err)
+	}
+	return report, nil
+}
+
+// trivyReport is the part of trivy's JSON output (schema version 2) the
+// scanner reads.
+type trivyReport struct {
+	SchemaVersion int
+	ArtifactName  string
+	CreatedAt     time.Time
+	Metadata      struct {
+		OS *struct {
+			Family string
+			Name   string
+		}
+	}
+	Results []struct {
+		Target          string
+		Class           string
+		Type            string
+		Vulnerabilities []struct {
+			VulnerabilityID  string
+			PkgName          string
+			InstalledVersion string
+			FixedVersion     string
+			Severity         string
+			Title            string
+			Description      string
+			PrimaryURL       string
+		}
+	}
+}
+
+// ParseReport maps the JSON output of trivy image into a report.
+func ParseReport(data []byte) (*Report, error) {
+	var tr trivyReport
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return nil, fmt.Errorf("parse trivy report: %w", err)
+	}
+	if tr.SchemaVersion != 2 {
+		return nil, fmt.Errorf("parse trivy report: unsupported schema version %d", tr.SchemaVersion)
+	}
+
+	report := &Report{Image: tr.ArtifactName, ScannedAt: tr.CreatedAt, Vulnerabilities: []Vulnerability{}}
+	if report.ScannedAt.IsZero() {
+		report.ScannedAt = time.Now().UTC()
+	}
+	if tr.Metadata.OS != nil {
+		report.OS = OS{Family: tr.Metadata.OS.Family, Name: tr.Metadata.OS.Name}
+	}
+	for _, res := range tr.Results {
+		for _, v := range res.Vulnerabilities {
+			severity := strings.ToUpper(v.Severity)
+			if _, ok := severityWeights[severity]; !ok {
+				severity = SeverityUnknown
+			}
+			report.Vulnerabilities = append(report.Vulnerabilities, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Severity:         severity,
+				Title:            v.Title,
+				Description:      v.Description,
+				URL:              v.PrimaryURL,
+				Target:           res.Target,
+				Class:            res.Class,
+				Type:             res.Type,
+				PkgName:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+			})
+		}
+	}
+	sort.SliceStable(report.Vulnerabilities, func(i, j int) bool {
+		a, b := report.Vulnerabilities[i], report.Vulnerabilities[j]
+		if wa, wb := severityWeights[a.Severity], severityWeights[b.Severity]; wa != wb {
+			return wa > wb
+		}
+		return a.ID < b.ID
+	})
+	return report, nil
+}
+
+func lastLine(b []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	return lines[len(lines)-1]
+}
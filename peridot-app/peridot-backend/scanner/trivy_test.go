@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+const alpineReport = `{
+  "SchemaVersion": 2,
+  "CreatedAt": "2026-10-01T12:00:00Z",
+  "ArtifactName": "alpine:3.18",
+  "ArtifactType": "container_image",
+  "Metadata": {"OS": {"Family": "alpine", "Name": "3.18.0"}},
+  "Results": [
+    {
+      "Target": "alpine:3.18 (alpine 3.18.0)",
+      "Class": "os-pkgs",
+      "Type": "alpine",
+      "Vulnerabilities": [
+        {"VulnerabilityID": "CVE-2023-5678", "PkgName": "libcrypto3", "InstalledVersion": "3.1.0-r4", "FixedVersion": "3.1.4-r1", "Severity": "MEDIUM", "Title": "openssl: DH keys"},
+        {"VulnerabilityID": "CVE-2023-2650", "PkgName": "libssl3", "InstalledVersion": "3.1.0-r4", "FixedVersion": "3.1.1-r0", "Severity": "HIGH", "Title": "openssl: ASN1 objects"},
+        {"VulnerabilityID": "CVE-2024-0001", "PkgName": "busybox", "InstalledVersion": "1.36.0-r9", "Severity": "negligible"}
+      ]
+    },
+    {"Target": "usr/bin/app", "Class": "lang-pkgs", "Type": "gobinary"}
+  ]
+}`
+
+type fakeRunner struct {
+	out  string
+	err  error
+	args []string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	r.args = args
+	return []byte(r.out), r.err
+}
+
+func TestScanImage(t *testing.T) {
+	runner := &fakeRunner{out: alpineReport}
+	s := &Scanner{Runner: runner, CacheDir: "/var/cache/trivy"}
+
+	report, err := s.ScanImage(context.Background(), "alpine:3.18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := []string{"image", "--format", "json", "--quiet", "--scanners", "vuln", "--cache-dir", "/var/cache/trivy", "alpine:3.18"}
+	if !slices.Equal(runner.args, wantArgs) {
+		t.Errorf("args = %q, want %q", runner.args, wantArgs)
+	}
+	if report.Image != "alpine:3.18" || report.OS.Family != "alpine" {
+		t.Errorf("image %q, os %+v", report.Image, report.OS)
+	}
+
+	var ids []string
+	for _, v := range report.Vulnerabilities {
+		ids = append(ids, v.ID)
+	}
+	if want := []string{"CVE-2023-2650", "CVE-2023-5678", "CVE-2024-0001"}; !slices.Equal(ids, want) {
+		t.Errorf("vulnerabilities %q, want worst first %q", ids, want)
+	}
+	high := report.Vulnerabilities[0]
+	if high.PkgName != "libssl3" || high.FixedVersion != "3.1.1-r0" || high.Class != ClassOSPackages || high.Type != "alpine" || !high.Fixable() {
+		t.Errorf("mapped %+v", high)
+	}
+	if unknown := report.Vulnerabilities[2]; unknown.Severity != SeverityUnknown || unknown.Fixable() {
+		t.Errorf("mapped %+v", unknown)
+	}
+	if got := report.Score(); got != 7 {
+		t.Errorf("score = %d, want 7", got)
+	}
+}
+
+func TestScanArchive(t *testing.T) {
+	runner := &fakeRunner{out: `{"SchemaVersion": 2, "ArtifactName": "/tmp/img", "Results": []}`}
+	s := &Scanner{Runner: runner}
+
+	report, err := s.ScanArchive(context.Background(), "/tmp/img", "library/alpine:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(runner.args); n < 3 || runner.args[n-2] != "--input" || runner.args[n-1] != "/tmp/img" {
+		t.Errorf("args = %q", runner.args)
+	}
+	if report.Image != "library/alpine:latest" || report.Vulnerabilities == nil || report.Score() != 0 {
+		t.Errorf("report %+v", report)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner *fakeRunner
+	}{
+		{"trivy fails", &fakeRunner{err: errors.New("exit status 1")}},
+		{"not json", &fakeRunner{out: "FATAL no such image"}},
+		{"old schema", &fakeRunner{out: `{"SchemaVersion": 1}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scanner{Runner: tt.runner}
+			if _, err := s.ScanImage(context.Background(), "alpine"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
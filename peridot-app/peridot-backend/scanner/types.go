@@ -0,0 +1,90 @@
+package scanner
+
+import "time"
+
+// Severities as trivy reports them, from the worst.
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+// Classes of scan targets.
+const (
+	// ClassOSPackages are the packages of the distribution, e.g. apk or deb.
+	ClassOSPackages = "os-pkgs"
+	// ClassLangPackages are application dependencies, e.g. go modules or npm.
+	ClassLangPackages = "lang-pkgs"
+)
+
+// Report is the outcome of scanning one image.
+type Report struct {
+	Image string `json:"image"`
+	OS    OS     `json:"os"`
+	// Vulnerabilities are sorted by severity, the worst first.
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+	ScannedAt       time.Time       `json:"scanned_at"`
+}
+
+// OS is the distribution of the image, empty when trivy found none.
+type OS struct {
+	// Family is e.g. alpine, debian, ubuntu or redhat.
+	Family string `json:"family"`
+	Name   string `json:"name"`
+}
+
+// Vulnerability is one vulnerable package.
+type Vulnerability struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	// Description is trivy's long text, often several paragraphs.
+	Description string `json:"description"`
+	URL         string `json:"url,omitempty"`
+
+	// Target is the part of the image the package belongs to, e.g. the OS
+	// or a lock file.
+	Target string `json:"target"`
+	// Class is ClassOSPackages or ClassLangPackages.
+	Class string `json:"class"`
+	// Type is the package ecosystem, e.g. alpine, debian, gomod or npm.
+	Type             string `json:"type"`
+	PkgName          string `json:"pkg_name"`
+	InstalledVersion string `json:"installed_version"`
+	// FixedVersion is empty while no fix is released.
+	FixedVersion string `json:"fixed_version,omitempty"`
+}
+
+// Fixable reports whether an upgrade of the package fixes v.
+func (v Vulnerability) Fixable() bool {
+	return v.FixedVersion != ""
+}
+
+// severityWeights rank severities and weigh them in the risk score.
+var severityWeights = map[string]int{
+	SeverityCritical: 10,
+	SeverityHigh:     5,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+}
+
+// Counts returns the number of vulnerabilities per severity.
+func (r *Report) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, v := range r.Vulnerabilities {
+		counts[v.Severity]++
+	}
+	return counts
+}
+
+// Score is the risk of the image, the sum of its vulnerabilities weighted
+// by severity: 10 for critical down to 1 for low. 0 means nothing was found.
+func (r *Report) Score() int {
+	score := 0
+	for _, v := range r.Vulnerabilities {
+		score += severityWeights[v.Severity]
+	}
+	return score
+}
@@ -0,0 +1,93 @@
+// Package archive is a client for the Internet Archive search, metadata
+// and download endpoints.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Internet Archive, tests point the client at a stand-in.
+const DefaultBaseURL = "https://archive.org"
+
+// Client calls the archive.org API under BaseURL.
+type Client struct {
+	BaseURL   string
+	HTTP      *http.Client
+	UserAgent string
+}
+
+// NewClient returns a client for archive.org.
+func NewClient() *Client {
+	return &Client{
+		BaseURL:   DefaultBaseURL,
+		HTTP:      &http.Client{Timeout: 60 * time.Second},
+		UserAgent: "videosearch/1.0",
+	}
+}
+
+// APIError is a response the API answered with an error.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("archive.org: %d %s", e.Status, e.Message)
+}
+
+func (c *Client) endpoint(path string, params url.Values) string {
+	u := strings.TrimRight(c.BaseURL, "/") + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return http.DefaultClient
+}
+
+// get sends a GET request for path and decodes the JSON answer into v.
+func (c *Client) get(ctx context.Context, path string, params url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(path, params), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// apiError reads the error response resp.
+func apiError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &APIError{Status: resp.StatusCode, Message: msg}
+}
@@ -0,0 +1,292 @@
+package archive
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the number of files downloaded at once.
+const DefaultWorkers = 4
+
+// progressInterval is how often a running download reports its progress.
+const progressInterval = 250 * time.Millisecond
+
+// ErrChecksum is returned for a file whose checksum differs from the metadata.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Downloader fetches files of an item into Dir/<identifier>/. A download
+// is written to a .part file first, an interrupted one continues with a
+// range request where it stopped on the next run.
+type Downloader struct {
+	Client  *Client
+	Dir     string
+	Workers int
+	// SkipVerify turns off comparing the MD5 and SHA1 of the files with the metadata.
+	SkipVerify bool
+	// Progress, when set, is called from the workers as files start, move
+	// on and finish.
+	Progress func(Progress)
+}
+
+// Progress is the state of one file.
+type Progress struct {
+	File File
+	// Done is the number of bytes on disk, including a resumed part.
+	Done int64
+	// Finished is set once the file is complete or has failed with Err.
+	Finished bool
+	Err      error
+}
+
+// Download is the outcome of one file.
+type Download struct {
+	File File
+	Path string
+	// Resumed is the size of the partial file the download continued from.
+	Resumed int64
+	// Existing is set when the file was already complete on disk.
+	Existing bool
+	// Verified names the checksums that were compared, e.g. "md5+sha1".
+	Verified string
+	Err      error
+}
+
+// Download fetches files of the item identifier with the configured number
+// of workers. It returns the outcome of every file, in the order of files,
+// and the joined errors of the failed ones.
+func (d *Downloader) Download(ctx context.Context, identifier string, files []File) ([]Download, error) {
+	workers := d.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	results := make([]Download, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = d.fetch(ctx, identifier, files[i])
+				d.report(Progress{File: files[i], Done: doneSize(results[i]), Finished: true, Err: results[i].Err})
+			}
+		}()
+	}
+feed:
+	for i := range files {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			for j := i; j < len(files); j++ {
+				results[j] = Download{File: files[j], Err: ctx.Err()}
+			}
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.File.Name, r.Err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+func (d *Downloader) fetch(ctx context.Context, identifier string, f File) Download {
+	res := Download{File: f}
+	name := filepath.FromSlash(f.Name)
+	if !filepath.IsLocal(name) {
+		res.Err = fmt.Errorf("file name %q leaves the item directory", f.Name)
+		return res
+	}
+	res.Path = filepath.Join(d.Dir, identifier, name)
+
+	if st, err := os.Stat(res.Path); err == nil && (f.Size < 0 || st.Size() == f.Size) {
+		if res.Verified, res.Err = d.verify(res.Path, f); res.Err == nil {
+			res.Existing = true
+			return res
+		}
+		// a complete looking file that does not match is fetched again
+	}
+	if err := os.MkdirAll(filepath.Dir(res.Path), 0o755); err != nil {
+		res.Err = err
+		return res
+	}
+
+	part := res.Path + ".part"
+	if st, err := os.Stat(part); err == nil && (f.Size < 0 || st.Size() <= f.Size) {
+		res.Resumed = st.Size()
+	}
+	if f.Size < 0 || res.Resumed < f.Size {
+		if res.Err = d.get(ctx, identifier, f, part, &res.Resumed); res.Err != nil {
+			return res
+		}
+	}
+	if res.Verified, res.Err = d.verify(part, f); res.Err != nil {
+		// the part is wrong somewhere, resuming it would not help
+		os.Remove(part)
+		return res
+	}
+	res.Err = os.Rename(part, res.Path)
+	return res
+}
+
+// get downloads f into part, continuing after the *offset bytes already
+// there. offset is reset when the server sends the whole file instead.
+func (d *Downloader) get(ctx context.Context, identifier string, f File, part string, offset *int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.Client.FileURL(identifier, f.Name), nil)
+	if err != nil {
+		return err
+	}
+	if d.Client.UserAgent != "" {
+		req.Header.Set("User-Agent", d.Client.UserAgent)
+	}
+	if *offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(*offset, 10)+"-")
+	}
+	// the client timeout would cut off large files, the context bounds the download
+	hc := *d.Client.httpClient()
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && *offset > 0:
+		if start, ok := rangeStart(resp.Header.Get("Content-Range")); !ok || start != *offset {
+			return fmt.Errorf("server resumed at %q, expected byte %d", resp.Header.Get("Content-Range"), *offset)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		*offset = 0
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && *offset > 0:
+		// nothing left to send, the part is complete
+		return nil
+	default:
+		return apiError(resp)
+	}
+
+	out, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	pw := &progressWriter{d: d, p: Progress{File: f, Done: *offset}}
+	d.report(pw.p)
+	_, err = io.Copy(io.MultiWriter(out, pw), resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if f.Size >= 0 && pw.p.Done != f.Size {
+		return fmt.Errorf("incomplete download, %d of %d bytes", pw.p.Done, f.Size)
+	}
+	return nil
+}
+
+// verify compares the checksums of the file at path with f and names the
+// ones it compared.
+func (d *Downloader) verify(path string, f File) (string, error) {
+	if d.SkipVerify || (f.MD5 == "" && f.SHA1 == "") {
+		return "", nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	type check struct {
+		name, want string
+		h          hash.Hash
+	}
+	var checks []check
+	if f.MD5 != "" {
+		checks = append(checks, check{"md5", f.MD5, md5.New()})
+	}
+	if f.SHA1 != "" {
+		checks = append(checks, check{"sha1", f.SHA1, sha1.New()})
+	}
+	writers := make([]io.Writer, len(checks))
+	for i, c := range checks {
+		writers[i] = c.h
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return "", err
+	}
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		if got := hex.EncodeToString(c.h.Sum(nil)); !strings.EqualFold(got, c.want) {
+			return "", fmt.Errorf("%w: %s is %s, metadata has %s", ErrChecksum, c.name, got, c.want)
+		}
+		names[i] = c.name
+	}
+	return strings.Join(names, "+"), nil
+}
+
+func (d *Downloader) report(p Progress) {
+	if d.Progress != nil {
+		d.Progress(p)
+	}
+}
+
+// progressWriter counts the bytes written and reports them every progressInterval.
+type progressWriter struct {
+	d    *Downloader
+	p    Progress
+	last time.Time
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.p.Done += int64(len(b))
+	if now := time.Now(); now.Sub(w.last) >= progressInterval {
+		w.last = now
+		w.d.report(w.p)
+	}
+	return len(b), nil
+}
+
+// rangeStart returns the first byte of a Content-Range like "bytes 100-199/200".
+func rangeStart(contentRange string) (int64, bool) {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+func doneSize(r Download) int64 {
+	if st, err := os.Stat(r.Path); err == nil {
+		return st.Size()
+	}
+	if st, err := os.Stat(r.Path + ".part"); err == nil {
+		return st.Size()
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestItemDownload(t *testing.T) {
+	movie := bytes.Repeat([]byte("frame "), 50000)
+	md5sum, sha1sum := md5.Sum(movie), sha1.Sum(movie)
+	metadata := fmt.Sprintf(`{"metadata": {"identifier": "night", "title": "Night"}, "files": [
+		{"name": "night.mp4", "format": "h.264", "source": "derivative", "size": "%d", "md5": "%s", "sha1": "%s"},
+		{"name": "night.ogv", "format": "Ogg Video", "source": "derivative", "size": "6", "md5": "00000000000000000000000000000000"},
+		{"name": "night_meta.xml", "format": "Metadata", "source": "metadata"}
+	]}`, len(movie), hex.EncodeToString(md5sum[:]), hex.EncodeToString(sha1sum[:]))
+
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata/night":
+			w.Write([]byte(metadata))
+		case "/metadata/missing":
+			w.Write([]byte("{}"))
+		case "/download/night/night.mp4":
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+			http.ServeContent(w, r, "night.mp4", time.Time{}, bytes.NewReader(movie))
+		case "/download/night/night.ogv":
+			w.Write([]byte("ogg..."))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	c.BaseURL = srv.URL
+	ctx := context.Background()
+	if _, err := c.Item(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing item: got %v", err)
+	}
+	item, err := c.Item(ctx, "night")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := item.Select([]string{"MP4", "ogg video"}); len(got) != 2 || got[0].Size != int64(len(movie)) {
+		t.Fatalf("selected %+v", got)
+	}
+	if got := item.Select(nil); len(got) != 3 || got[2].Size != -1 {
+		t.Fatalf("selected %+v", got)
+	}
+
+	// a previous run stopped half way through the movie
+	dir := t.TempDir()
+	part := filepath.Join(dir, "night", "night.mp4.part")
+	os.MkdirAll(filepath.Dir(part), 0o755)
+	os.WriteFile(part, movie[:len(movie)/2], 0o644)
+
+	d := &Downloader{Client: c, Dir: dir, Workers: 2}
+	results, err := d.Download(ctx, "night", item.Select([]string{"mp4", "ogv"}))
+	if err == nil || !errors.Is(results[1].Err, ErrChecksum) {
+		t.Fatalf("expected a checksum error for the ogv, got %v", err)
+	}
+	mp4 := results[0]
+	if mp4.Err != nil || mp4.Resumed != int64(len(movie)/2) || mp4.Verified != "md5+sha1" {
+		t.Fatalf("mp4: %+v", mp4)
+	}
+	if want := fmt.Sprintf("bytes=%d-", len(movie)/2); len(ranges) != 1 || ranges[0] != want {
+		t.Errorf("range requests %q, want %q", ranges, want)
+	}
+	if data, _ := os.ReadFile(mp4.Path); !bytes.Equal(data, movie) {
+		t.Error("downloaded movie differs")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "night", "night.ogv.part")); !os.IsNotExist(err) {
+		t.Error("the part of a file failing its checksum is kept")
+	}
+
+	// the next run finds the movie complete
+	results, err = d.Download(ctx, "night", item.Select([]string{"mp4"}))
+	if err != nil || !results[0].Existing || len(ranges) != 1 {
+		t.Fatalf("second run: %+v, %v", results, err)
+	}
+
+	if _, err := d.Download(ctx, "night", []File{{Name: "../escape", Size: -1}}); err == nil || !strings.Contains(err.Error(), "leaves") {
+		t.Errorf("expected a path error, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned for an identifier without an item.
+var ErrNotFound = errors.New("item not found")
+
+// Item is the metadata of an item and its files.
+type Item struct {
+	Identifier string `json:"identifier"`
+	// Metadata holds title, description, year and the other fields of the item.
+	Metadata Doc    `json:"metadata"`
+	Files    []File `json:"files"`
+}
+
+// File is one file of an item with the checksums the archive keeps for it.
+type File struct {
+	Name string `json:"name"`
+	// Format is the archive's name for the format, e.g. "h.264", "512Kb MPEG4" or "Ogg Video".
+	Format string `json:"format"`
+	// Source is original for uploaded files and derivative for converted ones.
+	Source string `json:"source"`
+	// Size is -1 when the archive does not know it.
+	Size  int64  `json:"size"`
+	MD5   string `json:"md5,omitempty"`
+	SHA1  string `json:"sha1,omitempty"`
+	CRC32 string `json:"crc32,omitempty"`
+}
+
+// UnmarshalJSON reads a file of the metadata API, which sends sizes as strings.
+func (f *File) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name, Format, Source string
+		Size                 json.RawMessage
+		MD5, SHA1, CRC32     string
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = File{Name: raw.Name, Format: raw.Format, Source: raw.Source, Size: -1, MD5: raw.MD5, SHA1: raw.SHA1, CRC32: raw.CRC32}
+	if s := strings.Trim(string(raw.Size), `"`); s != "" && s != "null" {
+		size, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("file %s: invalid size %s", raw.Name, raw.Size)
+		}
+		f.Size = size
+	}
+	return nil
+}
+
+// Ext is the extension of the file name without the dot, in lower case.
+func (f File) Ext() string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(f.Name), "."))
+}
+
+// Item returns the metadata of the item identifier.
+func (c *Client) Item(ctx context.Context, identifier string) (*Item, error) {
+	var out struct {
+		Error    string `json:"error"`
+		Metadata Doc    `json:"metadata"`
+		Files    []File `json:"files"`
+	}
+	if err := c.get(ctx, "/metadata/"+url.PathEscape(identifier), nil, &out); err != nil {
+		return nil, fmt.Errorf("item %s: %w", identifier, err)
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("item %s: %w", identifier, &APIError{Status: 200, Message: out.Error})
+	}
+	// an unknown identifier is answered with an empty object
+	if out.Metadata == nil && len(out.Files) == 0 {
+		return nil, fmt.Errorf("item %s: %w", identifier, ErrNotFound)
+	}
+	return &Item{Identifier: identifier, Metadata: out.Metadata, Files: out.Files}, nil
+}
+
+// Select returns the files in one of formats, matched against the format
+// name or the file extension without regard to case, e.g. "mp4" or "h.264".
+// No formats selects every file.
+func (it *Item) Select(formats []string) []File {
+	if len(formats) == 0 {
+		return it.Files
+	}
+	var out []File
+	for _, f := range it.Files {
+		for _, want := range formats {
+			if strings.EqualFold(f.Format, want) || strings.EqualFold(f.Ext(), strings.TrimPrefix(want, ".")) {
+				out = append(out, f)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// FileURL is the download URL of a file of the item identifier.
+func (c *Client) FileURL(identifier, name string) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return c.endpoint("/download/"+url.PathEscape(identifier)+"/"+strings.Join(segments, "/"), nil)
+}
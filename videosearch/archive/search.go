@@ -0,0 +1,233 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultFields are returned when a search names none.
+var DefaultFields = []string{"identifier", "title", "year", "description"}
+
+const (
+	// DefaultRows is the page size of a search.
+	DefaultRows = 50
+	// minScrapeCount and maxScrapeCount are the batch sizes the scrape API accepts
+	minScrapeCount = 100
+	maxScrapeCount = 10000
+)
+
+// Query builds the Lucene query of a search from its filters.
+type Query struct {
+	// Keywords is free text or Lucene syntax, e.g. `title:(night) AND creator:romero`.
+	Keywords string
+	// MediaType is e.g. movies, audio or texts, empty matches all.
+	MediaType   string
+	Collections []string
+	// Language is a language code or name as the items use it, e.g. eng or English.
+	Language string
+	// YearFrom and YearTo bound the year, 0 leaves that end open.
+	YearFrom, YearTo int
+}
+
+// String is the query in the archive's Lucene syntax.
+func (q Query) String() string {
+	var parts []string
+	if k := strings.TrimSpace(q.Keywords); k != "" {
+		parts = append(parts, "("+k+")")
+	}
+	if q.MediaType != "" {
+		parts = append(parts, "mediatype:("+q.MediaType+")")
+	}
+	if len(q.Collections) > 0 {
+		parts = append(parts, "collection:("+strings.Join(q.Collections, " OR ")+")")
+	}
+	if q.Language != "" {
+		parts = append(parts, "language:("+q.Language+")")
+	}
+	if q.YearFrom > 0 || q.YearTo > 0 {
+		from, to := "*", "*"
+		if q.YearFrom > 0 {
+			from = strconv.Itoa(q.YearFrom)
+		}
+		if q.YearTo > 0 {
+			to = strconv.Itoa(q.YearTo)
+		}
+		parts = append(parts, "year:["+from+" TO "+to+"]")
+	}
+	if len(parts) == 0 {
+		return "*:*"
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// Doc is one search result with the requested fields. Multi-valued fields
+// such as collection are lists.
+type Doc map[string]any
+
+// String returns a field as text, lists joined by "; ".
+func (d Doc) String(field string) string {
+	switch v := d[field].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, Doc{"v": item}.String("v"))
+		}
+		return strings.Join(parts, "; ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// SearchOptions is a page of a search.
+type SearchOptions struct {
+	Query  Query
+	Fields []string
+	// Sort orders the results, e.g. "downloads desc" or "date asc".
+	Sort []string
+	Rows int
+	// Page is 1-based.
+	Page int
+}
+
+// Result is a page of results.
+type Result struct {
+	Total int   `json:"total"`
+	Page  int   `json:"page"`
+	Rows  int   `json:"rows"`
+	Docs  []Doc `json:"docs"`
+}
+
+// Pages is the number of pages of the search.
+func (r *Result) Pages() int {
+	if r.Rows <= 0 {
+		return 0
+	}
+	return (r.Total + r.Rows - 1) / r.Rows
+}
+
+// Search returns one page of the results of opts.
+func (c *Client) Search(ctx context.Context, opts SearchOptions) (*Result, error) {
+	if opts.Rows <= 0 {
+		opts.Rows = DefaultRows
+	}
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	params := url.Values{}
+	params.Set("q", opts.Query.String())
+	for _, f := range fieldsOr(opts.Fields) {
+		params.Add("fl[]", f)
+	}
+	for _, s := range opts.Sort {
+		params.Add("sort[]", s)
+	}
+	params.Set("rows", strconv.Itoa(opts.Rows))
+	params.Set("page", strconv.Itoa(opts.Page))
+	params.Set("output", "json")
+
+	var out struct {
+		Error    string `json:"error"`
+		Response struct {
+			NumFound int   `json:"numFound"`
+			Docs     []Doc `json:"docs"`
+		} `json:"response"`
+	}
+	if err := c.get(ctx, "/advancedsearch.php", params, &out); err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("search: %w", &APIError{Status: 200, Message: out.Error})
+	}
+	return &Result{Total: out.Response.NumFound, Page: opts.Page, Rows: opts.Rows, Docs: out.Response.Docs}, nil
+}
+
+// ScrapeOptions is a batch of a cursor search, which unlike pages can walk
+// through any number of results.
+type ScrapeOptions struct {
+	Query  Query
+	Fields []string
+	// Sort orders the results, the scrape API only sorts by some fields,
+	// e.g. "identifier asc" or "downloads desc".
+	Sort []string
+	// Count is the batch size, the API takes 100 to 10000.
+	Count int
+	// Cursor continues after the previous batch, empty starts at the first result.
+	Cursor string
+}
+
+// ScrapeResult is a batch of a cursor search.
+type ScrapeResult struct {
+	Total int   `json:"total"`
+	Docs  []Doc `json:"docs"`
+	// Cursor fetches the next batch, empty after the last one.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// Scrape returns one batch of the results of opts.
+func (c *Client) Scrape(ctx context.Context, opts ScrapeOptions) (*ScrapeResult, error) {
+	count := min(max(opts.Count, minScrapeCount), maxScrapeCount)
+	params := url.Values{}
+	params.Set("q", opts.Query.String())
+	params.Set("fields", strings.Join(fieldsOr(opts.Fields), ","))
+	if len(opts.Sort) > 0 {
+		params.Set("sorts", strings.Join(opts.Sort, ","))
+	}
+	params.Set("count", strconv.Itoa(count))
+	if opts.Cursor != "" {
+		params.Set("cursor", opts.Cursor)
+	}
+
+	var out struct {
+		Error  string `json:"error"`
+		Items  []Doc  `json:"items"`
+		Total  int    `json:"total"`
+		Cursor string `json:"cursor"`
+	}
+	if err := c.get(ctx, "/services/search/v1/scrape", params, &out); err != nil {
+		return nil, fmt.Errorf("scrape: %w", err)
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("scrape: %w", &APIError{Status: 200, Message: out.Error})
+	}
+	return &ScrapeResult{Total: out.Total, Docs: out.Items, Cursor: out.Cursor}, nil
+}
+
+// All walks every result of opts batch by batch, starting at opts.Cursor.
+// Stop the loop to stop fetching.
+func (c *Client) All(ctx context.Context, opts ScrapeOptions) iter.Seq2[Doc, error] {
+	return func(yield func(Doc, error) bool) {
+		for {
+			res, err := c.Scrape(ctx, opts)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, d := range res.Docs {
+				if !yield(d, nil) {
+					return
+				}
+			}
+			if res.Cursor == "" || len(res.Docs) == 0 {
+				return
+			}
+			opts.Cursor = res.Cursor
+		}
+	}
+}
+
+func fieldsOr(fields []string) []string {
+	if len(fields) == 0 {
+		return DefaultFields
+	}
+	return fields
+}
@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestQueryString(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Query
+		want string
+	}{
+		{"empty", Query{}, "*:*"},
+		{"keywords", Query{Keywords: "night", MediaType: "movies"}, "(night) AND mediatype:(movies)"},
+		{"filters", Query{Collections: []string{"prelinger", "feature_films"}, Language: "eng", YearFrom: 1960, YearTo: 1970},
+			"collection:(prelinger OR feature_films) AND language:(eng) AND year:[1960 TO 1970]"},
+		{"open range", Query{YearTo: 1950}, "year:[* TO 1950]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/advancedsearch.php" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("q") != "(night) AND mediatype:(movies)" || q.Get("rows") != "2" || q.Get("page") != "3" ||
+			!slices.Equal(q["fl[]"], []string{"identifier", "collection"}) || !slices.Equal(q["sort[]"], []string{"downloads desc"}) {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": map[string]any{
+			"numFound": 5,
+			"docs": []map[string]any{
+				{"identifier": "night1968", "collection": []string{"feature_films", "moviesandfilms"}},
+				{"identifier": "night1990", "collection": "feature_films"},
+			},
+		}})
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	c.BaseURL = srv.URL
+	res, err := c.Search(context.Background(), SearchOptions{
+		Query:  Query{Keywords: "night", MediaType: "movies"},
+		Fields: []string{"identifier", "collection"},
+		Sort:   []string{"downloads desc"},
+		Rows:   2,
+		Page:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 5 || res.Pages() != 3 || len(res.Docs) != 2 {
+		t.Fatalf("got total %d, pages %d, %d docs", res.Total, res.Pages(), len(res.Docs))
+	}
+	if got := res.Docs[0].String("collection"); got != "feature_films; moviesandfilms" {
+		t.Errorf("collection = %q", got)
+	}
+
+	c.BaseURL = srv.URL + "/missing"
+	if _, err := c.Search(context.Background(), SearchOptions{}); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
+
+func TestAll(t *testing.T) {
+	batches := map[string]map[string]any{
+		"":     {"items": []map[string]any{{"identifier": "a"}, {"identifier": "b"}}, "total": 3, "cursor": "next"},
+		"next": {"items": []map[string]any{{"identifier": "c"}}, "total": 3},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("count") != "100" {
+			http.Error(w, "count below the minimum", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(batches[r.URL.Query().Get("cursor")])
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	c.BaseURL = srv.URL
+	var ids []string
+	for doc, err := range c.All(context.Background(), ScrapeOptions{Count: 10}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, doc.String("identifier"))
+	}
+	if !slices.Equal(ids, []string{"a", "b", "c"}) {
+		t.Errorf("got %v", ids)
+	}
+}
@@ -0,0 +1,3 @@
+module videosearch
+
+go 1.25.1
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"videosearch/archive"
+)
+
+func runItem(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("item", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: videosearch item [flags] <identifier>")
+		fs.PrintDefaults()
+	}
+	formats := fs.String("formats", "", `comma separated formats or extensions to select, e.g. "mp4" or "h.264,Ogg Video"`)
+	format := fs.String("format", "table", "output format of the file list: table or json")
+	download := fs.Bool("download", false, "download the selected files")
+	dir := fs.String("dir", ".", "directory to download into, files go to <dir>/<identifier>/")
+	workers := fs.Int("workers", archive.DefaultWorkers, "files downloaded at once")
+	noVerify := fs.Bool("no-verify", false, "do not check the MD5 and SHA1 of downloaded files")
+	baseURL := fs.String("base-url", archive.DefaultBaseURL, "archive.org API base URL")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q, use table or json", *format)
+	}
+
+	client := archive.NewClient()
+	client.BaseURL = *baseURL
+	item, err := client.Item(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	files := item.Select(splitList(*formats))
+	if len(files) == 0 {
+		return fmt.Errorf("item %s has no files in %s", item.Identifier, *formats)
+	}
+
+	if !*download {
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(&archive.Item{Identifier: item.Identifier, Metadata: item.Metadata, Files: files})
+		}
+		fmt.Printf("%s: %s\n\n", item.Identifier, item.Metadata.String("title"))
+		return writeFiles(os.Stdout, files)
+	}
+
+	p := newProgress(os.Stderr, files)
+	d := &archive.Downloader{Client: client, Dir: *dir, Workers: *workers, SkipVerify: *noVerify, Progress: p.update}
+	results, err := d.Download(ctx, item.Identifier, files)
+	p.finish()
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "failed  %s: %v\n", r.File.Name, r.Err)
+		case r.Existing:
+			fmt.Fprintf(os.Stderr, "exists  %s%s\n", r.Path, verifiedNote(r))
+		case r.Resumed > 0:
+			fmt.Fprintf(os.Stderr, "resumed %s from %s%s\n", r.Path, formatSize(r.Resumed), verifiedNote(r))
+		default:
+			fmt.Fprintf(os.Stderr, "done    %s%s\n", r.Path, verifiedNote(r))
+		}
+	}
+	if err != nil {
+		return errors.New("some files failed, run again to resume them")
+	}
+	return nil
+}
+
+func writeFiles(w io.Writer, files []archive.File) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tFORMAT\tSOURCE\tSIZE\tMD5")
+	var total int64
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Name, f.Format, f.Source, formatSize(f.Size), f.MD5)
+		total += max(f.Size, 0)
+	}
+	fmt.Fprintf(tw, "\t\t\t%s\t%d files\n", formatSize(total), len(files))
+	return tw.Flush()
+}
+
+func verifiedNote(r archive.Download) string {
+	if r.Verified == "" {
+		return ""
+	}
+	return " (" + r.Verified + " ok)"
+}
+
+// progress keeps one status line of all downloads up to date.
+type progress struct {
+	w     io.Writer
+	total int64
+
+	mu       sync.Mutex
+	done     map[string]int64
+	finished int
+	files    int
+}
+
+func newProgress(w io.Writer, files []archive.File) *progress {
+	p := &progress{w: w, done: map[string]int64{}, files: len(files)}
+	for _, f := range files {
+		p.total += max(f.Size, 0)
+	}
+	return p
+}
+
+func (p *progress) update(ev archive.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[ev.File.Name] = ev.Done
+	if ev.Finished {
+		p.finished++
+	}
+	var done int64
+	for _, n := range p.done {
+		done += n
+	}
+	line := fmt.Sprintf("[%d/%d files] %s", p.finished, p.files, formatSize(done))
+	if p.total > 0 {
+		line += fmt.Sprintf(" of %s, %d%%", formatSize(p.total), done*100/p.total)
+	}
+	fmt.Fprintf(p.w, "\r%-60s", line)
+}
+
+func (p *progress) finish() {
+	fmt.Fprintln(p.w)
+}
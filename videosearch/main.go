@@ -1,65 +1,172 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"videosearch/archive"
 )
 
-type Movie struct {
-	Identifier  string `json:"identifier"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-}
+const usage = `usage: videosearch <command> [flags]
 
-type Response struct {
-	Response struct {
-		Docs []Movie `json:"docs"`
-	} `json:"response"`
-}
+commands:
+  search    search the Internet Archive (the default command)
+  item      list the files of an item and download them
+
+run "videosearch <command> -h" for the flags of a command`
+
+func main() {
+	args := os.Args[1:]
+	cmd := "search"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-func searchMovies(keyword string, rows int) error {
-	basURL := "https://archive.org/advancedsearch.php"
-	params := url.Values{}
-	params.Set("q", fmt.Sprintf("mediaType:movies AND (%s)", keyword))
-	params.Add("fl[]", "identifier")
-	params.Add("fl[]", "title")
-	params.Add("fl[]", "description")
-	params.Set("rows", fmt.Sprintf("%d", rows))
-	params.Set("output", "json")
-
-	resp, err := http.Get(basURL + "?" + params.Encode())
+	var err error
+	switch cmd {
+	case "search":
+		err = runSearch(ctx, args)
+	case "item":
+		err = runItem(ctx, args)
+	case "help":
+		fmt.Println(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", cmd, usage)
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
 	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func runSearch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	keyword := fs.String("keyword", "", "keywords or a Lucene query, also taken from the arguments")
+	mediaType := fs.String("mediatype", "movies", "media type, e.g. movies, audio or texts, empty for all")
+	collection := fs.String("collection", "", "comma separated collections, e.g. prelinger,feature_films")
+	language := fs.String("language", "", "language, e.g. eng or English")
+	years := fs.String("year", "", "year or range, e.g. 1968, 1960-1970, 1990- or -1950")
+	sort := fs.String("sort", "", `comma separated sort order, e.g. "downloads desc,date asc"`)
+	fields := fs.String("fields", strings.Join(archive.DefaultFields, ","), "comma separated fields to return")
+	rows := fs.Int("rows", 20, "results per page, or per batch with -cursor")
+	page := fs.Int("page", 1, "page to show")
+	scroll := fs.Bool("scroll", false, "use the cursor API, which is not limited to the first 10000 results")
+	cursor := fs.String("cursor", "", "continue a -scroll search after a previous batch")
+	all := fs.Bool("all", false, "fetch every result through the cursor API")
+	format := fs.String("format", "table", "output format: table, json or csv")
+	baseURL := fs.String("base-url", archive.DefaultBaseURL, "archive.org API base URL")
+	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	var result Response
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	q := archive.Query{
+		Keywords:    strings.TrimSpace(*keyword + " " + strings.Join(fs.Args(), " ")),
+		MediaType:   *mediaType,
+		Collections: splitList(*collection),
+		Language:    *language,
+	}
+	var err error
+	if q.YearFrom, q.YearTo, err = parseYears(*years); err != nil {
 		return err
 	}
-	for _, movie := range result.Response.Docs {
-		fmt.Printf(
-			"Title: %s\nIdentifier: %s\n Description %.200s...\n\n",
-			movie.Title,
-			movie.Identifier,
-			movie.Description,
-		)
+	if q.Keywords == "" && len(q.Collections) == 0 && q.YearFrom == 0 && q.YearTo == 0 && q.Language == "" {
+		return errors.New("nothing to search for, give keywords or a filter")
+	}
+	out, err := newOutput(os.Stdout, *format, splitList(*fields))
+	if err != nil {
+		return err
+	}
+
+	client := archive.NewClient()
+	client.BaseURL = *baseURL
+
+	switch {
+	case *all:
+		opts := archive.ScrapeOptions{Query: q, Fields: out.fields, Sort: splitList(*sort), Count: 1000, Cursor: *cursor}
+		var docs []archive.Doc
+		for doc, err := range client.All(ctx, opts) {
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+		return out.write(&archive.ScrapeResult{Total: len(docs), Docs: docs}, docs)
+
+	case *scroll || *cursor != "":
+		opts := archive.ScrapeOptions{Query: q, Fields: out.fields, Sort: splitList(*sort), Count: *rows, Cursor: *cursor}
+		res, err := client.Scrape(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if err := out.write(res, res.Docs); err != nil {
+			return err
+		}
+		if res.Cursor != "" {
+			fmt.Fprintf(os.Stderr, "%d results, next batch: -cursor %s\n", res.Total, res.Cursor)
+		} else {
+			fmt.Fprintf(os.Stderr, "%d results, last batch\n", res.Total)
+		}
+		return nil
+
+	default:
+		opts := archive.SearchOptions{Query: q, Fields: out.fields, Sort: splitList(*sort), Rows: *rows, Page: *page}
+		res, err := client.Search(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if err := out.write(res, res.Docs); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "page %d of %d, %d results\n", res.Page, res.Pages(), res.Total)
+		return nil
 	}
-	return nil
 }
 
-func main() {
-	k := flag.String("keyword", "", "keyword")
-	flag.Parse()
-	if *k == "" {
-		fmt.Println("keyword is empty ")
-		os.Exit(1)
+// parseYears parses "1968", "1960-1970", "1990-" or "-1950".
+func parseYears(s string) (from, to int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	lo, hi, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hi = lo
+	}
+	if lo != "" {
+		if from, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+			return 0, 0, fmt.Errorf("invalid year %q", s)
+		}
+	}
+	if hi != "" {
+		if to, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+			return 0, 0, fmt.Errorf("invalid year %q", s)
+		}
 	}
-	if err := searchMovies(*k, 5); err != nil {
-		fmt.Println("error:", err)
+	if from > 0 && to > 0 && from > to {
+		return 0, 0, fmt.Errorf("invalid year range %q", s)
+	}
+	return from, to, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
+	return out
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"videosearch/archive"
+)
+
+// maxCell is the width a table cell is cut to.
+const maxCell = 60
+
+// output writes search results in one of the formats.
+type output struct {
+	w      io.Writer
+	format string
+	fields []string
+}
+
+func newOutput(w io.Writer, format string, fields []string) (*output, error) {
+	switch format {
+	case "table", "json", "csv":
+	default:
+		return nil, fmt.Errorf("unknown format %q, use table, json or csv", format)
+	}
+	if len(fields) == 0 {
+		fields = archive.DefaultFields
+	}
+	return &output{w: w, format: format, fields: fields}, nil
+}
+
+// write prints docs, the JSON format prints the whole result instead.
+func (o *output) write(result any, docs []archive.Doc) error {
+	switch o.format {
+	case "json":
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case "csv":
+		cw := csv.NewWriter(o.w)
+		cw.Write(o.fields)
+		for _, d := range docs {
+			cw.Write(o.row(d, 0))
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+		header := make([]string, len(o.fields))
+		for i, f := range o.fields {
+			header[i] = strings.ToUpper(f)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, d := range docs {
+			fmt.Fprintln(tw, strings.Join(o.row(d, maxCell), "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// row returns the fields of d, flattened to one line and cut to width
+// runes when width is not 0.
+func (o *output) row(d archive.Doc, width int) []string {
+	row := make([]string, len(o.fields))
+	for i, f := range o.fields {
+		v := d.String(f)
+		if width > 0 {
+			v = strings.Join(strings.Fields(v), " ")
+			if r := []rune(v); len(r) > width {
+				v = string(r[:width-3]) + "..."
+			}
+		}
+		row[i] = v
+	}
+	return row
+}
+
+// formatSize prints a byte count in binary units, "?" when it is unknown.
+func formatSize(n int64) string {
+	if n < 0 {
+		return "?"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}